@@ -0,0 +1,80 @@
+package csrf
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const actionPurpose = "action"
+
+const actionKey contextKey = 4
+
+// actionMinter makes tokens for the forms of a page served with
+// BindAction.
+type actionMinter func(method, path string) (string, error)
+
+// withActionMinter adds an actionMinter for r to ctx with BindAction.
+func (m *Middleware) withActionMinter(ctx context.Context, w http.ResponseWriter, r *http.Request, now time.Time) context.Context {
+	if !m.BindAction || m.DoubleSubmit != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, actionKey, actionMinter(func(method, path string) (string, error) {
+		token, _, err := m.token(w, r, now, method, path)
+		return token, err
+	}))
+}
+
+// TokenFor() returns a token for a form on the page served for r that
+// submits to action with method, such as "post" and "/account/delete".
+// The action is resolved against the request URL as a browser would, and
+// an empty one is the page itself. With WithActionBinding() the token
+// only validates for that method and path, so every form gets its own;
+// otherwise it is Token(r).
+func TokenFor(r *http.Request, method, action string) (string, error) {
+	mint, ok := r.Context().Value(actionKey).(actionMinter)
+	if !ok {
+		return Token(r), nil
+	}
+	u, err := r.URL.Parse(action)
+	if err != nil {
+		return "", err
+	}
+	return mint(method, u.Path)
+}
+
+// action binds session to an HTTP method and path.
+func action(session []byte, method, path string) []byte {
+	return bind(actionPurpose, session, []byte(strings.ToUpper(method)), []byte(path))
+}
+
+// GenerateTokenFor() creates a token that only validates for a request
+// with the given method and URL path, so a token harvested from one form
+// cannot be replayed against another endpoint.
+func (a *Authenticator) GenerateTokenFor(date time.Time, session []byte, method, path string) (string, error) {
+	return a.generateFor(context.Background(), date, session, method, path)
+}
+
+func (a *Authenticator) generateFor(ctx context.Context, date time.Time, session []byte, method, path string) (string, error) {
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	return a.generate(ctx, date, action(session, method, path))
+}
+
+// ValidateTokenFor() returns nil if the token was generated by
+// GenerateTokenFor() for the session, method and path and has not
+// expired.
+func (a *Authenticator) ValidateTokenFor(date time.Time, session []byte, method, path, token string) error {
+	_, err := a.validateFor(context.Background(), date, session, session, method, path, token)
+	return err
+}
+
+// validateFor is ValidateTokenFor() for a session bound to raw.
+func (a *Authenticator) validateFor(ctx context.Context, date time.Time, raw, session []byte, method, path, token string) (int64, error) {
+	if err := a.checkSession(session); err != nil {
+		return 0, err
+	}
+	return a.validate(ctx, date, raw, action(session, method, path), token)
+}
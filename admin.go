@@ -0,0 +1,118 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// AdminHandler is a management endpoint for a Middleware, meant for an
+// internal port or behind an operator-only route, so operations need no
+// redeploy. Mount it under a prefix with http.StripPrefix(). It serves:
+//
+//	GET  /stats   the Counts() of Stats
+//	GET  /config  the Snapshot() of the Middleware
+//	POST /rotate  a new random primary key, keeping the old one as the
+//	              only secondary; see Authenticator.SetKeys()
+//	GET  /mode    {"mode":"enforce"} or {"mode":"report-only"}
+//	PUT  /mode    the same body, to switch modes; see SetReportOnly()
+//
+// A rotation keeps only the key it replaces, so tokens from before the
+// previous rotation fail; rotate at most once every AcceptedWindows
+// windows. Every request must pass Authorize first, or gets 403 Forbidden.
+type AdminHandler struct {
+	Middleware *Middleware
+	// Authorize admits operators, for example by client certificate or
+	// an admin session. If nil, every request is refused.
+	Authorize func(r *http.Request) bool
+	// Stats, if set, is the Metrics of the Authenticator served at
+	// /stats.
+	Stats *CountingMetrics
+}
+
+// adminMode is the body of /mode.
+type adminMode struct {
+	Mode string `json:"mode"`
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Authorize == nil || !h.Authorize(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	switch path := strings.TrimPrefix(r.URL.Path, "/"); {
+	case path == "stats" && r.Method == http.MethodGet:
+		if h.Stats == nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, h.Stats.Counts())
+	case path == "config" && r.Method == http.MethodGet:
+		writeJSON(w, h.Middleware.Snapshot())
+	case path == "rotate" && r.Method == http.MethodPost:
+		h.rotate(w)
+	case path == "mode" && r.Method == http.MethodGet:
+		writeJSON(w, adminMode{h.Middleware.modeName()})
+	case path == "mode" && r.Method == http.MethodPut:
+		var body adminMode
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil ||
+			body.Mode != "enforce" && body.Mode != "report-only" {
+			http.Error(w, `mode must be "enforce" or "report-only"`, http.StatusBadRequest)
+			return
+		}
+		h.Middleware.SetReportOnly(body.Mode == "report-only")
+		h.Middleware.logger().Warn("csrf: enforcement mode changed", "mode", body.Mode)
+		writeJSON(w, body)
+	case path == "stats" || path == "config" || path == "mode" || path == "rotate":
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}
+}
+
+// rotate installs a new random primary key in the Authenticator of the
+// Middleware. Keys of a KeyProvider, a FrozenAuthenticator or tenants are
+// managed elsewhere, so those get 409 Conflict.
+func (h *AdminHandler) rotate(w http.ResponseWriter) {
+	a := h.Middleware.Authenticator
+	if a == nil || h.Middleware.Frozen != nil || h.Middleware.Tenants != nil || a.Keys != nil {
+		http.Error(w, "keys are not managed by this Authenticator", http.StatusConflict)
+		return
+	}
+	old, _ := a.staticKeys()
+	key, err := GenerateKey(len(old))
+	if err == nil {
+		err = a.SetKeys(key, old)
+	}
+	if err != nil {
+		a.logger().Warn("csrf: key rotation failed", "reason", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	a.info("csrf: key rotated")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logger returns the Logger of the Authenticator of m, or of the Default
+// of its Tenants.
+func (m *Middleware) logger() Logger {
+	if m.Authenticator != nil {
+		return m.Authenticator.logger()
+	}
+	if m.Tenants != nil {
+		return m.Tenants.logger()
+	}
+	return stdLogger{}
+}
+
+// writeJSON answers with v as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,100 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	a := testAuthenticator(t)
+	stats := &CountingMetrics{}
+	a.Metrics = stats
+	m := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithAuthenticator(a), testSessionOption).(*Middleware)
+	h := &AdminHandler{
+		Middleware: m,
+		Authorize:  func(r *http.Request) bool { return r.Header.Get("X-Admin") == "yes" },
+		Stats:      stats,
+	}
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-Admin", "yes")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	post := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(TokenHeader, token)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unauthorized: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	if code := post(""); code != http.StatusForbidden {
+		t.Fatalf("enforcing: got status %d, want %d", code, http.StatusForbidden)
+	}
+	if w := admin(http.MethodPut, "/mode", `{"mode":"report-only"}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT /mode: got status %d", w.Code)
+	}
+	if code := post(""); code != http.StatusOK {
+		t.Fatalf("report-only: got status %d, want %d", code, http.StatusOK)
+	}
+	var snapshot ConfigSnapshot
+	if err := json.Unmarshal(admin(http.MethodGet, "/config", "").Body.Bytes(), &snapshot); err != nil || snapshot.Mode != "report-only" {
+		t.Fatalf("GET /config: got mode %q, %v", snapshot.Mode, err)
+	}
+	if w := admin(http.MethodPut, "/mode", `{"mode":"off"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /mode with an unknown mode: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	admin(http.MethodPut, "/mode", `{"mode":"enforce"}`)
+	if got := admin(http.MethodGet, "/mode", "").Body.String(); got != `{"mode":"enforce"}`+"\n" {
+		t.Fatalf("GET /mode: got %q", got)
+	}
+
+	old := a.GenerateToken(a.now(), testSession)
+	if w := admin(http.MethodPost, "/rotate", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("POST /rotate: got status %d, want %d", w.Code, http.StatusNoContent)
+	}
+	token := a.GenerateToken(a.now(), testSession)
+	if token == old {
+		t.Fatal("POST /rotate did not change the key")
+	}
+	if post(old) != http.StatusOK || post(token) != http.StatusOK {
+		t.Fatal("tokens of the old or new key fail after POST /rotate")
+	}
+	admin(http.MethodPost, "/rotate", "")
+	if code := post(old); code != http.StatusForbidden {
+		t.Fatalf("token from before the previous rotation: got status %d, want %d", code, http.StatusForbidden)
+	}
+
+	var counts Counts
+	if err := json.Unmarshal(admin(http.MethodGet, "/stats", "").Body.Bytes(), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts.Validated != 2 || counts.Rejected["missing_token"] != 2 || counts.Rejected["mismatch"] != 1 {
+		t.Fatalf("GET /stats: got %+v", counts)
+	}
+	if w := admin(http.MethodDelete, "/config", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE /config: got status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAdminHandlerRotateFrozen(t *testing.T) {
+	m := Protect(nil, WithFrozenAuthenticator(Freeze(testAuthenticator(t)))).(*Middleware)
+	h := &AdminHandler{Middleware: m, Authorize: func(*http.Request) bool { return true }}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rotate", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
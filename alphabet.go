@@ -0,0 +1,33 @@
+package csrf
+
+// Alphabets for the Alphabet field, each sorted as required.
+const (
+	// URLSafeAlphabet is the default: the 66 characters URLs leave
+	// unescaped, giving 3.02 bits per character overall
+	URLSafeAlphabet = "-.0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz~"
+	// Base64URLAlphabet is the 64 characters of base64url, without the
+	// '.' and '~' some form processors reject, giving 3 bits per
+	// character overall
+	Base64URLAlphabet = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+	// HexAlphabet is lowercase hexadecimal, giving 2 bits per character
+	// overall, and also works in CaseInsensitive mode
+	HexAlphabet = "0123456789abcdef"
+)
+
+// checkAlphabet returns ErrAlphabet if Alphabet cannot be binary searched
+// or, in CaseInsensitive mode, would not survive lowercasing.
+func (a *Authenticator) checkAlphabet() error {
+	alphabet := a.Alphabet
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return ErrAlphabet
+	}
+	for i, c := range alphabet {
+		if i > 0 && alphabet[i-1] >= c {
+			return ErrAlphabet
+		}
+		if a.CaseInsensitive && c >= 'A' && c <= 'Z' {
+			return ErrAlphabet
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package csrf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+)
+
+// AttachScriptName is the file name AttachHandler() serves its script as.
+const AttachScriptName = "csrf.js"
+
+// attachTokenName is the file name of the token endpoint of
+// AttachHandler(), next to the script.
+const attachTokenName = "token"
+
+// attachScript patches fetch() and XMLHttpRequest to add the token header
+// to same-origin unsafe requests. Its parameters are the header, whether
+// tokens are bound to actions, and the token endpoint relative to the
+// script.
+const attachScript = `(function () {
+	"use strict";
+	var header = %s, bindAction = %s;
+	var script = document.currentScript;
+	var tokenURL = new URL(%s, script ? script.src : location.href).href;
+	var safe = /^(GET|HEAD|OPTIONS|TRACE)$/i;
+	var tokens = {};
+
+	function resolve(url) {
+		try {
+			return new URL(url, location.href);
+		} catch (e) {
+			return null;
+		}
+	}
+	function needs(method, url) {
+		var u = resolve(url);
+		return !safe.test(method) && u !== null && u.origin === location.origin;
+	}
+	function key(method, url) {
+		return bindAction ? method.toUpperCase() + " " + resolve(url).pathname : "";
+	}
+	function cached(method, url) {
+		var t = tokens[key(method, url)];
+		return t && t.expiry > Date.now() ? t.token : "";
+	}
+	function token(method, url) {
+		var k = key(method, url), t = cached(method, url);
+		if (t) {
+			return Promise.resolve(t);
+		}
+		var query = "";
+		if (bindAction) {
+			query = "?method=" + encodeURIComponent(method.toUpperCase()) + "&path=" + encodeURIComponent(resolve(url).pathname);
+		}
+		return fetch.call(window, tokenURL + query, {credentials: "same-origin", cache: "no-store"}).then(function (resp) {
+			if (!resp.ok) {
+				throw new Error("csrf: token endpoint returned " + resp.status);
+			}
+			return resp.json();
+		}).then(function (body) {
+			tokens[k] = {token: body.token, expiry: Date.now() + (body.expiresIn - 10) * 1000};
+			return body.token;
+		}).catch(function () {
+			// send the request anyway, so the application sees the 403
+			return "";
+		});
+	}
+
+	var fetch = window.fetch;
+	if (fetch) {
+		window.fetch = function (input, init) {
+			var req;
+			try {
+				req = new Request(input, init);
+			} catch (e) {
+				return Promise.reject(e);
+			}
+			if (!needs(req.method, req.url) || req.headers.has(header)) {
+				return fetch.call(window, req);
+			}
+			return token(req.method, req.url).then(function (t) {
+				if (t) {
+					req.headers.set(header, t);
+				}
+				return fetch.call(window, req);
+			});
+		};
+	}
+
+	if (window.XMLHttpRequest) {
+		var proto = XMLHttpRequest.prototype;
+		var open = proto.open, send = proto.send, setRequestHeader = proto.setRequestHeader;
+		var requests = new WeakMap();
+		proto.open = function (method, url, async) {
+			requests.set(this, {method: String(method), url: String(url), async: async !== false, set: false});
+			return open.apply(this, arguments);
+		};
+		proto.setRequestHeader = function (name, value) {
+			var r = requests.get(this);
+			if (r && String(name).toLowerCase() === header.toLowerCase()) {
+				r.set = true;
+			}
+			return setRequestHeader.apply(this, arguments);
+		};
+		proto.send = function (body) {
+			var xhr = this, r = requests.get(xhr);
+			if (!r || r.set || !fetch || !needs(r.method, r.url)) {
+				return send.apply(xhr, arguments);
+			}
+			if (!r.async) {
+				// a synchronous request cannot wait for a token
+				var t = cached(r.method, r.url);
+				if (t) {
+					setRequestHeader.call(xhr, header, t);
+				}
+				return send.apply(xhr, arguments);
+			}
+			token(r.method, r.url).then(function (t) {
+				if (t) {
+					setRequestHeader.call(xhr, header, t);
+				}
+				send.call(xhr, body);
+			});
+		};
+	}
+})();
+`
+
+// AttachHandler() returns a handler serving a script that adds tokens to
+// an existing single page application without touching its call sites,
+// and the token endpoint the script uses. Mount it on a directory and
+// load the script from every page:
+//
+//	mux.Handle("/csrf/", csrf.AttachHandler(opts...))
+//	<script src="/csrf/csrf.js"></script>
+//
+// The script patches fetch() and XMLHttpRequest so every same-origin
+// request with an unsafe method carries a token in the first of
+// TokenHeaders, fetched from the "token" file next to the script, which
+// is TokenHandler() with the same options. Tokens are cached until
+// shortly before they expire, per method and path with
+// WithActionBinding(). Requests that set the header themselves are left
+// alone, and synchronous XMLHttpRequests only get a cached token. It
+// takes the same options as Protect() and panics like it on a
+// misconfiguration.
+func AttachHandler(opts ...Option) http.Handler {
+	m := Protect(nil, opts...).(*Middleware)
+	header, _ := json.Marshal(m.headers()[0])
+	tokenName, _ := json.Marshal(attachTokenName)
+	script := []byte(fmt.Sprintf(attachScript, header, fmt.Sprint(m.BindAction), tokenName))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case attachTokenName:
+			m.vend(w, r)
+		case AttachScriptName:
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Write(script)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
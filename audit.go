@@ -0,0 +1,99 @@
+package csrf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FormAudit finds POST forms that do not submit a CSRF token, in
+// html/template sources or in rendered pages captured by tests.
+type FormAudit struct {
+	// Field is the name of the hidden token input, "csrf_token" if empty
+	Field string
+	// Markers are template snippets that render the token field, such as
+	// a template function call. A form containing one is accepted.
+	Markers []string
+}
+
+// FormIssue describes a form missing a token field.
+type FormIssue struct {
+	// Line is the 1-based line of the <form> tag
+	Line int
+	// Action is the form's action attribute, if any
+	Action string
+}
+
+// The form patterns are shared by FormAudit and WithFormInjection().
+var (
+	formOpen    = regexp.MustCompile(`(?is)<form\b[^>]*>`)
+	formClose   = regexp.MustCompile(`(?i)</form\s*>`)
+	formMethod  = regexp.MustCompile(`(?i)\bmethod\s*=\s*["']?\s*post\b`)
+	formAction  = regexp.MustCompile(`(?i)\baction\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	formFieldRe = `(?i)<input\b[^>]*\bname\s*=\s*["']?%s["'\s/>]`
+)
+
+// Check() returns the POST forms in src that contain neither an input named
+// Field nor one of the Markers.
+func (f *FormAudit) Check(src []byte) []FormIssue {
+	field := f.Field
+	if field == "" {
+		field = "csrf_token"
+	}
+	input := regexp.MustCompile(strings.Replace(formFieldRe, "%s", regexp.QuoteMeta(field), 1))
+
+	var issues []FormIssue
+	for _, loc := range formOpen.FindAllIndex(src, -1) {
+		tag := src[loc[0]:loc[1]]
+		if !formMethod.Match(tag) {
+			continue
+		}
+		body := src[loc[1]:]
+		if end := formClose.FindIndex(body); end != nil {
+			body = body[:end[0]]
+		}
+		if input.Match(body) || f.hasMarker(body) {
+			continue
+		}
+
+		issue := FormIssue{Line: bytes.Count(src[:loc[0]], []byte("\n")) + 1}
+		if m := formAction.FindSubmatch(tag); m != nil {
+			issue.Action = string(bytes.Join(m[1:], nil))
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func (f *FormAudit) hasMarker(body []byte) bool {
+	for _, m := range f.Markers {
+		if bytes.Contains(body, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFiles() runs Check() on every file matching the glob patterns and
+// returns the issues found, keyed by file name.
+func (f *FormAudit) CheckFiles(patterns ...string) (map[string][]FormIssue, error) {
+	found := make(map[string][]FormIssue)
+	for _, pattern := range patterns {
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			if issues := f.Check(src); len(issues) > 0 {
+				found[name] = issues
+			}
+		}
+	}
+	return found, nil
+}
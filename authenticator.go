@@ -1,121 +1,604 @@
 package csrf
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
-	"encoding/binary"
-	"log"
-	"math/big"
-	"math/rand"
-	"sort"
+	"crypto/subtle"
+	"hash"
+	"io"
+	"strings"
 	"time"
+	"unsafe"
+
+	"github.com/foobaz/csrf/internal/core"
 )
 
 // Create an Authenticator with site-specific values
 type Authenticator struct {
 	// Key should be approximately 64 bytes of unguessable data
 	Key []byte
+	// KeyID, if set, is prefixed to every token so validation can pick
+	// the right key without trying each one. It must be a character of
+	// the token alphabet, and lowercase in CaseInsensitive mode.
+	KeyID byte
+	// SecondaryKeys are accepted during validation but never used for new
+	// tokens, so Key can be rotated without invalidating forms already in
+	// flight. Move the old Key here and remove it after twice Lifetime.
+	// Use SetKeys() to rotate while serving requests.
+	SecondaryKeys []SecondaryKey
+	// Keys, if set, supplies keys at run time instead of Key, KeyID and
+	// SecondaryKeys, for secrets held by a KMS or HSM.
+	Keys KeyProvider
 	// Each character of a token supplies 3.02 bits of security.
 	// Recommended values are 12 - 40. The maximum effective length
 	// is 168. Higher values work correctly but do not provide any
 	// additional security.
+	//
+	// The second half of the token is a random salt drawn uniformly from
+	// the 66 character alphabet, 6.04 bits per character, which is as
+	// dense as any encoding of the same alphabet. The first half is the
+	// HMAC. An attacker chooses the salt freely, so only the HMAC half
+	// resists forgery, giving 3.02 bits per character overall. With an
+	// odd TokenLength the extra character goes to the HMAC.
 	TokenLength int
+	// SaltLength, if set, is the length of the salt part instead of
+	// half of TokenLength. A shorter salt leaves more characters for
+	// the HMAC, raising EffectiveBits() at the same TokenLength, but
+	// tokens for a session repeat more often within a window, which
+	// UsedTokens reports as replays. It must be less than TokenLength.
+	SaltLength int
 	// Tokens remain valid for at least Lifetime, and no more
-	// than twice Lifetime. Lower values provide better security,
-	// higher values provide better user experience.
+	// than twice Lifetime with the default AcceptedWindows. Lower
+	// values provide better security, higher values provide better
+	// user experience. Windows are
+	// computed in nanoseconds, so sub-second values work, except in
+	// TOTP mode where the step is at least one second. Lifetime must be
+	// positive and at most MaxLifetime.
 	Lifetime time.Duration
+	// AcceptedWindows is how many windows, counting the current one, a
+	// token is accepted in, so tokens live between AcceptedWindows-1 and
+	// AcceptedWindows times Lifetime. Zero means two. Raise it for
+	// long-lived forms, or set 1 for flows where a token may expire
+	// immediately after issue. It is at most MaxWindows.
+	AcceptedWindows int
+	// EmbedWindow adds a character identifying the issuing window after
+	// the key ID, so validation computes one MAC per key instead of one
+	// per accepted window. Tokens with and without it do not validate
+	// against each other; migrate with a ConfigSet entry per format.
+	EmbedWindow bool
+	// Revocations, if set, is consulted during validation so tokens
+	// bound to a killed session are rejected before they expire.
+	Revocations SessionRevoker
+	// Epochs, if set, supplies an invalidation epoch that is mixed into
+	// every token. Advancing the epoch invalidates all tokens at once.
+	Epochs EpochSource
+	// TOTP selects RFC 6238 counter derivation, whole seconds since T0
+	// divided by Lifetime in seconds, for interoperating with existing
+	// OTP infrastructure. Lifetime should be a whole number of seconds.
+	TOTP bool
+	// T0 is the TOTP start time. The zero value means the Unix epoch.
+	T0 time.Time
+	// Window, if set, replaces the built-in counter derivation for custom
+	// quantization such as windows aligned to calendar boundaries, or
+	// SessionScoped() for tokens that do not expire.
+	Window WindowFunc
+	// Session, if set, normalizes the session binding before it is mixed
+	// into the token, at both generation and validation. Use HashSession
+	// for large or variable session blobs; leave nil for compact IDs.
+	Session SessionNormalizer
+	// Strict rejects session bindings shorter than MinSessionLength, or
+	// empty ones if MinSessionLength is zero. An empty binding would make
+	// a token valid for every caller who also passes an empty session.
+	Strict           bool
+	MinSessionLength int
+	// Denylist, if set, records tokens revoked with Revoke() and is
+	// consulted after a token's MAC has been verified.
+	Denylist Denylist
+	// UsedTokens, if set, makes every token single use: a token that
+	// validated once is rejected with ErrTokenReplayed until it expires.
+	// Use it for high-value actions such as payments, where replay
+	// protection outweighs the cost of a lookup per request.
+	UsedTokens Store
+	// MaxUses, if above one, lets a token validate that many times with
+	// UsedTokens instead of once, for multi-step forms that resubmit the
+	// same token. UsedTokens must then be a CounterStore.
+	MaxUses int
+	// Replays, if set, rejects tokens validated more than its MaxUses
+	// times, approximately but in fixed memory; see ReplayFilter.
+	Replays *ReplayFilter
+	// CaseInsensitive uses a lowercase-only 40 character alphabet and
+	// accepts tokens in any case, for channels that alter case. Each
+	// character then supplies 2.66 bits of security instead of 3.02.
+	CaseInsensitive bool
+	// Alphabet, if set, replaces the token characters, such as
+	// HexAlphabet for systems that choke on punctuation. It must be
+	// sorted, without repeats, and lowercase in CaseInsensitive mode.
+	// Smaller alphabets carry fewer bits per character, so raise
+	// TokenLength to keep EffectiveBits().
+	Alphabet []byte
+	// Grace keeps accepting tokens for up to Grace after they would
+	// otherwise expire, to absorb network latency and clock skew. It
+	// matters most for sub-second Lifetimes and should be shorter than
+	// Lifetime.
+	Grace time.Duration
+	// FutureWindows also accepts tokens from up to FutureWindows windows
+	// after the current one, for fleets with imperfect clock sync, where
+	// a token from a server running ahead would fail on one that lags.
+	// Zero, the default, accepts none, and it is at most MaxWindows.
+	FutureWindows int
+	// Rand, if set, is the source of randomness for salts, such as a
+	// hardware RNG, a DRBG required by policy, or a deterministic reader
+	// in tests. Salts are drawn from crypto/rand if Rand is nil.
+	Rand io.Reader
+	// Pepper is an optional second secret mixed into every MAC. Load it
+	// from a different channel than Key, such as an environment variable
+	// versus a KMS, so compromising one secret store is not enough to
+	// forge tokens.
+	Pepper []byte
+	// MAC, if set, constructs the keyed hash used for tokens instead of
+	// HMAC-SHA-512, such as KMAC256. Tokens made with different MACs do
+	// not validate against each other.
+	MAC func(key []byte) hash.Hash
+	// Hash selects the hash function used with HMAC, such as sha256.New
+	// or sha3.New512 where policy mandates a particular primitive. It
+	// defaults to sha512.New and is ignored if MAC is set. A hash shorter
+	// than 64 bytes caps EffectiveBits() at its output size.
+	Hash func() hash.Hash
+	// Logger receives diagnostics for rejected tokens and failed
+	// generation. If nil, they go to the standard log package; set a
+	// Logger that discards them to silence the package.
+	Logger Logger
+	// Now, if set, replaces time.Now as the clock for the HTTP handlers
+	// and for every method called with a zero date, for deterministic
+	// tests or a clock corrected for skew.
+	Now func() time.Time
+	// Mask makes every generated token a MaskToken() of itself, twice as
+	// long, so tokens repeated across compressed HTTPS responses do not
+	// leak through BREACH. Masked and unmasked tokens both validate.
+	Mask bool
+	// Metrics, if set, counts generated tokens and validation outcomes.
+	Metrics Metrics
+	// Events, if set, is called with an Event for every token validation,
+	// and by Protect() for every unsafe request it checks. It is called
+	// synchronously, so it should hand events off quickly, as
+	// EventChannel() does.
+	Events func(Event)
+	// RequestBinding, if set, additionally binds tokens issued and
+	// checked by Protect() to attributes of the request, such as the
+	// client network and User-Agent. See BindRequest().
+	RequestBinding *RequestBinding
+	// MaxBodyBytes limits how much of a request body
+	// ValidateRequestSignature() reads to hash it, DefaultMaxBodyBytes if
+	// zero. Larger bodies are rejected with ErrBodyTooLarge.
+	MaxBodyBytes int64
+	// Versioned prefixes new tokens with a character from '1' to '4'
+	// naming their format, whether the window is embedded and whether
+	// the token is masked, so they validate whatever the EmbedWindow and
+	// Mask settings of the validator. Validators accept versioned tokens
+	// whether or not Versioned is set, so in a mixed fleet upgrade every
+	// server before setting it, and format changes can then roll out
+	// one server at a time.
+	Versioned bool
+
+	// ownsKeys is set by WithKeyCopy(), closed by Close(), tokenFloor
+	// by WithStrictConfig() and rotation by SetKeys()
+	ownsKeys   bool
+	closed     int32
+	tokenFloor int
+	rotation   unsafe.Pointer // *keyRotation, shared by copies
 }
 
 // Sorted for binary search in ValidateToken()
-var urlSafe = []byte{
+var urlSafe = core.URLSafe
+
+// Sorted like urlSafe, used in CaseInsensitive mode
+var lowerSafe = []byte{
 	'-', '.',
 	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9',
-	'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
-	'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
 	'_',
 	'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm',
 	'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z',
 	'~',
 }
 
+// canonicalToken lowercases tokens in CaseInsensitive mode.
+func (a *Authenticator) canonicalToken(token string) string {
+	if a.CaseInsensitive {
+		return strings.ToLower(token)
+	}
+	return token
+}
+
+func (a *Authenticator) alphabet() []byte {
+	if a.Alphabet != nil {
+		return a.Alphabet
+	}
+	if a.CaseInsensitive {
+		return lowerSafe
+	}
+	return urlSafe
+}
+
 // GenerateToken() creates a new token in the given session. Date should be
 // the current time and session should uniquely identify the user, such as
-// []byte(username) or a session token.
+// []byte(username) or a session token. It returns an empty string, which
+// never validates, if GenerateTokenErr() would return an error.
 func (a *Authenticator) GenerateToken(date time.Time, session []byte) string {
-	saltLength := a.TokenLength / 2
-	randomSalt := make([]byte, saltLength)
-	for i := range randomSalt {
-		randomSalt[i] = urlSafe[rand.Int31n(int32(len(urlSafe)))]
+	token, err := a.GenerateTokenErr(date, session)
+	if err != nil {
+		a.logger().Warn("csrf: token generation failed", "reason", err)
+		return ""
 	}
-
-	counter := date.UnixNano() / int64(a.Lifetime)
-	token := a.generateTokenWithSalt(counter, session, randomSalt)
 	return token
 }
 
-func (a *Authenticator) generateTokenWithSalt(counter int64, session, salt []byte) string {
-	token := a.generateByteTokenWithSalt(counter, session, salt)
+// GenerateTokenErr() is like GenerateToken() but reports why a token could
+// not be generated, such as an empty session in Strict mode.
+func (a *Authenticator) GenerateTokenErr(date time.Time, session []byte) (string, error) {
+	return a.generate(context.Background(), date, session)
+}
+
+func (a *Authenticator) generate(ctx context.Context, date time.Time, session []byte) (string, error) {
+	token, err := a.newToken(ctx, date, session)
+	if err == nil && a.Metrics != nil {
+		a.Metrics.TokenGenerated()
+	}
+	return token, err
+}
+
+func (a *Authenticator) newToken(ctx context.Context, date time.Time, session []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := a.checkLifetime(); err != nil {
+		return "", err
+	}
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+
+	randomSalt, err := a.salt(a.saltLength())
+	if err != nil {
+		return "", err
+	}
+
+	epoch, err := a.epochBytes(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", err
+	}
+	session = a.normalizeSession(session)
+	id, key := a.primaryKey()
+	token := a.generateTokenWithSalt(key, counter, epoch, session, randomSalt)
+	if a.EmbedWindow {
+		id += string(a.windowChar(counter))
+	}
+	if a.Mask {
+		masked, err := a.MaskToken(id + token)
+		if err != nil {
+			return "", err
+		}
+		return a.versionPrefix() + masked, nil
+	}
+	return a.versionPrefix() + id + token, nil
+}
+
+func (a *Authenticator) generateTokenWithSalt(key []byte, counter int64, epoch, session, salt []byte) string {
+	token := a.generateByteTokenWithSalt(key, counter, epoch, session, salt)
 	return string(token)
 }
 
-func (a *Authenticator) generateByteTokenWithSalt(counter int64, session, salt []byte) []byte {
-	var counterBytes [8]byte
-	binary.BigEndian.PutUint64(counterBytes[:], uint64(counter))
+// mac returns the HMAC of the pepper, time window, epoch, session and salt.
+func (a *Authenticator) mac(key []byte, counter int64, epoch, session, salt []byte) []byte {
+	s := a.acquireMAC(key)
+	a.writeMAC(s.h, counter, epoch, session, salt)
+	sum := s.h.Sum(nil)
+	a.releaseMAC(key, s)
+	return sum
+}
 
-	h := hmac.New(sha512.New, a.Key)
-	h.Write(counterBytes[:])
-	h.Write(session)
-	h.Write(salt)
+// writeMAC writes the MAC input to h.
+func (a *Authenticator) writeMAC(h hash.Hash, counter int64, epoch, session, salt []byte) {
+	core.WriteMAC(h, a.Pepper, counter, epoch, session, salt)
+}
 
-	var hashArray [sha512.Size]byte
-	sumBytes := h.Sum(hashArray[:0])
+// newMAC returns the keyed hash selected by MAC and Hash.
+func (a *Authenticator) newMAC(key []byte) hash.Hash {
+	if a.MAC != nil {
+		return a.MAC(key)
+	}
+	if a.Hash != nil {
+		return hmac.New(a.Hash, key)
+	}
+	return hmac.New(sha512.New, key)
+}
 
+func (a *Authenticator) generateByteTokenWithSalt(key []byte, counter int64, epoch, session, salt []byte) []byte {
 	token := make([]byte, a.TokenLength)
-	hashLength := a.TokenLength - len(salt)
+	a.fillToken(token, key, counter, epoch, session, salt)
+	return token
+}
 
-	var sum, base big.Int
-	sum.SetBytes(sumBytes)
-	base.SetUint64(uint64(len(urlSafe)))
-	for i := 0; i < hashLength; i++ {
-		var remainder big.Int
-		sum.QuoRem(&sum, &base, &remainder)
-		remainder.Abs(&remainder)
-		token[i] = urlSafe[remainder.Uint64()]
-	}
+// fillToken writes the token for salt to token, whose length is the
+// token length without key ID.
+func (a *Authenticator) fillToken(token, key []byte, counter int64, epoch, session, salt []byte) {
+	s := a.acquireMAC(key)
+	a.writeMAC(s.h, counter, epoch, session, salt)
+	s.sum = s.h.Sum(s.sum[:0])
 
+	hashLength := len(token) - len(salt)
+	core.Encode(token[:hashLength], s.sum, a.alphabet())
 	copy(token[hashLength:], salt)
-	return token
+	a.releaseMAC(key, s)
+}
+
+// CheckTokenFormat() returns a *MalformedTokenError if the token has the
+// wrong length or contains a character outside the token alphabet. Every
+// character is checked, not just the salt, so garbage input is never
+// mistaken for a MAC mismatch. The whole token is scanned even after an
+// invalid character, in time that depends only on its length.
+func (a *Authenticator) CheckTokenFormat(token string) error {
+	token = a.canonicalToken(token)
+	if len(token) != a.tokenLength() {
+		return &MalformedTokenError{Length: len(token), Offset: -1}
+	}
+	alphabet := a.alphabet()
+	bad := -1
+	for offset := 0; offset < len(token); offset++ {
+		bad = firstInvalid(bad, offset, core.AlphabetIndex(alphabet, token[offset]))
+	}
+	if bad >= 0 {
+		return &MalformedTokenError{Length: len(token), Offset: bad, Char: token[bad]}
+	}
+	return nil
 }
 
 // ValidateToken() returns true if the token is valid for given time and
 // session. Date should be the current time. Session must be the same
 // identifier used when generating the token.
 func (a *Authenticator) ValidateToken(date time.Time, session []byte, token string) bool {
-	if len(token) != a.TokenLength {
-		log.Printf("CheckToken() invalid length: %d", len(token))
+	return a.validToken(context.Background(), date, session, session, token)
+}
+
+// validToken is ValidateToken() for a session bound to raw, logging the
+// reason a token is rejected.
+func (a *Authenticator) validToken(ctx context.Context, date time.Time, raw, session []byte, token string) bool {
+	if _, err := a.validate(ctx, date, raw, session, token); err != nil {
+		a.logger().Warn("csrf: token rejected", "reason", err, "length", len(token))
 		return false
 	}
+	return true
+}
+
+// ValidateTokenErr() is like ValidateToken() but returns the reason a token
+// is rejected instead of logging it, such as ErrWrongLength,
+// ErrInvalidCharacter, ErrExpired or ErrMismatch, so callers can tell an
+// expired form from a forgery. It returns nil for a valid token.
+func (a *Authenticator) ValidateTokenErr(date time.Time, session []byte, token string) error {
+	_, err := a.validate(context.Background(), date, session, session, token)
+	return err
+}
+
+// expiredWindows is how many windows before the accepted ones a token is
+// recognized as expired rather than mismatched.
+const expiredWindows = 8
+
+// mismatch tells an expired token from a forged one by checking a few
+// windows before oldest. It only runs for tokens that already failed, and
+// like verifyToken compares every window, so an expired token takes as
+// long as a forged one.
+func (a *Authenticator) mismatch(keys [][]byte, oldest int64, window byte, epoch, session, salt, tokenBytes []byte) error {
+	expected := make([]byte, len(tokenBytes))
+	expired := 0
+	for _, key := range keys {
+		for c := oldest - 1; c >= oldest-expiredWindows; c-- {
+			if a.EmbedWindow && a.windowChar(c) != window {
+				continue
+			}
+			a.fillToken(expected, key, c, epoch, session, salt)
+			expired |= subtle.ConstantTimeCompare(tokenBytes, expected)
+		}
+	}
+	if expired == 1 {
+		return ErrExpired
+	}
+	return ErrMismatch
+}
+
+// validate checks the token and returns the counter of the window it was
+// generated in, reporting the outcome to Metrics and Events. Session is
+// what the token is bound to, and raw the session of the application it
+// was derived from with bind() or BindRequest(), which is what
+// Revocations and Events know; they are the same for plain tokens.
+func (a *Authenticator) validate(ctx context.Context, date time.Time, raw, session []byte, token string) (int64, error) {
+	counter, err := a.verify(ctx, date, raw, session, token, true)
+	a.report(ctx, date, raw, err)
+	return counter, err
+}
+
+// verify checks the token, and if use is set also the Denylist and
+// UsedTokens, which it records the token in.
+func (a *Authenticator) verify(ctx context.Context, date time.Time, raw, session []byte, token string, use bool) (int64, error) {
+	return a.verifyWith(ctx, date, a.preparer(ctx, date, raw, session), token, use)
+}
+
+// verifyWith is verify() with the session checks made by prepare.
+func (a *Authenticator) verifyWith(ctx context.Context, date time.Time, prepare func() *verification, token string, use bool) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := a.checkLifetime(); err != nil {
+		return 0, err
+	}
+	token = a.canonicalToken(token)
+	if b, body, ok := a.versioned(token); ok {
+		counter, err := b.verifyTokenWith(ctx, date, prepare, body, use)
+		if err == nil || !a.legacyLength(len(token)) {
+			return counter, err
+		}
+	}
+	return a.verifyTokenWith(ctx, date, prepare, token, use)
+}
 
-	tokenBytes := []byte(token)
-	saltLength := len(tokenBytes) / 2
-	hashLength := len(tokenBytes) - saltLength
+// verification is the part of validating a token that depends only on
+// the session and date, made once for all tokens by ValidateTokens().
+type verification struct {
+	session        []byte
+	epoch          []byte
+	newest, oldest int64
+	err            error
+}
+
+// prepare checks the session, and raw against Revocations, and finds the
+// epoch and accepted windows.
+func (a *Authenticator) prepare(ctx context.Context, date time.Time, raw, session []byte) *verification {
+	if err := a.checkSession(session); err != nil {
+		return &verification{err: err}
+	}
+	revoked, err := a.sessionRevoked(ctx, raw)
+	if err != nil {
+		return &verification{err: err}
+	}
+	if revoked {
+		return &verification{err: ErrSessionRevoked}
+	}
+	epoch, err := a.epochBytes(ctx)
+	if err != nil {
+		return &verification{err: err}
+	}
+	newest, oldest, err := a.acceptedRange(date)
+	if err != nil {
+		return &verification{err: err}
+	}
+	return &verification{session: a.normalizeSession(session), epoch: epoch, newest: newest, oldest: oldest}
+}
+
+// preparer returns a function making prepare() on first use, so tokens
+// that are malformed never reach the session backends.
+func (a *Authenticator) preparer(ctx context.Context, date time.Time, raw, session []byte) func() *verification {
+	var v *verification
+	return func() *verification {
+		if v == nil {
+			v = a.prepare(ctx, date, raw, session)
+		}
+		return v
+	}
+}
+
+// verifyToken is verify() for a canonical token without version.
+func (a *Authenticator) verifyToken(ctx context.Context, date time.Time, raw, session []byte, token string, use bool) (int64, error) {
+	return a.verifyTokenWith(ctx, date, a.preparer(ctx, date, raw, session), token, use)
+}
+
+// verifyTokenWith is verifyToken() with the session checks made by
+// prepare.
+func (a *Authenticator) verifyTokenWith(ctx context.Context, date time.Time, prepare func() *verification, token string, use bool) (int64, error) {
+	token, err := a.unmaskToken(token)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.CheckTokenFormat(token); err != nil {
+		return 0, err
+	}
+
+	keys, body, err := a.verificationKeys(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, ErrMismatch
+	}
+	var window byte
+	if a.EmbedWindow {
+		window, body = body[0], body[1:]
+	}
+	tokenBytes := []byte(body)
+	hashLength := len(tokenBytes) - a.saltLength()
 	salt := tokenBytes[hashLength:]
-	for _, c := range salt {
-		i := sort.Search(len(urlSafe), func(i int) bool {
-			return urlSafe[i] >= c
-		})
-		if urlSafe[i] != c {
-			// invalid character
-			log.Printf("CheckToken() invalid character: %c", c)
-			return false
+
+	v := prepare()
+	if v.err != nil {
+		return 0, v.err
+	}
+	session, epoch, newest, oldest := v.session, v.epoch, v.newest, v.oldest
+
+	// every key and window is compared so timing does not reveal which
+	// matched
+	counter, matched := int64(0), 0
+	expected := make([]byte, len(tokenBytes))
+	for _, key := range keys {
+		for c := newest; c >= oldest; c-- {
+			if a.EmbedWindow && a.windowChar(c) != window {
+				continue
+			}
+			a.fillToken(expected, key, c, epoch, session, salt)
+			equal := subtle.ConstantTimeCompare(tokenBytes, expected)
+			// keep the first match without branching on it
+			first := -int64(equal &^ matched)
+			counter ^= (counter ^ c) & first
+			matched |= equal
+		}
+	}
+	if matched == 0 {
+		return 0, a.mismatch(keys, oldest, window, epoch, session, salt, tokenBytes)
+	}
+
+	if use {
+		if err := a.checkUse(ctx, date, counter, token); err != nil {
+			return 0, err
 		}
 	}
+	return counter, nil
+}
 
-	counter := date.UnixNano() / int64(a.Lifetime)
-	token1 := a.generateByteTokenWithSalt(counter, session, salt)
-	token2 := a.generateByteTokenWithSalt(counter - 1, session, salt)
-	match1 := hmac.Equal(tokenBytes, token1)
-	match2 := hmac.Equal(tokenBytes, token2)
-	return match1 || match2
+// acceptedRange returns the newest and oldest windows whose tokens are
+// accepted at date.
+func (a *Authenticator) acceptedRange(date time.Time) (int64, int64, error) {
+	current, err := a.counter(date)
+	if err != nil {
+		return 0, 0, err
+	}
+	newest := current + int64(a.futureWindows())
+	oldest := current - int64(a.acceptedWindows()-1)
+	if a.Grace > 0 {
+		graceCounter, err := a.counter(a.date(date).Add(-a.Grace))
+		if err != nil {
+			return 0, 0, err
+		}
+		if current-graceCounter > MaxWindows {
+			// a Grace of many windows would make validation unbounded
+			graceCounter = current - MaxWindows
+		}
+		oldest = graceCounter - int64(a.acceptedWindows()-1)
+	}
+	return newest, oldest, nil
 }
 
+// checkUse rejects an authentic token from window counter if it is in the
+// Denylist, has been seen too often by Replays or, with UsedTokens, has
+// been used before.
+func (a *Authenticator) checkUse(ctx context.Context, date time.Time, counter int64, token string) error {
+	if a.Denylist != nil {
+		denied, err := a.denied(ctx, token)
+		if err != nil {
+			return err
+		}
+		if denied {
+			return ErrTokenRevoked
+		}
+	}
+	if a.Replays != nil {
+		if err := a.checkReplays(a.date(date), counter, token); err != nil {
+			return err
+		}
+	}
+	if a.UsedTokens != nil {
+		return a.markUsed(ctx, a.date(date), counter, token)
+	}
+	return nil
+}
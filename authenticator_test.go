@@ -0,0 +1,33 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkGenerateToken(b *testing.B) {
+	a := testAuthenticator(b)
+	date := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := a.GenerateTokenErr(date, testSession); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidateToken(b *testing.B) {
+	a := testAuthenticator(b)
+	date := time.Now()
+	token, err := a.GenerateTokenErr(date, testSession)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := a.ValidateTokenErr(date, testSession, token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package csrf
+
+import (
+	"context"
+	"time"
+)
+
+// ValidateTokens() validates many tokens for one session at date, such as
+// the CSRF proofs of queued form submissions, and returns the reason each
+// was rejected, or nil, in the order of tokens. The session is checked
+// against the revokers, and the epoch and accepted windows are found, once
+// for the whole batch rather than per token. Each token is still reported
+// to Metrics and Events and checked against the Denylist, Replays and
+// UsedTokens.
+func (a *Authenticator) ValidateTokens(date time.Time, session []byte, tokens []string) []error {
+	ctx := context.Background()
+	prepare := a.preparer(ctx, date, session, session)
+	errs := make([]error, len(tokens))
+	for i, token := range tokens {
+		_, err := a.verifyWith(ctx, date, prepare, token, true)
+		a.report(ctx, date, session, err)
+		errs[i] = err
+	}
+	return errs
+}
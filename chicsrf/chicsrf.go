@@ -0,0 +1,24 @@
+// Package chicsrf adapts csrf.Protect() to chi routers. chi middleware
+// has the net/http signature, so this is csrf.Wrap() under the name chi
+// users look for, and needs no chi import:
+//
+//	r := chi.NewRouter()
+//	r.Use(chicsrf.Middleware(csrf.WithAuthenticator(a), csrf.WithSession(session)))
+package chicsrf
+
+import (
+	"net/http"
+
+	"github.com/foobaz/csrf"
+)
+
+// Middleware() returns middleware for chi's Router.Use(). It panics on a
+// misconfiguration, as csrf.Protect() does.
+func Middleware(opts ...csrf.Option) func(http.Handler) http.Handler {
+	return csrf.Wrap(opts...)
+}
+
+// Token() returns the token issued for a safe request; see csrf.Token().
+func Token(r *http.Request) string {
+	return csrf.Token(r)
+}
@@ -0,0 +1,154 @@
+package csrf
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const claimsPurpose = "claims"
+
+// MaxClaimsLength is the largest claims payload GenerateClaimToken()
+// accepts, to keep tokens small enough for headers and form fields.
+const MaxClaimsLength = 512
+
+// ErrClaimsLength is returned for claims longer than MaxClaimsLength.
+var ErrClaimsLength = errors.New("csrf: claims too long")
+
+// claimsAEAD returns the AES-256-GCM cipher for claim tokens made with
+// key. Its key is derived from key and Pepper so it is never used
+// directly for encryption.
+func (a *Authenticator) claimsAEAD(key []byte) (cipher.AEAD, error) {
+	h := hmac.New(sha256.New, key)
+	h.Write(a.Pepper)
+	h.Write([]byte(claimsPurpose))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// claimsData returns the additional data authenticated with claims.
+func (a *Authenticator) claimsData(epoch, session []byte) []byte {
+	return bind(claimsPurpose, a.normalizeSession(session), epoch)
+}
+
+// GenerateClaimToken() creates a token carrying claims, such as a user ID
+// or intent, encrypted and authenticated with AES-GCM under a key derived
+// from the Authenticator key. Any service holding the key can validate
+// the token and read the claims without shared session storage. Claim
+// tokens are longer than TokenLength, vary with the claims, and are case
+// sensitive even in CaseInsensitive mode.
+func (a *Authenticator) GenerateClaimToken(date time.Time, session, claims []byte) (string, error) {
+	if err := a.checkLifetime(); err != nil {
+		return "", err
+	}
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	if len(claims) > MaxClaimsLength {
+		return "", ErrClaimsLength
+	}
+	epoch, err := a.epochBytes(context.Background())
+	if err != nil {
+		return "", err
+	}
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", err
+	}
+
+	id, key := a.primaryKey()
+	aead, err := a.claimsAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(a.random(), nonce); err != nil {
+		return "", err
+	}
+	plaintext := make([]byte, 8, 8+len(claims))
+	binary.BigEndian.PutUint64(plaintext, uint64(counter))
+	plaintext = append(plaintext, claims...)
+	sealed := aead.Seal(nonce, nonce, plaintext, a.claimsData(epoch, session))
+	return id + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// ValidateClaimToken() returns the claims of a token made by
+// GenerateClaimToken() for the session, or an error if it was not made
+// for the session, has been tampered with or has expired.
+func (a *Authenticator) ValidateClaimToken(date time.Time, session []byte, token string) ([]byte, error) {
+	ctx := context.Background()
+	if err := a.checkLifetime(); err != nil {
+		return nil, err
+	}
+	if err := a.checkSession(session); err != nil {
+		return nil, err
+	}
+	if id, _ := a.primaryKey(); len(token) < len(id) {
+		return nil, ErrWrongLength
+	}
+	keys, body, err := a.verificationKeys(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := a.sessionRevoked(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrSessionRevoked
+	}
+	epoch, err := a.epochBytes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := a.claimsData(epoch, session)
+	var plaintext []byte
+	for _, key := range keys {
+		aead, err := a.claimsAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		n := aead.NonceSize()
+		if len(sealed) < n+8+aead.Overhead() {
+			return nil, ErrWrongLength
+		}
+		if plaintext, err = aead.Open(nil, sealed[:n], sealed[n:], data); err == nil {
+			break
+		}
+	}
+	if plaintext == nil {
+		return nil, ErrMismatch
+	}
+
+	counter := int64(binary.BigEndian.Uint64(plaintext))
+	newest, oldest, err := a.acceptedRange(date)
+	if err != nil {
+		return nil, err
+	}
+	if counter < oldest {
+		return nil, ErrExpired
+	}
+	if counter > newest {
+		return nil, ErrMismatch
+	}
+	if err := a.checkUse(ctx, date, counter, token); err != nil {
+		return nil, err
+	}
+	return plaintext[8:], nil
+}
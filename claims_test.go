@@ -0,0 +1,105 @@
+package csrf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimToken(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	now := time.Now()
+	for _, claims := range [][]byte{nil, []byte(`{"user":42,"intent":"pay"}`), bytes.Repeat([]byte{0xff}, MaxClaimsLength)} {
+		token, err := a.GenerateClaimToken(now, testSession, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := a.ValidateClaimToken(now, testSession, token)
+		if err != nil || !bytes.Equal(got, claims) {
+			t.Fatalf("ValidateClaimToken() = %q, %v, want %q", got, err, claims)
+		}
+	}
+	if _, err := a.GenerateClaimToken(now, testSession, make([]byte, MaxClaimsLength+1)); !errors.Is(err, ErrClaimsLength) {
+		t.Fatalf("long claims: got %v, want %v", err, ErrClaimsLength)
+	}
+
+	token, err := a.GenerateClaimToken(now, testSession, []byte("claims"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testAuthenticator(t)
+	other.Key = make([]byte, MinKeyLength)
+	foreign, err := other.GenerateClaimToken(now, testSession, []byte("claims"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		date    time.Time
+		session []byte
+		token   string
+		want    error
+	}{
+		{"other session", now, []byte("fedcba9876543210fedcba9876543210"), token, ErrMismatch},
+		{"other key", now, testSession, foreign, ErrMismatch},
+		{"expired", now.Add(3 * a.Lifetime), testSession, token, ErrExpired},
+		{"truncated", now, testSession, token[:len(token)-4], ErrMismatch},
+		{"short", now, testSession, token[:8], ErrWrongLength},
+		{"not base64", now, testSession, token[:len(token)-1] + "!", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := a.ValidateClaimToken(tt.date, tt.session, tt.token)
+			if err == nil {
+				t.Fatalf("validated with claims %q", claims)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// the last character may carry bits base64 drops
+	for i := 0; i < len(token)-1; i++ {
+		b := []byte(token)
+		if b[i] == 'A' {
+			b[i] = 'B'
+		} else {
+			b[i] = 'A'
+		}
+		if claims, err := a.ValidateClaimToken(now, testSession, string(b)); err == nil {
+			t.Fatalf("token changed at %d validated with claims %q", i, claims)
+		}
+	}
+
+	revoke(t, revocations, testSession)
+	if _, err := a.ValidateClaimToken(now, testSession, token); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("revoked session: got %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestClaimTokenRotation(t *testing.T) {
+	a := testAuthenticator(t)
+	now := time.Now()
+	token, err := a.GenerateClaimToken(now, testSession, []byte("claims"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := GenerateKey(MinKeyLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetKeys(key, a.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ValidateClaimToken(now, testSession, token); err != nil {
+		t.Fatalf("token of the secondary key: %v", err)
+	}
+	if err := a.SetKeys(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ValidateClaimToken(now, testSession, token); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("token of a retired key: got %v, want %v", err, ErrMismatch)
+	}
+}
@@ -0,0 +1,217 @@
+// Command csrfgen generates keys and mints or explains tokens, for
+// setting up an Authenticator and debugging reports of rejected tokens.
+//
+// Usage:
+//
+//	csrfgen key [-n bytes] [-base64]
+//	csrfgen mint [flags]
+//	csrfgen explain [flags] token
+//
+// mint and explain take the key as hex in -key or $CSRF_KEY, the session
+// binding in -session or -session-hex, and the time in -time, RFC 3339
+// and now by default. -preset, -length, -lifetime, -windows and -grace
+// must match the server's settings for the results to be meaningful.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/foobaz/csrf"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "key":
+		err = key(os.Args[2:])
+	case "mint":
+		err = mint(os.Args[2:])
+	case "explain":
+		err = explain(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "csrfgen:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: csrfgen key|mint|explain [flags]")
+	os.Exit(2)
+}
+
+// key prints a random key.
+func key(args []string) error {
+	flags := flag.NewFlagSet("key", flag.ExitOnError)
+	n := flags.Int("n", 64, "key length in bytes")
+	b64 := flags.Bool("base64", false, "print base64 instead of hex")
+	flags.Parse(args)
+	k, err := csrf.GenerateKey(*n)
+	if err != nil {
+		return err
+	}
+	if *b64 {
+		fmt.Println(base64.StdEncoding.EncodeToString(k))
+	} else {
+		fmt.Println(hex.EncodeToString(k))
+	}
+	return nil
+}
+
+// settings are the flags shared by mint and explain.
+type settings struct {
+	key, preset, session, sessionHex, date string
+	length, windows                        int
+	lifetime, grace                        time.Duration
+}
+
+func (s *settings) register(flags *flag.FlagSet) {
+	flags.StringVar(&s.key, "key", os.Getenv("CSRF_KEY"), "hex key, $CSRF_KEY by default")
+	flags.StringVar(&s.preset, "preset", "balanced", "strict, balanced or compatible")
+	flags.StringVar(&s.session, "session", "", "session binding")
+	flags.StringVar(&s.sessionHex, "session-hex", "", "session binding in hex")
+	flags.StringVar(&s.date, "time", "", "RFC 3339 time, now by default")
+	flags.IntVar(&s.length, "length", 0, "TokenLength, the preset's by default")
+	flags.IntVar(&s.windows, "windows", 0, "AcceptedWindows, the preset's by default")
+	flags.DurationVar(&s.lifetime, "lifetime", 0, "Lifetime, the preset's by default")
+	flags.DurationVar(&s.grace, "grace", -1, "Grace, the preset's by default")
+}
+
+// authenticator returns the Authenticator, session and time the flags
+// describe.
+func (s *settings) authenticator() (*csrf.Authenticator, []byte, time.Time, error) {
+	k, err := csrf.LoadKeyHex(s.key)
+	if err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("-key: %w", err)
+	}
+	var a *csrf.Authenticator
+	switch s.preset {
+	case "strict":
+		a = csrf.Strict(k)
+	case "balanced":
+		a = csrf.Balanced(k)
+	case "compatible":
+		a = csrf.Compatible(k)
+	default:
+		return nil, nil, time.Time{}, fmt.Errorf("unknown preset %q", s.preset)
+	}
+	if s.length != 0 {
+		a.TokenLength = s.length
+	}
+	if s.lifetime != 0 {
+		a.Lifetime = s.lifetime
+	}
+	if s.windows != 0 {
+		a.AcceptedWindows = s.windows
+	}
+	if s.grace >= 0 {
+		a.Grace = s.grace
+	}
+	if err := a.CheckConfig(); err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	session := []byte(s.session)
+	if s.sessionHex != "" {
+		if session, err = hex.DecodeString(s.sessionHex); err != nil {
+			return nil, nil, time.Time{}, errors.New("-session-hex must be hex")
+		}
+	}
+	date := time.Now()
+	if s.date != "" {
+		if date, err = time.Parse(time.RFC3339, s.date); err != nil {
+			return nil, nil, time.Time{}, err
+		}
+	}
+	return a, session, date, nil
+}
+
+// mint prints a token for the session and time.
+func mint(args []string) error {
+	var s settings
+	flags := flag.NewFlagSet("mint", flag.ExitOnError)
+	s.register(flags)
+	flags.Parse(args)
+	a, session, date, err := s.authenticator()
+	if err != nil {
+		return err
+	}
+	token, err := a.GenerateTokenErr(date, session)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
+
+// maxScan is how many windows back explain looks for a token's window.
+const maxScan = 10000
+
+// explain prints the parts of a token, whether it validates, and which
+// window it was issued in.
+func explain(args []string) error {
+	var s settings
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	s.register(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("explain takes one token")
+	}
+	token := flags.Arg(0)
+	a, session, date, err := s.authenticator()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("length:  %d, expected %d\n", len(token), a.TokenLength)
+	info, err := a.ParseToken(token)
+	if err != nil {
+		fmt.Println("format: ", err)
+		return nil
+	}
+	if info.KeyID != "" {
+		fmt.Println("key ID: ", info.KeyID)
+	}
+	if info.Window != "" {
+		fmt.Println("window: ", info.Window)
+	}
+	fmt.Println("hash:   ", info.Hash)
+	fmt.Println("salt:   ", info.Salt)
+	fmt.Println("masked: ", info.Masked)
+	if err := a.ValidateTokenErr(date, session, token); err != nil {
+		fmt.Println("result: ", err)
+	} else {
+		fmt.Println("result:  valid")
+	}
+
+	// With one accepted window and no grace, a token validates only at
+	// times in the window it was issued in.
+	single := *a
+	single.AcceptedWindows = 1
+	single.Grace = 0
+	for i := -1; i <= maxScan; i++ {
+		at := date.Add(-time.Duration(i) * a.Lifetime)
+		if single.ValidateTokenErr(at, session, token) != nil {
+			continue
+		}
+		if i < 0 {
+			fmt.Println("issued:  in the window after -time; was the issuing clock ahead?")
+		} else {
+			fmt.Printf("issued:  %d windows before %s, around %s\n", i, date.Format(time.RFC3339), at.Format(time.RFC3339))
+		}
+		return nil
+	}
+	fmt.Println("issued:  no matching window; wrong key, session or settings")
+	return nil
+}
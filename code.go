@@ -0,0 +1,200 @@
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const codePurpose = "code"
+
+// CodeAuthenticator issues short digit-only codes for non-browser
+// confirmation flows such as SMS or IVR device pairing. Codes use the key
+// and window machinery of the Authenticator under a separate purpose.
+// Because a six digit code offers only about 20 bits, codes should have a
+// Lifetime of minutes and each session gets at most MaxAttempts wrong
+// guesses per Lifetime.
+type CodeAuthenticator struct {
+	Authenticator *Authenticator
+	// Digits is the code length, 6 if zero
+	Digits int
+	// Lifetime replaces the Authenticator Lifetime
+	Lifetime time.Duration
+	// MaxAttempts limits failed validations per session per Lifetime,
+	// 5 if zero
+	MaxAttempts int
+
+	mutex    sync.Mutex
+	failures map[[sha256.Size]byte]*codeFailures
+	sweepAt  int
+}
+
+type codeFailures struct {
+	count int
+	reset time.Time
+}
+
+// GenerateCode() creates a code for purpose in the session.
+func (c *CodeAuthenticator) GenerateCode(date time.Time, session []byte, purpose string) (string, error) {
+	a := c.authenticator()
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	epoch, err := a.epochBytes(context.Background())
+	if err != nil {
+		return "", err
+	}
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", err
+	}
+	_, key := a.primaryKey()
+	binding := a.normalizeSession(bind(codePurpose, session, []byte(purpose)))
+	return c.code(a.mac(key, counter, epoch, binding, nil)), nil
+}
+
+// ValidateCode() returns true if code was generated for purpose in the
+// session during one of the Authenticator's AcceptedWindows. Once a session has made
+// MaxAttempts failed attempts, every code is rejected until Lifetime has
+// passed. With the Authenticator UsedTokens set, a code validates only
+// once, or MaxUses times; without it, a code can be replayed until it
+// expires.
+func (c *CodeAuthenticator) ValidateCode(date time.Time, session []byte, purpose, code string) bool {
+	a := c.authenticator()
+	date = a.date(date)
+	if err := a.checkSession(session); err != nil {
+		a.logger().Warn("csrf: code rejected", "reason", err)
+		return false
+	}
+	key := sha256.Sum256(session)
+	if !c.reserve(key, date) {
+		a.logger().Warn("csrf: code rejected", "reason", "too many attempts")
+		return false
+	}
+
+	epoch, err := a.epochBytes(context.Background())
+	if err != nil {
+		c.release(key)
+		a.logger().Warn("csrf: code rejected", "reason", err)
+		return false
+	}
+	counter, err := a.counter(date)
+	if err != nil {
+		c.release(key)
+		a.logger().Warn("csrf: code rejected", "reason", err)
+		return false
+	}
+	_, macKey := a.primaryKey()
+	binding := a.normalizeSession(bind(codePurpose, session, []byte(purpose)))
+	var matched []byte
+	var window int64
+	for i := -a.futureWindows(); i < a.acceptedWindows(); i++ {
+		mac := a.mac(macKey, counter-int64(i), epoch, binding, nil)
+		if hmac.Equal([]byte(code), []byte(c.code(mac))) && matched == nil {
+			matched, window = mac, counter-int64(i)
+		}
+	}
+	if matched == nil {
+		return false
+	}
+	if a.UsedTokens != nil {
+		// the MAC names the code, session, purpose and window, where the
+		// few digits of the code alone would collide across sessions
+		err := a.markUsed(context.Background(), date, window, codePurpose+":"+hex.EncodeToString(matched))
+		if err != nil {
+			if !errors.Is(err, ErrTokenReplayed) {
+				c.release(key)
+			}
+			a.logger().Warn("csrf: code rejected", "reason", err)
+			return false
+		}
+	}
+	c.release(key)
+	return true
+}
+
+func (c *CodeAuthenticator) authenticator() *Authenticator {
+	a := c.Authenticator.clone()
+	a.Lifetime = c.Lifetime
+	a.Window = nil
+	return a
+}
+
+// code reduces 63 bits of a MAC, at an offset given by its last byte, to
+// decimal digits. The offset is the low four bits of the byte, reduced
+// for MACs shorter than 24 bytes, such as HMAC with MD5 or SHA-1, so the
+// eight bytes read stay within the MAC.
+func (c *CodeAuthenticator) code(mac []byte) string {
+	digits := c.Digits
+	if digits <= 0 {
+		digits = 6
+	}
+	offset := 0
+	if len(mac) >= 8 {
+		offset = int(mac[len(mac)-1]&0xf) % (len(mac) - 7)
+	}
+	var b [8]byte
+	copy(b[:], mac[offset:])
+	n := binary.BigEndian.Uint64(b[:]) & (1<<63 - 1)
+	s := strconv.FormatUint(n, 10)
+	if len(s) > digits {
+		s = s[len(s)-digits:]
+	}
+	return strings.Repeat("0", digits-len(s)) + s
+}
+
+// reserve counts an attempt of the session with key as failed until
+// release() is called, in the same step as checking the limit, so
+// concurrent guesses cannot all pass the check before any failure is
+// recorded. It returns false if the session has no attempts left.
+func (c *CodeAuthenticator) reserve(key [sha256.Size]byte, date time.Time) bool {
+	max := c.MaxAttempts
+	if max <= 0 {
+		max = 5
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.failures == nil {
+		c.failures = make(map[[sha256.Size]byte]*codeFailures)
+	}
+	if len(c.failures) >= c.sweepAt {
+		// expired entries are swept only as the map doubles, so each
+		// attempt costs constant time on average
+		for k, f := range c.failures {
+			if !date.Before(f.reset) {
+				delete(c.failures, k)
+			}
+		}
+		c.sweepAt = 2*len(c.failures) + 64
+	}
+	f, ok := c.failures[key]
+	if ok && !date.Before(f.reset) {
+		ok = false
+	}
+	if !ok {
+		f = &codeFailures{reset: date.Add(c.Lifetime)}
+		c.failures[key] = f
+	}
+	if f.count >= max {
+		return false
+	}
+	f.count++
+	return true
+}
+
+// release returns an attempt reserved by reserve() that did not fail.
+func (c *CodeAuthenticator) release(key [sha256.Size]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if f, ok := c.failures[key]; ok && f.count > 0 {
+		f.count--
+	}
+}
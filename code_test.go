@@ -0,0 +1,63 @@
+package csrf
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCodeShortHash(t *testing.T) {
+	for name, h := range map[string]func() hash.Hash{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := testAuthenticator(t)
+			a.Hash = h
+			c := &CodeAuthenticator{Authenticator: a, Lifetime: time.Minute}
+			now := time.Now()
+			// every offset a last byte can give is reached within a few
+			// hundred purposes
+			for i := 0; i < 256; i++ {
+				purpose := strconv.Itoa(i)
+				code, err := c.GenerateCode(now, testSession, purpose)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !c.ValidateCode(now, testSession, purpose, code) {
+					t.Fatalf("code for %q rejected", purpose)
+				}
+			}
+		})
+	}
+}
+
+func TestCodeSingleUse(t *testing.T) {
+	a := testAuthenticator(t)
+	a.UsedTokens = &MemoryStore{}
+	c := &CodeAuthenticator{Authenticator: a, Lifetime: time.Minute}
+	now := time.Now()
+	code, err := c.GenerateCode(now, testSession, "pair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ValidateCode(now, testSession, "pair", code) {
+		t.Fatal("first use rejected")
+	}
+	if c.ValidateCode(now, testSession, "pair", code) {
+		t.Fatal("replayed code accepted")
+	}
+	other := []byte("fedcba9876543210fedcba9876543210")
+	otherCode, err := c.GenerateCode(now, other, "pair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ValidateCode(now, other, "pair", otherCode) {
+		t.Fatal("code of another session rejected")
+	}
+}
@@ -0,0 +1,88 @@
+package csrf
+
+import (
+	"sync"
+	"time"
+)
+
+// ConfigSet validates tokens made by any of several active Authenticator
+// configurations, so a rolling deploy that changes TokenLength or Key does
+// not make half the fleet reject tokens issued by the other half. Each
+// token is prefixed with the one-character ID of the configuration that
+// generated it. IDs must be characters that can appear in a token.
+type ConfigSet struct {
+	// Current is the ID of the configuration used for new tokens
+	Current byte
+	// Configs holds every configuration accepted during validation
+	Configs map[byte]*Authenticator
+	// Expires optionally sets when tokens of an older configuration stop
+	// being accepted. Keep it at least one Lifetime after the switch.
+	Expires map[byte]time.Time
+
+	mutex  sync.Mutex
+	counts map[byte]uint64
+}
+
+// GenerateToken() creates a new token with the Current configuration.
+func (s *ConfigSet) GenerateToken(date time.Time, session []byte) string {
+	a, ok := s.Configs[s.Current]
+	if !ok {
+		s.logger().Warn("csrf: token generation failed", "reason", "unknown config", "config", string(s.Current))
+		return ""
+	}
+	token := a.GenerateToken(date, session)
+	if token == "" {
+		return ""
+	}
+	return string(s.Current) + token
+}
+
+// ValidateToken() returns true if the token is valid for the configuration
+// named by its prefix.
+func (s *ConfigSet) ValidateToken(date time.Time, session []byte, token string) bool {
+	if len(token) == 0 {
+		s.logger().Warn("csrf: token rejected", "reason", "missing config version")
+		return false
+	}
+	id := token[0]
+	a, ok := s.Configs[id]
+	if !ok {
+		s.logger().Warn("csrf: token rejected", "reason", "unknown config", "config", string(id))
+		return false
+	}
+	if expires, ok := s.Expires[id]; ok && id != s.Current && !a.date(date).Before(expires) {
+		a.logger().Warn("csrf: token rejected", "reason", "retired config", "config", string(id))
+		return false
+	}
+	if !a.ValidateToken(date, session, token[1:]) {
+		return false
+	}
+
+	s.mutex.Lock()
+	if s.counts == nil {
+		s.counts = make(map[byte]uint64)
+	}
+	s.counts[id]++
+	s.mutex.Unlock()
+	return true
+}
+
+// Counts() returns how many tokens of each configuration have validated,
+// showing how much traffic still uses an old configuration.
+func (s *ConfigSet) Counts() map[byte]uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	counts := make(map[byte]uint64, len(s.counts))
+	for id, n := range s.counts {
+		counts[id] = n
+	}
+	return counts
+}
+
+// logger returns the Logger of the Current configuration.
+func (s *ConfigSet) logger() Logger {
+	if a, ok := s.Configs[s.Current]; ok {
+		return a.logger()
+	}
+	return stdLogger{}
+}
@@ -0,0 +1,71 @@
+package csrf
+
+import (
+	"context"
+	"time"
+)
+
+const confirmPurpose = "confirm"
+
+// Confirmer issues short-lived confirmation tokens for destructive actions
+// that require a recent re-authentication ("sudo mode"). Issue one after
+// the user re-enters their password and require it, in addition to the
+// normal CSRF token, on the protected request. Confirmation tokens use a
+// separate purpose, so they are not interchangeable with CSRF tokens.
+type Confirmer struct {
+	// Authenticator supplies the key and token settings
+	Authenticator *Authenticator
+	// Lifetime replaces the Authenticator Lifetime, typically a few
+	// minutes
+	Lifetime time.Duration
+	// OneTime makes each token single use: checking and recording it is
+	// one atomic step in UsedTokens, so concurrent submissions of the
+	// same token cannot both succeed.
+	OneTime bool
+	// UsedTokens records used tokens in OneTime mode. If nil, the
+	// Authenticator UsedTokens is used or, without one, a MemoryStore
+	// private to the Confirmer, which only covers a single server.
+	UsedTokens Store
+
+	used MemoryStore
+}
+
+func (c *Confirmer) authenticator() *Authenticator {
+	a := c.Authenticator.clone()
+	a.Lifetime = c.Lifetime
+	a.Window = nil
+	if c.OneTime {
+		a.MaxUses = 0
+		if c.UsedTokens != nil {
+			a.UsedTokens = c.UsedTokens
+		} else if a.UsedTokens == nil {
+			a.UsedTokens = &c.used
+		}
+	}
+	return a
+}
+
+// GenerateToken() creates a confirmation token for action in the session.
+func (c *Confirmer) GenerateToken(date time.Time, session []byte, action string) (string, error) {
+	if err := c.Authenticator.checkSession(session); err != nil {
+		return "", err
+	}
+	a := c.authenticator()
+	return a.GenerateTokenErr(date, bind(confirmPurpose, session, []byte(action)))
+}
+
+// ValidateToken() returns true if the token confirms action in the session
+// and has not expired or, in OneTime mode, been used already.
+func (c *Confirmer) ValidateToken(date time.Time, session []byte, action, token string) bool {
+	return c.validToken(date, session, session, action, token)
+}
+
+// validToken is ValidateToken() for a session bound to raw.
+func (c *Confirmer) validToken(date time.Time, raw, session []byte, action, token string) bool {
+	if err := c.Authenticator.checkSession(session); err != nil {
+		c.Authenticator.logger().Warn("csrf: confirmation rejected", "reason", err)
+		return false
+	}
+	a := c.authenticator()
+	return a.validToken(context.Background(), date, raw, bind(confirmPurpose, session, []byte(action)), token)
+}
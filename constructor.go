@@ -0,0 +1,70 @@
+package csrf
+
+import "time"
+
+const (
+	// MinKeyLength is the shortest Key accepted by NewAuthenticator().
+	MinKeyLength = 32
+	// MinTokenLength and MaxTokenLength bound the TokenLength accepted
+	// by NewAuthenticator(). Longer tokens exceed the HMAC-SHA-512 output
+	// and add no security.
+	MinTokenLength = 12
+	MaxTokenLength = 168
+)
+
+// AuthenticatorOption configures an Authenticator made by
+// NewAuthenticator(). Any func(*Authenticator) works, for fields without
+// a With function.
+type AuthenticatorOption func(*Authenticator)
+
+// WithTokenLength() sets the TokenLength.
+func WithTokenLength(n int) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.TokenLength = n
+	}
+}
+
+// WithAlphabet() sets the Alphabet, for example to HexAlphabet.
+func WithAlphabet(alphabet string) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.Alphabet = []byte(alphabet)
+	}
+}
+
+// WithLifetime() sets the Lifetime.
+func WithLifetime(d time.Duration) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.Lifetime = d
+	}
+}
+
+// NewAuthenticator() returns a Balanced() Authenticator for key with opts
+// applied, or an error if the result is misconfigured: a key shorter than
+// MinKeyLength, a TokenLength outside MinTokenLength to MaxTokenLength,
+// anything CheckConfig() rejects, or with WithStrictConfig() a TokenLength
+// below its floor. Use it instead of a struct literal to catch mistakes at
+// startup rather than on the first request.
+func NewAuthenticator(key []byte, opts ...AuthenticatorOption) (*Authenticator, error) {
+	a := Balanced(key)
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.Keys == nil && len(a.Key) < MinKeyLength {
+		return nil, ErrKeyLength
+	}
+	if a.TokenLength < MinTokenLength || a.TokenLength > MaxTokenLength {
+		return nil, ErrTokenLength
+	}
+	if err := a.CheckConfig(); err != nil {
+		return nil, err
+	}
+	if a.tokenFloor > 0 {
+		if err := a.checkStrict(); err != nil {
+			return nil, err
+		}
+	}
+	if a.ownsKeys {
+		a.copyKeys()
+	}
+	return a, nil
+}
@@ -0,0 +1,42 @@
+package csrf
+
+import (
+	"context"
+	"time"
+)
+
+// GenerateTokenCtx() is like GenerateTokenErr() but passes ctx to an
+// EpochSource implementing EpochSourceContext, and fails if ctx is done.
+func (a *Authenticator) GenerateTokenCtx(ctx context.Context, date time.Time, session []byte) (string, error) {
+	return a.generate(ctx, date, session)
+}
+
+// ValidateTokenCtx() is like ValidateToken() but passes ctx to session
+// revokers, epoch sources, key providers, denylists and UsedTokens
+// stores that accept a context, so slow backends respect cancellation
+// and deadlines, and returns the reason a token was rejected instead of
+// logging it.
+func (a *Authenticator) ValidateTokenCtx(ctx context.Context, date time.Time, session []byte, token string) error {
+	_, err := a.validate(ctx, date, session, session, token)
+	return err
+}
+
+// ValidateTokenTTL() is like ValidateTokenErr() but also returns how long
+// the token remains valid, so clients can refresh it before it expires.
+func (a *Authenticator) ValidateTokenTTL(date time.Time, session []byte, token string) (time.Duration, error) {
+	counter, err := a.validate(context.Background(), date, session, session, token)
+	if err != nil {
+		return 0, err
+	}
+	date = a.date(date)
+	expiry, err := a.expiry(date, counter)
+	if err != nil {
+		return 0, err
+	}
+	return expiry.Sub(date), nil
+}
+
+// RevokeCtx() is like Revoke() but honors ctx.
+func (a *Authenticator) RevokeCtx(ctx context.Context, date time.Time, session []byte, token string) error {
+	return a.revoke(ctx, date, session, token)
+}
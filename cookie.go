@@ -0,0 +1,88 @@
+package csrf
+
+import "net/http"
+
+// CookieOption adjusts the cookie the middleware sets in double-submit
+// mode, starting from the DoubleSubmit Cookie template or its defaults.
+type CookieOption func(c *http.Cookie)
+
+// WithCookie() applies opts to the double-submit cookie. It requires
+// WithDoubleSubmit(), whose DoubleSubmit is copied rather than modified.
+func WithCookie(opts ...CookieOption) Option {
+	return func(m *Middleware) {
+		m.cookieOptions = append(m.cookieOptions, opts...)
+	}
+}
+
+// CookieName() sets the cookie name. A "__Host-" prefix makes browsers
+// insist on Secure, Path "/" and no Domain.
+func CookieName(name string) CookieOption {
+	return func(c *http.Cookie) {
+		c.Name = name
+	}
+}
+
+// CookiePath() sets the cookie Path.
+func CookiePath(path string) CookieOption {
+	return func(c *http.Cookie) {
+		c.Path = path
+	}
+}
+
+// CookieDomain() sets the cookie Domain, to share it with subdomains.
+func CookieDomain(domain string) CookieOption {
+	return func(c *http.Cookie) {
+		c.Domain = domain
+	}
+}
+
+// CookieSecure() sets whether the cookie is only sent over HTTPS. Turn it
+// off only for local development over plain HTTP.
+func CookieSecure(secure bool) CookieOption {
+	return func(c *http.Cookie) {
+		c.Secure = secure
+	}
+}
+
+// CookieHTTPOnly() sets whether the cookie is hidden from JavaScript.
+func CookieHTTPOnly(httpOnly bool) CookieOption {
+	return func(c *http.Cookie) {
+		c.HttpOnly = httpOnly
+	}
+}
+
+// CookieSameSite() sets the cookie SameSite attribute.
+func CookieSameSite(sameSite http.SameSite) CookieOption {
+	return func(c *http.Cookie) {
+		c.SameSite = sameSite
+	}
+}
+
+// CookieMaxAge() sets the cookie Max-Age in seconds. Zero leaves a session
+// cookie, and negative values delete the cookie.
+func CookieMaxAge(seconds int) CookieOption {
+	return func(c *http.Cookie) {
+		c.MaxAge = seconds
+	}
+}
+
+// applyCookieOptions gives the middleware its own DoubleSubmit with the
+// cookie options applied, after those of a preset.
+func (m *Middleware) applyCookieOptions() {
+	if len(m.cookieOptions) == 0 && (len(m.presetCookie) == 0 || m.DoubleSubmit == nil) {
+		return
+	}
+	if m.DoubleSubmit == nil {
+		panic("csrf: WithCookie() requires WithDoubleSubmit()")
+	}
+	d := *m.DoubleSubmit
+	c := d.NewCookie("")
+	for _, opt := range m.presetCookie {
+		opt(c)
+	}
+	for _, opt := range m.cookieOptions {
+		opt(c)
+	}
+	d.Cookie = *c
+	m.DoubleSubmit = &d
+}
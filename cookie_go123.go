@@ -0,0 +1,14 @@
+//go:build go1.23
+
+package csrf
+
+import "net/http"
+
+// CookiePartitioned() sets the Partitioned attribute (CHIPS), so the
+// cookie works when the site is embedded cross-site in an iframe. It
+// requires Secure.
+func CookiePartitioned(partitioned bool) CookieOption {
+	return func(c *http.Cookie) {
+		c.Partitioned = partitioned
+	}
+}
@@ -0,0 +1,109 @@
+package csrf
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS answers the CORS requests of the TrustedOrigins of an
+// OriginChecker, so the origins allowed to call the site from scripts and
+// those allowed past the CSRF origin check are one list. Enable it with
+// WithCORS(). Responses allow credentials, since a session cookie is what
+// CSRF tokens protect; trust only origins that may act for the user.
+type CORS struct {
+	// Origins are the trusted origins. Protect() also checks unsafe
+	// requests against it, unless WithOriginChecker() sets another
+	// OriginChecker.
+	Origins *OriginChecker
+	// AllowedMethods are the methods preflights are allowed, by default
+	// GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers preflights are allowed in
+	// addition to Content-Type and the TokenHeaders of the middleware.
+	AllowedHeaders []string
+	// MaxAge is how long browsers may cache a preflight, their own
+	// default if zero.
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// WithCORS() answers the preflights of the origins trusted by c with 204
+// No Content, and lets those origins read responses and the token header.
+// Requests of other origins pass without CORS headers, so browsers keep
+// their responses from the calling script. It panics if c has no
+// Origins.
+func WithCORS(c *CORS) Option {
+	return func(m *Middleware) {
+		m.CORS = c
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight, which browsers send
+// without credentials and so without a session.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// serve adds the CORS headers for the origin of r to w, and answers r if
+// it is a preflight of a trusted origin, reporting whether it did.
+func (c *CORS) serve(w http.ResponseWriter, r *http.Request, m *Middleware) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if !c.Origins.trusts(r, origin) {
+		return false
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Credentials", "true")
+	if !isPreflight(r) {
+		h.Set("Access-Control-Expose-Headers", strings.Join(m.exposedHeaders(), ", "))
+		return false
+	}
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := append([]string{"Content-Type"}, m.headers()...)
+	headers = append(headers, c.AllowedHeaders...)
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge/time.Second), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// exposedHeaders are the response headers of the middleware scripts of
+// trusted origins may read.
+func (m *Middleware) exposedHeaders() []string {
+	headers := []string{m.headers()[0]}
+	if m.ExpiryHeader != "" {
+		headers = append(headers, m.ExpiryHeader)
+	}
+	if m.Debug {
+		headers = append(headers, debugHeaders...)
+	}
+	return headers
+}
+
+// checkCORS panics if CORS has no Origins, and otherwise makes them the
+// Origins of the middleware if it has none.
+func (m *Middleware) checkCORS() {
+	if m.CORS.Origins == nil {
+		panic("csrf: WithCORS() requires Origins")
+	}
+	if m.Origins == nil {
+		m.Origins = m.CORS.Origins
+	}
+}
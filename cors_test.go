@@ -0,0 +1,83 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	a := testAuthenticator(t)
+	sessions := 0
+	reached := false
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }),
+		WithAuthenticator(a),
+		WithSession(func(*http.Request) ([]byte, error) { sessions++; return testSession, nil }),
+		WithCORS(&CORS{Origins: &OriginChecker{TrustedOrigins: []string{"https://app.example"}}, MaxAge: time.Hour}))
+	token, err := a.GenerateTokenErr(time.Now(), testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		reached, sessions = false, 0
+		r := httptest.NewRequest(method, "https://api.example/transfer", nil)
+		r.Header.Set("Origin", origin)
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		} else {
+			r.Header.Set("X-CSRF-Token", token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(http.MethodOptions, "https://app.example", true)
+	if w.Code != http.StatusNoContent || reached || sessions != 0 {
+		t.Fatalf("trusted preflight: got %d, reached %v, %d session lookups", w.Code, reached, sessions)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Fatalf("trusted preflight: Access-Control-Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-CSRF-Token" {
+		t.Fatalf("trusted preflight: Access-Control-Allow-Headers %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "3600" {
+		t.Fatalf("trusted preflight: Access-Control-Max-Age %q", got)
+	}
+
+	w = send(http.MethodOptions, "https://evil.example", true)
+	if !reached || sessions != 0 || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("untrusted preflight: reached %v, %d session lookups, headers %v", reached, sessions, w.Header())
+	}
+
+	w = send(http.MethodPost, "https://app.example", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Fatalf("trusted request: got %d, headers %v", w.Code, w.Header())
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-CSRF-Token" {
+		t.Fatalf("trusted request: Access-Control-Expose-Headers %q", got)
+	}
+
+	// the CORS origins also gate unsafe requests
+	w = send(http.MethodPost, "https://evil.example", false)
+	if w.Code != http.StatusForbidden || reached {
+		t.Fatalf("untrusted request with a valid token: got %d, reached %v", w.Code, reached)
+	}
+}
+
+func TestPreflightWithoutCORS(t *testing.T) {
+	reached := false
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }),
+		WithAuthenticator(testAuthenticator(t)),
+		WithSession(func(*http.Request) ([]byte, error) { return nil, http.ErrNoCookie }))
+	r := httptest.NewRequest(http.MethodOptions, "/transfer", nil)
+	r.Header.Set("Origin", "https://app.example")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !reached || w.Header().Get(TokenHeader) != "" {
+		t.Fatalf("preflight: reached %v, token header %q", reached, w.Header().Get(TokenHeader))
+	}
+}
@@ -0,0 +1,99 @@
+//go:build go1.25
+
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrNoProtection is returned by CrossOriginGuard.AddTrustedOrigin()
+// without a Protection.
+var ErrNoProtection = errors.New("csrf: CrossOriginGuard has no Protection")
+
+// CrossOriginGuard composes net/http's CrossOriginProtection with token
+// validation. The stdlib check runs first and rejects cross-origin
+// browser requests. Requests that carry Sec-Fetch-Site were fully judged
+// by it and pass. Requests without fetch metadata, from older browsers or
+// non-browser clients, must also carry a valid token unless their Origin
+// is trusted.
+type CrossOriginGuard struct {
+	Protection    *http.CrossOriginProtection
+	Authenticator *Authenticator
+	// Session returns the session binding for a request
+	Session func(r *http.Request) ([]byte, error)
+	// Token returns the submitted token, by default the X-CSRF-Token
+	// header or the csrf_token form field; see TokenExtractor()
+	Token func(r *http.Request) string
+
+	mutex   sync.RWMutex
+	trusted map[string]bool
+}
+
+// AddTrustedOrigin() trusts origin in both the stdlib check and the token
+// fallback, so the two never disagree about which origins may bypass.
+func (g *CrossOriginGuard) AddTrustedOrigin(origin string) error {
+	if g.Protection == nil {
+		return ErrNoProtection
+	}
+	if err := g.Protection.AddTrustedOrigin(origin); err != nil {
+		return err
+	}
+	g.mutex.Lock()
+	if g.trusted == nil {
+		g.trusted = make(map[string]bool)
+	}
+	g.trusted[origin] = true
+	g.mutex.Unlock()
+	return nil
+}
+
+// Handler() wraps next with the combined check. It panics if Protection or
+// Authenticator is nil, so a misconfiguration fails at startup.
+func (g *CrossOriginGuard) Handler(next http.Handler) http.Handler {
+	if g.Protection == nil || g.Authenticator == nil {
+		panic("csrf: CrossOriginGuard requires a Protection and an Authenticator")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := g.check(r); err != nil {
+			g.Authenticator.logger().Warn("csrf: request rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *CrossOriginGuard) check(r *http.Request) error {
+	if isSafeMethod(r.Method) {
+		return nil
+	}
+	if g.Protection == nil {
+		return ErrNoProtection
+	}
+	if err := g.Protection.Check(r); err != nil {
+		return err
+	}
+	if r.Header.Get("Sec-Fetch-Site") != "" {
+		return nil
+	}
+	g.mutex.RLock()
+	trusted := g.trusted[r.Header.Get("Origin")]
+	g.mutex.RUnlock()
+	if trusted {
+		return nil
+	}
+
+	raw, session, err := requestSession(g.Authenticator, g.Session, r)
+	if err != nil {
+		return err
+	}
+	extract := requestToken
+	if g.Token != nil {
+		extract = g.Token
+	}
+	token := extract(r)
+	_, err = g.Authenticator.validate(r.Context(), g.Authenticator.now(), raw, session, token)
+	return err
+}
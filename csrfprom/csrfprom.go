@@ -0,0 +1,58 @@
+// Package csrfprom exports csrf.Metrics to Prometheus.
+package csrfprom
+
+import (
+	"github.com/foobaz/csrf"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts CSRF tokens in csrf_tokens_generated_total,
+// csrf_tokens_validated_total and csrf_tokens_rejected_total, the last
+// labelled with the csrf.Reason() for each rejection.
+type Metrics struct {
+	generated prometheus.Counter
+	validated prometheus.Counter
+	rejected  *prometheus.CounterVec
+}
+
+var _ csrf.Metrics = (*Metrics)(nil)
+
+// New() returns Metrics registered with reg. Assign the result to
+// Authenticator.Metrics.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		generated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "csrf_tokens_generated_total",
+			Help: "CSRF tokens generated.",
+		}),
+		validated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "csrf_tokens_validated_total",
+			Help: "CSRF tokens that passed validation.",
+		}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csrf_tokens_rejected_total",
+			Help: "CSRF tokens that failed validation, by reason.",
+		}, []string{"reason"}),
+	}
+	for _, c := range []prometheus.Collector{m.generated, m.validated, m.rejected} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// TokenGenerated() counts a generated token.
+func (m *Metrics) TokenGenerated() {
+	m.generated.Inc()
+}
+
+// TokenValidated() counts a token that passed validation.
+func (m *Metrics) TokenValidated() {
+	m.validated.Inc()
+}
+
+// TokenRejected() counts a token rejected for reason.
+func (m *Metrics) TokenRejected(reason string) {
+	m.rejected.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,91 @@
+// Package csrftest mints tokens for application tests. A Fixture holds an
+// Authenticator with a fixed key and clock, so tests can produce valid,
+// expired and wrong-session tokens without knowing how tokens are built:
+//
+//	f := csrftest.New()
+//	handler := csrf.Protect(app, csrf.WithAuthenticator(f.Authenticator),
+//		csrf.WithSession(func(*http.Request) ([]byte, error) { return f.Session, nil }))
+//	r := f.Request(httptest.NewRequest("POST", "/transfer", body))
+//
+// It must never be used outside tests: its key is public.
+package csrftest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/foobaz/csrf"
+)
+
+// Key is the fixed key of every Fixture.
+var Key = []byte("csrftest key, never use outside tests!")
+
+// Now is the fixed time of every Fixture clock.
+var Now = time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// Session is the session binding Fixture tokens are made for.
+var Session = []byte("csrftest session binding")
+
+// Fixture mints tokens for Session with an Authenticator whose clock
+// is stopped at Now. Change the Authenticator fields to match the
+// application's configuration before minting tokens.
+type Fixture struct {
+	Authenticator *csrf.Authenticator
+	Session       []byte
+}
+
+// New() returns a Fixture with a csrf.NewAuthenticator() Authenticator
+// for Key whose Now clock always returns Now.
+func New(opts ...csrf.AuthenticatorOption) *Fixture {
+	a, err := csrf.NewAuthenticator(Key, opts...)
+	if err != nil {
+		panic("csrftest: " + err.Error())
+	}
+	a.Now = func() time.Time { return Now }
+	return &Fixture{Authenticator: a, Session: Session}
+}
+
+// Valid() returns a token the Authenticator accepts at Now for Session.
+func (f *Fixture) Valid() string {
+	return f.mint(Now, f.Session)
+}
+
+// Expired() returns a token for Session that expired just before Now,
+// counting AcceptedWindows and Grace.
+func (f *Fixture) Expired() string {
+	a := f.Authenticator
+	windows := a.AcceptedWindows
+	if windows == 0 {
+		windows = 2
+	}
+	return f.mint(Now.Add(-time.Duration(windows+1)*a.Lifetime-a.Grace), f.Session)
+}
+
+// WrongSession() returns a token that is valid at Now, but for a session
+// other than Session.
+func (f *Fixture) WrongSession() string {
+	other := append([]byte("other "), f.Session...)
+	return f.mint(Now, other)
+}
+
+// Request() returns r with a Valid() token in the csrf.TokenHeader.
+func (f *Fixture) Request(r *http.Request) *http.Request {
+	return WithToken(r, f.Valid())
+}
+
+// WithToken() returns a copy of r with token in the
+// csrf.TokenHeader, for attaching Expired() and WrongSession() tokens.
+func WithToken(r *http.Request, token string) *http.Request {
+	r = r.Clone(r.Context())
+	r.Header.Set(csrf.TokenHeader, token)
+	return r
+}
+
+// mint generates a token, panicking if the Authenticator is misconfigured.
+func (f *Fixture) mint(date time.Time, session []byte) string {
+	token, err := f.Authenticator.GenerateTokenErr(date, session)
+	if err != nil {
+		panic("csrftest: " + err.Error())
+	}
+	return token
+}
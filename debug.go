@@ -0,0 +1,94 @@
+package csrf
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The headers of WithDebugHeaders().
+const (
+	// DebugDecisionHeader is "issue" for a safe request given a token,
+	// "exempt", "pass", "reject", or "report-only" for a request that
+	// would have been rejected.
+	DebugDecisionHeader = "X-CSRF-Debug-Decision"
+	// DebugReasonHeader is the Reason() a request failed.
+	DebugReasonHeader = "X-CSRF-Debug-Reason"
+	// DebugClauseHeader is the check that decided an unsafe request:
+	// "fetch_metadata", "origin", "session", "double_submit" or "token".
+	DebugClauseHeader = "X-CSRF-Debug-Clause"
+	// DebugSourceHeader is where the token was found, such as "header"
+	// or "form", or "none".
+	DebugSourceHeader = "X-CSRF-Debug-Source"
+	// DebugWindowHeader is how many windows before the current one the
+	// token of a passing request was made in, 0 for the current window.
+	DebugWindowHeader = "X-CSRF-Debug-Window"
+)
+
+var debugHeaders = []string{
+	DebugDecisionHeader, DebugReasonHeader, DebugClauseHeader,
+	DebugSourceHeader, DebugWindowHeader,
+}
+
+// WithDebugHeaders() makes Protect() describe its decision on every
+// request in the X-CSRF-Debug headers, so front end developers can debug
+// an integration without the server logs. They tell an attacker which
+// check to work around: enable it only in development, never in
+// production. Protect() logs a warning when it is on.
+func WithDebugHeaders() Option {
+	return func(m *Middleware) {
+		m.Debug = true
+	}
+}
+
+// debugDecision sets DebugDecisionHeader to decision, with Debug.
+func (m *Middleware) debugDecision(w http.ResponseWriter, decision string) {
+	if m.Debug {
+		w.Header().Set(DebugDecisionHeader, decision)
+	}
+}
+
+// debugCheck sets the debug headers for an unsafe request, which check
+// judged to be from window counter or failing with err, with Debug. It
+// repeats the checks up to the one that failed to name it, which is
+// affordable since it is for development only.
+func (m *Middleware) debugCheck(w http.ResponseWriter, r *http.Request, now time.Time, counter int64, err error) {
+	if !m.Debug {
+		return
+	}
+	h := w.Header()
+	_, source := m.findToken(r)
+	if source == "" {
+		source = "none"
+	}
+	h.Set(DebugSourceHeader, source)
+	clause := "token"
+	if m.DoubleSubmit != nil {
+		clause = "double_submit"
+	}
+	if err == nil {
+		h.Set(DebugDecisionHeader, "pass")
+		h.Set(DebugClauseHeader, clause)
+		if current, cerr := m.Authenticator.counter(now); cerr == nil {
+			h.Set(DebugWindowHeader, strconv.FormatInt(current-counter, 10))
+		}
+		return
+	}
+	switch {
+	case m.FetchMetadata != nil && m.FetchMetadata.Check(r) != nil:
+		clause = "fetch_metadata"
+	case m.Origins != nil && m.Origins.Check(r) != nil:
+		clause = "origin"
+	case m.DoubleSubmit == nil:
+		if _, _, serr := requestSession(m.Authenticator, m.Session, r); serr != nil {
+			clause = "session"
+		}
+	}
+	decision := "reject"
+	if m.ReportOnly() {
+		decision = "report-only"
+	}
+	h.Set(DebugDecisionHeader, decision)
+	h.Set(DebugReasonHeader, Reason(err))
+	h.Set(DebugClauseHeader, clause)
+}
@@ -0,0 +1,117 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDebugHeaders(t *testing.T) {
+	a := testAuthenticator(t)
+	token := a.GenerateToken(a.now(), testSession)
+	session := WithSession(func(r *http.Request) ([]byte, error) {
+		if r.Header.Get("X-Anonymous") != "" {
+			return nil, ErrNoSession
+		}
+		return testSession, nil
+	})
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithAuthenticator(a), session, WithDebugHeaders(), WithExemptPaths("/hooks/"),
+		WithFetchMetadata(&FetchMetadata{}),
+		WithOriginChecker(&OriginChecker{TrustedOrigins: []string{"https://app.example"}, AllowMissing: true}))
+	form := url.Values{TokenField: {token}}.Encode()
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		header  map[string]string
+		body    string
+		want    map[string]string
+		missing []string
+	}{
+		{
+			name:   "safe",
+			method: http.MethodGet,
+			want:   map[string]string{DebugDecisionHeader: "issue"},
+		},
+		{
+			name:   "exempt",
+			method: http.MethodPost,
+			path:   "/hooks/",
+			want:   map[string]string{DebugDecisionHeader: "exempt"},
+		},
+		{
+			name:    "header token",
+			method:  http.MethodPost,
+			header:  map[string]string{TokenHeader: token},
+			want:    map[string]string{DebugDecisionHeader: "pass", DebugSourceHeader: "header", DebugClauseHeader: "token", DebugWindowHeader: "0"},
+			missing: []string{DebugReasonHeader},
+		},
+		{
+			name:   "form token",
+			method: http.MethodPost,
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:   form,
+			want:   map[string]string{DebugDecisionHeader: "pass", DebugSourceHeader: "form"},
+		},
+		{
+			name:    "no token",
+			method:  http.MethodPost,
+			want:    map[string]string{DebugDecisionHeader: "reject", DebugReasonHeader: "missing_token", DebugClauseHeader: "token", DebugSourceHeader: "none"},
+			missing: []string{DebugWindowHeader},
+		},
+		{
+			name:   "cross-site",
+			method: http.MethodPost,
+			header: map[string]string{TokenHeader: token, "Sec-Fetch-Site": "cross-site"},
+			want:   map[string]string{DebugDecisionHeader: "reject", DebugReasonHeader: "cross_site", DebugClauseHeader: "fetch_metadata"},
+		},
+		{
+			name:   "untrusted origin",
+			method: http.MethodPost,
+			header: map[string]string{TokenHeader: token, "Origin": "https://evil.example"},
+			want:   map[string]string{DebugReasonHeader: "untrusted_origin", DebugClauseHeader: "origin"},
+		},
+		{
+			name:   "no session",
+			method: http.MethodPost,
+			header: map[string]string{TokenHeader: token, "X-Anonymous": "1"},
+			want:   map[string]string{DebugDecisionHeader: "reject", DebugClauseHeader: "session"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+			r := httptest.NewRequest(tt.method, path, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			for k, v := range tt.want {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("got %s %q, want %q", k, got, v)
+				}
+			}
+			for _, k := range tt.missing {
+				if got := w.Header().Get(k); got != "" {
+					t.Errorf("got %s %q, want none", k, got)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithAuthenticator(a), session).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	for _, k := range debugHeaders {
+		if got := w.Header().Get(k); got != "" {
+			t.Fatalf("without WithDebugHeaders(): got %s %q", k, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package csrf
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Denylist records individually revoked tokens until they would have
+// expired anyway. Implementations may be shared between servers.
+type Denylist interface {
+	// Deny records token as revoked until expires.
+	Deny(token string, expires time.Time) error
+	// Denied returns true if token has been revoked and not yet expired.
+	Denied(token string) (bool, error)
+}
+
+// DenylistContext is implemented by denylists that can honor
+// cancellation, such as ones backed by a remote store. Its methods are
+// used instead of Deny() and Denied() when available.
+type DenylistContext interface {
+	DenyContext(ctx context.Context, token string, expires time.Time) error
+	DeniedContext(ctx context.Context, token string) (bool, error)
+}
+
+// Revoke() adds a valid token to the Denylist for the rest of its
+// lifetime, for example a confirmation link reported as phishing. It
+// returns the validation error if the token is not currently valid.
+func (a *Authenticator) Revoke(date time.Time, session []byte, token string) error {
+	return a.revoke(context.Background(), date, session, token)
+}
+
+func (a *Authenticator) revoke(ctx context.Context, date time.Time, session []byte, token string) error {
+	if a.Denylist == nil {
+		return ErrNoDenylist
+	}
+	date = a.date(date)
+	counter, err := a.validate(ctx, date, session, session, token)
+	if err != nil {
+		return err
+	}
+
+	expires, err := a.expiry(date, counter)
+	if err != nil {
+		return err
+	}
+	token, err = a.recorded(ctx, date, session, token)
+	if err != nil {
+		return err
+	}
+	if c, ok := a.Denylist.(DenylistContext); ok {
+		return c.DenyContext(ctx, token, expires)
+	}
+	return a.Denylist.Deny(token, expires)
+}
+
+func (a *Authenticator) denied(ctx context.Context, token string) (bool, error) {
+	if c, ok := a.Denylist.(DenylistContext); ok {
+		return c.DeniedContext(ctx, token)
+	}
+	return a.Denylist.Denied(token)
+}
+
+// MemoryDenylist is an in-memory Denylist for a single server. Expired
+// entries are removed as new tokens are denied.
+type MemoryDenylist struct {
+	mutex  sync.Mutex
+	tokens map[string]time.Time
+}
+
+// Deny() records token as revoked until expires.
+func (d *MemoryDenylist) Deny(token string, expires time.Time) error {
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.tokens == nil {
+		d.tokens = make(map[string]time.Time)
+	}
+	for t, e := range d.tokens {
+		if now.After(e) {
+			delete(d.tokens, t)
+		}
+	}
+	d.tokens[token] = expires
+	return nil
+}
+
+// Denied() returns true if token has been revoked and not yet expired.
+func (d *MemoryDenylist) Denied(token string) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	expires, ok := d.tokens[token]
+	return ok && time.Now().Before(expires), nil
+}
@@ -0,0 +1,38 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+)
+
+// hkdfSalt is the HKDF salt for keys derived by DeriveKey(), so they
+// differ from HKDF keys another library derives from the same master.
+const hkdfSalt = "github.com/foobaz/csrf"
+
+// DeriveKey() derives a 64 byte key from master for the purpose named by
+// label, with HKDF-SHA-512 (RFC 5869). Keys for different labels are
+// independent, so one master secret can back CSRF tokens, password reset
+// tokens and other Authenticators without reusing a key. It returns
+// ErrKeyLength for a master shorter than MinKeyLength.
+func DeriveKey(master []byte, label string) ([]byte, error) {
+	if len(master) < MinKeyLength {
+		return nil, ErrKeyLength
+	}
+	extract := hmac.New(sha512.New, []byte(hkdfSalt))
+	extract.Write(master)
+	expand := hmac.New(sha512.New, extract.Sum(nil))
+	expand.Write([]byte(label))
+	expand.Write([]byte{1})
+	return expand.Sum(nil), nil
+}
+
+// NewAuthenticatorFromMaster() is NewAuthenticator() with a key derived
+// from master by DeriveKey() for label, such as "csrf" or
+// "password-reset".
+func NewAuthenticatorFromMaster(master []byte, label string, opts ...AuthenticatorOption) (*Authenticator, error) {
+	key, err := DeriveKey(master, label)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuthenticator(key, opts...)
+}
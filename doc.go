@@ -19,7 +19,8 @@
 // Also, tokens cannot be revoked after being used once.
 //
 // When you generate a token, you pass the current time (from
-// time.Now()) and a byte slice that uniquely identifies the user.
+// time.Now(), or the zero Time to use the Authenticator's Now clock)
+// and a byte slice that uniquely identifies the user.
 // This can be as simple as []byte(username) or if you have a session
 // token you can use that. Include this token in your HTML page as a
 // hidden form field.
@@ -29,7 +30,29 @@
 // the request being made is from the HTML page you generated earlier,
 // and not from a malicious script or link on the user's device.
 //
+// For net/http servers, Protect() wraps a handler with this logic. It
+// generates a token for every GET, HEAD, OPTIONS and TRACE request,
+// available in the X-CSRF-Token response header, and rejects other
+// requests unless they carry a valid token in the X-CSRF-Token header
+// or the csrf_token form field.
+//
+// Handlers get the token from Token(r), and html/template pages can
+// render a hidden form field with TemplateField(r) or the functions in
+// FuncMap().
+//
 // The tokens generated by this package are strings using alphanumeric
 // characters, plus dot, dash, underscore, and tilde. These characters
 // are safe to use in URL query strings, HTML attributes, and cookies.
+//
+// Validation time depends only on public values: the length of the
+// submitted token, the configuration, the number of candidate keys and,
+// with EmbedWindow, the window the token names. Every character is
+// checked, even after an invalid one, and the MAC is compared in
+// constant time against every accepted window and, for a token that
+// fails, every recently expired one. So the timing shows neither where a
+// token diverges from the expected MAC nor which key or window it
+// matched. Tokens of the wrong length or with invalid characters are
+// rejected before any MAC is computed, which reveals only what the
+// sender already knows, and ValidateToken() logs every rejection the
+// same way whatever its reason.
 package csrf
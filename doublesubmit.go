@@ -0,0 +1,169 @@
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"net/http"
+	"time"
+)
+
+// DefaultCookieName is the name of the DoubleSubmit cookie if its Cookie
+// template has none.
+const DefaultCookieName = "csrf"
+
+const doubleSubmitPurpose = "double-submit"
+
+// doubleSubmitNonce is the length of the random identifier each cookie
+// token is bound to in place of a session.
+const doubleSubmitNonce = 22
+
+// DoubleSubmit implements the signed double-submit cookie pattern for
+// applications without server-side sessions. The token is bound to a
+// random nonce and stored in a cookie, and unsafe requests must echo the
+// cookie value in the X-CSRF-Token header or csrf_token form field. A
+// cross-site page can neither read the cookie nor forge a value without
+// the key. A sibling subdomain that can set cookies can still plant a
+// value it obtained from this server, so prefer session-bound tokens
+// where there is a session.
+type DoubleSubmit struct {
+	// Authenticator supplies the key and token settings
+	Authenticator *Authenticator
+	// Cookie is the template for the token cookie; its Value is set by
+	// DoubleSubmit. If Name is empty, a cookie named DefaultCookieName
+	// with Path "/", Secure and SameSite=Lax is used. Leave HttpOnly
+	// unset if JavaScript copies the cookie into the header.
+	Cookie http.Cookie
+	// Extract returns the token submitted to Check(), by default the
+	// X-CSRF-Token header or the csrf_token form field; see
+	// TokenExtractor(). Protect() uses its own token sources instead.
+	Extract func(r *http.Request) string
+}
+
+// GenerateToken() creates a cookie value bound to a fresh nonce.
+func (d *DoubleSubmit) GenerateToken(date time.Time) (string, error) {
+	a := d.Authenticator.plain()
+	nonce, err := a.salt(doubleSubmitNonce)
+	if err != nil {
+		return "", err
+	}
+	token, err := a.GenerateTokenErr(date, bind(doubleSubmitPurpose, nonce))
+	if err != nil {
+		return "", err
+	}
+	return string(nonce) + token, nil
+}
+
+// ValidateToken() returns nil if submitted equals the cookie value and the
+// cookie holds an unexpired value from GenerateToken().
+func (d *DoubleSubmit) ValidateToken(date time.Time, cookie, submitted string) error {
+	_, err := d.validate(context.Background(), date, cookie, submitted)
+	return err
+}
+
+func (d *DoubleSubmit) validate(ctx context.Context, date time.Time, cookie, submitted string) (int64, error) {
+	a := d.Authenticator
+	cookie = a.canonicalToken(cookie)
+	submitted = a.canonicalToken(submitted)
+	if a.Mask && len(submitted) == 2*len(cookie) {
+		var err error
+		if submitted, err = a.unmask(submitted); err != nil {
+			return 0, err
+		}
+	}
+	if !hmac.Equal([]byte(cookie), []byte(submitted)) {
+		return 0, ErrMismatch
+	}
+	if len(cookie) < doubleSubmitNonce {
+		return 0, ErrWrongLength
+	}
+	binding := bind(doubleSubmitPurpose, []byte(cookie[:doubleSubmitNonce]))
+	return a.validate(ctx, date, binding, binding, cookie[doubleSubmitNonce:])
+}
+
+// reusable returns the window of a cookie value that stays valid for
+// another Lifetime. It neither records the value as used nor counts it in
+// Metrics, since it is only looking.
+func (d *DoubleSubmit) reusable(ctx context.Context, date time.Time, cookie string) (int64, error) {
+	a := d.Authenticator
+	cookie = a.canonicalToken(cookie)
+	if len(cookie) < doubleSubmitNonce {
+		return 0, ErrWrongLength
+	}
+	binding := bind(doubleSubmitPurpose, []byte(cookie[:doubleSubmitNonce]))
+	return a.verify(ctx, date.Add(a.Lifetime), binding, binding, cookie[doubleSubmitNonce:], false)
+}
+
+// NewCookie() returns the cookie carrying value, built from the Cookie
+// template.
+func (d *DoubleSubmit) NewCookie(value string) *http.Cookie {
+	c := d.Cookie
+	if c.Name == "" {
+		c = http.Cookie{
+			Name:     DefaultCookieName,
+			Path:     "/",
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		}
+	}
+	c.Value = value
+	return &c
+}
+
+// Token() returns the cookie value to embed in a response to r, masked in
+// Mask mode. The request's cookie is reused while it stays valid for
+// another Lifetime, so pages open in several tabs keep working; otherwise
+// a fresh cookie is set on w.
+func (d *DoubleSubmit) Token(w http.ResponseWriter, r *http.Request, date time.Time) (string, error) {
+	token, _, err := d.token(w, r, date)
+	return token, err
+}
+
+// token is Token() that also returns the window of the token.
+func (d *DoubleSubmit) token(w http.ResponseWriter, r *http.Request, date time.Time) (string, int64, error) {
+	a := d.Authenticator
+	date = a.date(date)
+	if c, err := r.Cookie(d.NewCookie("").Name); err == nil {
+		if counter, err := d.reusable(r.Context(), date, c.Value); err == nil {
+			token, err := d.embed(c.Value)
+			return token, counter, err
+		}
+	}
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", 0, err
+	}
+	value, err := d.GenerateToken(date)
+	if err != nil {
+		return "", 0, err
+	}
+	http.SetCookie(w, d.NewCookie(value))
+	token, err := d.embed(value)
+	return token, counter, err
+}
+
+// embed returns the form of a cookie value placed in responses.
+func (d *DoubleSubmit) embed(value string) (string, error) {
+	if d.Authenticator.Mask {
+		return d.Authenticator.MaskToken(value)
+	}
+	return value, nil
+}
+
+// Check() returns nil if r carries the cookie and a matching token, as
+// read by Token.
+func (d *DoubleSubmit) Check(r *http.Request, date time.Time) error {
+	extract := requestToken
+	if d.Extract != nil {
+		extract = d.Extract
+	}
+	_, err := d.check(r, date, extract(r))
+	return err
+}
+
+func (d *DoubleSubmit) check(r *http.Request, date time.Time, submitted string) (int64, error) {
+	c, err := r.Cookie(d.NewCookie("").Name)
+	if err != nil {
+		return 0, err
+	}
+	return d.validate(r.Context(), date, c.Value, submitted)
+}
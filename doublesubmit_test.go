@@ -0,0 +1,115 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoubleSubmitValidateToken(t *testing.T) {
+	a := testAuthenticator(t)
+	d := &DoubleSubmit{Authenticator: a}
+	now := time.Now()
+	cookie, err := d.GenerateToken(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := d.GenerateToken(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookie == other {
+		t.Fatal("GenerateToken() made the same cookie twice")
+	}
+	if err := d.ValidateToken(now, cookie, cookie); err != nil {
+		t.Fatalf("matching token: %v", err)
+	}
+
+	foreign := &DoubleSubmit{Authenticator: testAuthenticator(t)}
+	foreign.Authenticator.Key = make([]byte, MinKeyLength)
+	forged, err := foreign.GenerateToken(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(cookie)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name              string
+		cookie, submitted string
+		want              error
+	}{
+		{"other cookie", cookie, other, ErrMismatch},
+		{"tampered submission", cookie, string(tampered), ErrMismatch},
+		{"empty submission", cookie, "", ErrMismatch},
+		// a planted cookie echoed in the header still needs the key
+		{"tampered pair", string(tampered), string(tampered), nil},
+		{"other key", forged, forged, nil},
+		{"short cookie", cookie[:10], cookie[:10], ErrWrongLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.ValidateToken(now, tt.cookie, tt.submitted)
+			if err == nil {
+				t.Fatal("ValidateToken() accepted the token")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if err := d.ValidateToken(now.Add(3*a.Lifetime), cookie, cookie); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired cookie: got %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestProtectDoubleSubmit(t *testing.T) {
+	d := &DoubleSubmit{Authenticator: testAuthenticator(t)}
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithDoubleSubmit(d))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCookieName {
+		t.Fatalf("got cookies %v, want one named %q", cookies, DefaultCookieName)
+	}
+	token := w.Header().Get(TokenHeader)
+	if token != cookies[0].Value {
+		t.Fatalf("got token %q for cookie %q", token, cookies[0].Value)
+	}
+	other, err := d.GenerateToken(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		token  string
+		want   int
+	}{
+		{"matching", cookies[0].Value, token, http.StatusOK},
+		{"no cookie", "", token, http.StatusForbidden},
+		{"no token", cookies[0].Value, "", http.StatusForbidden},
+		{"mismatch", cookies[0].Value, other, http.StatusForbidden},
+		{"mismatch with the cookie of the token", other, token, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: tt.cookie})
+			}
+			if tt.token != "" {
+				r.Header.Set(TokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build go1.19
+
+package csrf
+
+// WithEarlyHints() makes Protect() answer page loads, safe requests that
+// accept text/html, with a 103 Early Hints response preloading the token
+// endpoint at path, such as a TokenHandler() mounted at "/csrf-token", so
+// the browser fetches the token while the server renders the page. The
+// page must fetch the same URL with the default same-origin credentials
+// for the preloaded response to be used. The Link header stays in the
+// final response.
+func WithEarlyHints(path string) Option {
+	return func(m *Middleware) {
+		m.EarlyHints = path
+	}
+}
@@ -0,0 +1,56 @@
+//go:build go1.19
+
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+	a := testAuthenticator(t)
+	s := httptest.NewServer(Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithAuthenticator(a), testSessionOption, WithEarlyHints("/csrf-token")))
+	defer s.Close()
+	want := "</csrf-token>; rel=preload; as=fetch; crossorigin"
+	for _, tt := range []struct {
+		accept string
+		hint   bool
+	}{
+		{"text/html,application/xhtml+xml;q=0.9,*/*;q=0.8", true},
+		{"application/json", false},
+	} {
+		var hints []string
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusEarlyHints {
+					hints = append(hints, header.Get("Link"))
+				}
+				return nil
+			},
+		}
+		r, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+		r.Header.Set("Accept", tt.accept)
+		resp, err := s.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d", tt.accept, resp.StatusCode, http.StatusOK)
+		}
+		if tt.hint && (len(hints) != 1 || hints[0] != want) {
+			t.Fatalf("%s: got early hints %q, want %q", tt.accept, hints, want)
+		}
+		if !tt.hint && len(hints) != 0 {
+			t.Fatalf("%s: got early hints %q, want none", tt.accept, hints)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package echocsrf adapts csrf.Protect() to Echo:
+//
+//	e := echo.New()
+//	e.Use(echocsrf.Middleware(csrf.WithAuthenticator(a), csrf.WithSession(session)))
+//
+// Rejected requests are answered by the csrf middleware, with its
+// ErrorHandler if one is set, and do not reach Echo's HTTPErrorHandler.
+package echocsrf
+
+import (
+	"github.com/foobaz/csrf"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware() returns middleware for Echo.Use() with the csrf options.
+// It panics on a misconfiguration, as csrf.Protect() does.
+func Middleware(opts ...csrf.Option) echo.MiddlewareFunc {
+	return echo.WrapMiddleware(csrf.Wrap(opts...))
+}
+
+// Token() returns the token issued for a safe request, or "" if there is
+// none.
+func Token(c echo.Context) string {
+	return csrf.Token(c.Request())
+}
@@ -0,0 +1,120 @@
+// Package edge validates tokens made by a csrf.Authenticator using only
+// small standard packages: no net/http, templates, JSON or reflection. It
+// builds with TinyGo for WebAssembly, so a CDN edge worker can reject
+// forged requests before they reach the origin, which still validates
+// them in full.
+//
+// A Validator accepts the tokens of an Authenticator with the same Key,
+// KeyID, SecondaryKeys, Pepper, TokenLength, SaltLength, Lifetime and
+// AcceptedWindows and the default alphabet and MAC. Tokens from
+// Authenticators using other features, such as Mask, EmbedWindow,
+// versions, epochs, session normalizers or request binding, are rejected.
+// Denylists and single-use stores are left to the origin.
+package edge
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/foobaz/csrf/internal/core"
+)
+
+var (
+	// ErrInvalidToken is returned for a token that is malformed, forged,
+	// expired or for another session
+	ErrInvalidToken = errors.New("edge: invalid token")
+	// ErrEmptySession is returned for an empty session binding
+	ErrEmptySession = errors.New("edge: empty session binding")
+	// ErrConfig is returned by a Validator whose Lifetime, TokenLength
+	// or SaltLength is out of range
+	ErrConfig = errors.New("edge: invalid configuration")
+)
+
+// Validator checks tokens against the configuration of the Authenticator
+// that made them; see the package documentation for the fields that must
+// match.
+type Validator struct {
+	Key           []byte
+	KeyID         byte
+	SecondaryKeys [][]byte
+	Pepper        []byte
+	TokenLength   int
+	// SaltLength defaults to half of TokenLength, as for an
+	// Authenticator
+	SaltLength int
+	Lifetime   time.Duration
+	// AcceptedWindows defaults to two, as for an Authenticator
+	AcceptedWindows int
+	// AllowEmptySession accepts tokens for an empty session binding,
+	// for Authenticators that are not Strict
+	AllowEmptySession bool
+}
+
+func (v *Validator) saltLength() int {
+	if v.SaltLength <= 0 || v.SaltLength >= v.TokenLength {
+		return v.TokenLength / 2
+	}
+	return v.SaltLength
+}
+
+func (v *Validator) acceptedWindows() int {
+	if v.AcceptedWindows <= 0 {
+		return 2
+	}
+	return v.AcceptedWindows
+}
+
+// Validate() returns nil if token is valid at now for session. Every key
+// and window is compared, so timing does not reveal which matched.
+func (v *Validator) Validate(now time.Time, session []byte, token string) error {
+	if v.Lifetime <= 0 || v.TokenLength < 2 {
+		return ErrConfig
+	}
+	if len(session) == 0 && !v.AllowEmptySession {
+		return ErrEmptySession
+	}
+	if v.KeyID != 0 {
+		if len(token) == 0 || token[0] != v.KeyID {
+			return ErrInvalidToken
+		}
+		token = token[1:]
+	}
+	if len(token) != v.TokenLength {
+		return ErrInvalidToken
+	}
+	valid := 1
+	for i := 0; i < len(token); i++ {
+		valid &^= subtle.ConstantTimeEq(int32(core.AlphabetIndex(core.URLSafe, token[i])), -1)
+	}
+	if valid == 0 {
+		return ErrInvalidToken
+	}
+	current, _, ok := core.Window(now, int64(v.Lifetime))
+	if !ok {
+		return ErrInvalidToken
+	}
+
+	tokenBytes := []byte(token)
+	salt := tokenBytes[len(tokenBytes)-v.saltLength():]
+	hashLength := len(tokenBytes) - len(salt)
+	expected := make([]byte, len(tokenBytes))
+	copy(expected[hashLength:], salt)
+	keys := append([][]byte{v.Key}, v.SecondaryKeys...)
+	matched := 0
+	for _, key := range keys {
+		h := hmac.New(sha512.New, key)
+		for c := current; c > current-int64(v.acceptedWindows()); c-- {
+			h.Reset()
+			core.WriteMAC(h, v.Pepper, c, nil, session, salt)
+			core.Encode(expected[:hashLength], h.Sum(nil), core.URLSafe)
+			matched |= subtle.ConstantTimeCompare(tokenBytes, expected)
+		}
+	}
+	if matched == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
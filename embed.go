@@ -0,0 +1,107 @@
+package csrf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const embedPurpose = "embed"
+
+// EmbedHandler is a token endpoint for forms embedded on partner sites.
+//
+// The flow is:
+//
+//  1. The embedded widget requests this handler with credentials, from
+//     its own script or from the partner page. Browsers send the Origin
+//     header of the embedding site.
+//  2. The handler answers only origins listed in AllowedOrigins, with
+//     CORS headers naming that single origin, and returns a JSON object
+//     that can be passed to postMessage() unchanged:
+//     {"type":"csrf-token","token":"...","origin":"https://partner.example"}
+//  3. The widget submits the token with the form. The application checks
+//     it with ValidateEmbedToken() using the Origin header of the
+//     submission, so a token handed to one partner is useless on another.
+type EmbedHandler struct {
+	Authenticator *Authenticator
+	// AllowedOrigins lists partner origins exactly, such as
+	// "https://partner.example". Requests from other origins get 403.
+	AllowedOrigins []string
+	// Session returns the session binding for a request. If nil, tokens
+	// are bound to the origin only.
+	Session func(r *http.Request) ([]byte, error)
+}
+
+type embedResponse struct {
+	Type   string `json:"type"`
+	Token  string `json:"token"`
+	Origin string `json:"origin"`
+}
+
+func (h *EmbedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if !h.allowed(origin) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodGet:
+	default:
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var session []byte
+	if h.Session != nil {
+		var err error
+		session, err = h.Session(r)
+		if err != nil {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+	}
+	token, err := h.Authenticator.GenerateEmbedToken(h.Authenticator.now(), session, origin)
+	if err != nil {
+		h.Authenticator.logger().Warn("csrf: embed token generation failed", "reason", err)
+		http.Error(w, "token unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(embedResponse{Type: "csrf-token", Token: token, Origin: origin})
+}
+
+func (h *EmbedHandler) allowed(origin string) bool {
+	if origin == "" || origin == "null" {
+		return false
+	}
+	for _, o := range h.AllowedOrigins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateEmbedToken() creates a token bound to the session and to the
+// origin of the site embedding the form.
+func (a *Authenticator) GenerateEmbedToken(date time.Time, session []byte, origin string) (string, error) {
+	return a.GenerateTokenErr(date, bind(embedPurpose, session, []byte(origin)))
+}
+
+// ValidateEmbedToken() returns true if the token was issued by an
+// EmbedHandler for this session and origin.
+func (a *Authenticator) ValidateEmbedToken(date time.Time, session []byte, origin, token string) bool {
+	return a.validToken(context.Background(), date, session, bind(embedPurpose, session, []byte(origin)), token)
+}
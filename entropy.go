@@ -0,0 +1,87 @@
+package csrf
+
+import (
+	"math"
+	"time"
+)
+
+// TokenStrength breaks down the security level of a token configuration.
+type TokenStrength struct {
+	// HashBits is the entropy of the MAC part of a token, limited by the
+	// size of the MAC output.
+	HashBits float64
+	// SaltBits is the entropy of the salt. It keeps tokens unique, but
+	// whoever makes a token chooses it, so it adds nothing against
+	// forgery.
+	SaltBits float64
+	// Bits is HashBits less log2 of the windows a token is accepted in,
+	// each of which is another chance to match: the bits an attacker must
+	// guess to forge a token for a known session.
+	Bits float64
+}
+
+// Strength() returns the TokenStrength of tokens of tokenLength characters
+// with saltLength of them salt, as made by an Authenticator with the
+// default alphabet, MAC and windows. A saltLength of zero means the
+// default of half the token.
+func Strength(tokenLength, saltLength int) TokenStrength {
+	a := &Authenticator{TokenLength: tokenLength, SaltLength: saltLength}
+	return a.Strength()
+}
+
+// Strength() returns the TokenStrength of the configured tokens.
+func (a *Authenticator) Strength() TokenStrength {
+	perChar := math.Log2(float64(len(a.alphabet())))
+	saltLength := a.saltLength()
+	s := TokenStrength{
+		HashBits: float64(a.TokenLength-saltLength) * perChar,
+		SaltBits: float64(saltLength) * perChar,
+	}
+	if max := float64(a.newMAC(nil).Size() * 8); s.HashBits > max {
+		s.HashBits = max
+	}
+	s.Bits = s.HashBits - math.Log2(float64(a.acceptedWindows()+a.futureWindows()))
+	if s.Bits < 0 {
+		s.Bits = 0
+	}
+	return s
+}
+
+// Chance() returns the probability that at least one of guesses forged
+// tokens validates.
+func (s TokenStrength) Chance(guesses float64) float64 {
+	if guesses <= 0 {
+		return 0
+	}
+	return -math.Expm1(guesses * math.Log1p(-math.Exp2(-s.Bits)))
+}
+
+// ChanceWithin() returns the probability that an attacker validating rate
+// forged tokens per second forges one within d, such as a year, to check
+// a configuration against a security policy at startup. Rate limiting
+// the failures of a session lowers rate.
+func (s TokenStrength) ChanceWithin(rate float64, d time.Duration) float64 {
+	return s.Chance(rate * d.Seconds())
+}
+
+// Guesses() returns how many forged tokens an attacker must try to
+// succeed with probability chance.
+func (s TokenStrength) Guesses(chance float64) float64 {
+	if chance <= 0 {
+		return 0
+	}
+	if s.Bits <= 0 {
+		return 1
+	}
+	return math.Log1p(-chance) / math.Log1p(-math.Exp2(-s.Bits))
+}
+
+// EffectiveBits() estimates the security level of the configured tokens:
+// the bits an attacker must guess to forge a token for a known session.
+// Only the hash part counts, since the salt is chosen by whoever makes the
+// token, and the hash part is limited by the size of the MAC output.
+// Each accepted window is another chance to match, so log2 of
+// AcceptedWindows plus FutureWindows is subtracted. It is Strength().Bits.
+func (a *Authenticator) EffectiveBits() float64 {
+	return a.Strength().Bits
+}
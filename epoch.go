@@ -0,0 +1,95 @@
+package csrf
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EpochSource supplies the current invalidation epoch, usually read from a
+// store shared by every server in the fleet. The epoch is mixed into every
+// token, so advancing it invalidates all outstanding tokens at once.
+type EpochSource interface {
+	Epoch() (uint64, error)
+}
+
+// EpochSourceContext is implemented by sources that can honor
+// cancellation. It is used instead of Epoch() by the Ctx methods.
+type EpochSourceContext interface {
+	EpochContext(ctx context.Context) (uint64, error)
+}
+
+// ErrNoEpoch is returned by EpochCache when no epoch is known.
+var ErrNoEpoch = errors.New("csrf: no invalidation epoch available")
+
+// EpochCache wraps an EpochSource so it is not queried on every request.
+// Source is polled when the cached value is older than MaxAge. A pushing
+// source can call SetEpoch() instead, in which case Source may be nil.
+//
+// When Source fails, a FailOpen cache keeps using the last known epoch and
+// a fail-closed cache returns the error, causing tokens to be rejected
+// until the source recovers.
+type EpochCache struct {
+	Source   EpochSource
+	MaxAge   time.Duration
+	FailOpen bool
+
+	mutex   sync.Mutex
+	epoch   uint64
+	known   bool
+	fetched time.Time
+}
+
+// Epoch() returns the cached epoch, refreshing it from Source if needed.
+func (c *EpochCache) Epoch() (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if c.known && (c.Source == nil || now.Sub(c.fetched) < c.MaxAge) {
+		return c.epoch, nil
+	}
+	if c.Source == nil {
+		return 0, ErrNoEpoch
+	}
+
+	epoch, err := c.Source.Epoch()
+	if err != nil {
+		if c.FailOpen && c.known {
+			return c.epoch, nil
+		}
+		return 0, err
+	}
+	c.epoch, c.known, c.fetched = epoch, true, now
+	return epoch, nil
+}
+
+// SetEpoch() updates the cached epoch, for sources that push changes.
+func (c *EpochCache) SetEpoch(epoch uint64) {
+	c.mutex.Lock()
+	c.epoch, c.known, c.fetched = epoch, true, time.Now()
+	c.mutex.Unlock()
+}
+
+// epochBytes returns the MAC input for the current epoch, or nil when no
+// EpochSource is configured so tokens keep their original format.
+func (a *Authenticator) epochBytes(ctx context.Context) ([]byte, error) {
+	if a.Epochs == nil {
+		return nil, nil
+	}
+	var epoch uint64
+	var err error
+	if c, ok := a.Epochs.(EpochSourceContext); ok {
+		epoch, err = c.EpochContext(ctx)
+	} else {
+		epoch, err = a.Epochs.Epoch()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], epoch)
+	return b[:], nil
+}
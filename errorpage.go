@@ -0,0 +1,170 @@
+package csrf
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMessages maps each Reason() to the English message ErrorPage
+// shows for it. Messages for "error" cover reasons without their own.
+var DefaultMessages = map[string]string{
+	"missing_token":     "This form is missing its security token. Reload the page and try again.",
+	"missing_cookie":    "Your browser did not send the cookie this site needs. Enable cookies, reload the page and try again.",
+	"wrong_length":      "This form's security token is invalid. Reload the page and try again.",
+	"invalid_character": "This form's security token is invalid. Reload the page and try again.",
+	"expired":           "This form has expired. Reload the page and try again.",
+	"mismatch":          "This form's security token does not match your session. Reload the page and try again.",
+	"empty_session":     "Your session has ended. Sign in again and retry.",
+	"short_session":     "Your session has ended. Sign in again and retry.",
+	"session_revoked":   "Your session has ended. Sign in again and retry.",
+	"token_revoked":     "This form is no longer valid. Reload the page and try again.",
+	"replayed":          "This form has already been submitted.",
+	"cross_site":        "This request came from another site and was blocked.",
+	"untrusted_origin":  "This request came from another site and was blocked.",
+	"missing_origin":    "Your browser did not say where this request came from, so it was blocked.",
+	"unknown_tenant":    "This site is not configured to accept this request.",
+	"canceled":          "The request was canceled. Try again.",
+	"error":             "This request could not be verified. Reload the page and try again.",
+}
+
+// ErrorPageData is what an ErrorPage Template is executed with.
+type ErrorPageData struct {
+	Request *http.Request
+	// Status is the response status, 403
+	Status int
+	// Reason is the Reason() for the Failure(), and Message the text
+	// for it
+	Reason  string
+	Message string
+}
+
+var defaultErrorTemplate = template.Must(template.New("csrf").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>{{.Status}} Forbidden</title></head>
+<body><h1>Forbidden</h1><p>{{.Message}}</p></body>
+</html>
+`))
+
+// ErrorPage is an ErrorHandler answering failed requests with an HTML page
+// explaining the failure, instead of a bare 403 Forbidden.
+type ErrorPage struct {
+	// Template, if set, renders the page from ErrorPageData instead of a
+	// minimal built-in page
+	Template *template.Template
+	// Message returns the text for a Reason(), for example in the
+	// language of the request; see LocalizedMessages(). If nil, or if it
+	// returns an empty string, DefaultMessages is used.
+	Message func(r *http.Request, reason string) string
+}
+
+// WithErrorPage() answers requests that fail validation with p.
+func WithErrorPage(p *ErrorPage) Option {
+	return WithErrorHandler(p)
+}
+
+// ServeHTTP() renders the page for the Failure() of r with status 403.
+func (p *ErrorPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reason := Reason(Failure(r))
+	data := ErrorPageData{
+		Request: r,
+		Status:  http.StatusForbidden,
+		Reason:  reason,
+		Message: p.message(r, reason),
+	}
+	t := p.Template
+	if t == nil {
+		t = defaultErrorTemplate
+	}
+	var page bytes.Buffer
+	if err := t.Execute(&page, data); err != nil {
+		http.Error(w, "Forbidden - CSRF token invalid", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(page.Bytes())
+}
+
+func (p *ErrorPage) message(r *http.Request, reason string) string {
+	if p.Message != nil {
+		if m := p.Message(r, reason); m != "" {
+			return m
+		}
+	}
+	if m, ok := DefaultMessages[reason]; ok {
+		return m
+	}
+	return DefaultMessages["error"]
+}
+
+// LocalizedMessages() returns an ErrorPage Message function picking from
+// catalog, keyed by language tag such as "de" or "pt-BR" and then by
+// Reason(), in the order of the request's Accept-Language. A tag such as
+// "de-AT" falls back to "de", and a reason missing from every accepted
+// language to that language's "error" message, then to DefaultMessages.
+func LocalizedMessages(catalog map[string]map[string]string) func(r *http.Request, reason string) string {
+	// language tags are case insensitive
+	languages := make(map[string]map[string]string, len(catalog))
+	for tag, messages := range catalog {
+		languages[strings.ToLower(tag)] = messages
+	}
+	return func(r *http.Request, reason string) string {
+		for _, tag := range acceptedLanguages(r) {
+			messages, ok := languages[tag]
+			if !ok {
+				if i := strings.IndexByte(tag, '-'); i > 0 {
+					messages, ok = languages[tag[:i]]
+				}
+			}
+			if !ok {
+				continue
+			}
+			if m, ok := messages[reason]; ok {
+				return m
+			}
+			return messages["error"]
+		}
+		return ""
+	}
+}
+
+// acceptedLanguages returns the tags in the Accept-Language header of r,
+// most preferred first, without those refused with q=0.
+func acceptedLanguages(r *http.Request) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var languages []language
+	for _, value := range r.Header.Values("Accept-Language") {
+		for _, part := range strings.Split(value, ",") {
+			params := strings.Split(part, ";")
+			l := language{tag: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						l.q = q
+					}
+				}
+			}
+			if l.tag != "" && l.tag != "*" && l.q > 0 {
+				languages = append(languages, l)
+			}
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].q > languages[j].q
+	})
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+	return tags
+}
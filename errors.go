@@ -0,0 +1,115 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrEmptySession is returned in Strict mode for an empty session
+	ErrEmptySession = errors.New("csrf: empty session binding")
+	// ErrShortSession is returned in Strict mode for a session shorter
+	// than MinSessionLength
+	ErrShortSession = errors.New("csrf: session binding too short")
+	// ErrInvalidToken matches, with errors.Is(), every error for a token
+	// that is not valid, and is returned when no more specific reason
+	// applies
+	ErrInvalidToken = errors.New("csrf: invalid token")
+	// ErrWrongLength matches a *MalformedTokenError for a token of the
+	// wrong length
+	ErrWrongLength error = &tokenError{"csrf: wrong token length"}
+	// ErrInvalidCharacter matches a *MalformedTokenError for a token with
+	// a character outside the alphabet
+	ErrInvalidCharacter error = &tokenError{"csrf: invalid token character"}
+	// ErrExpired is returned for a token that was valid for the session
+	// in one of the recent windows before the accepted ones
+	ErrExpired error = &tokenError{"csrf: token expired"}
+	// ErrMismatch is returned for a well-formed token that was not
+	// generated for this session, or expired long ago
+	ErrMismatch error = &tokenError{"csrf: token mismatch"}
+	// ErrSessionRevoked is returned for a token bound to a revoked session
+	ErrSessionRevoked error = &tokenError{"csrf: session revoked"}
+	// ErrTokenRevoked is returned for a token in the Denylist
+	ErrTokenRevoked error = &tokenError{"csrf: token revoked"}
+	// ErrTokenReplayed is returned for a token already used once, or
+	// MaxUses times, when UsedTokens is set
+	ErrTokenReplayed error = &tokenError{"csrf: token already used"}
+	// ErrLifetime is returned when Lifetime is not positive or exceeds
+	// MaxLifetime
+	ErrLifetime = errors.New("csrf: lifetime out of range")
+	// ErrWindows is returned by CheckConfig() for AcceptedWindows or
+	// FutureWindows above MaxWindows
+	ErrWindows = errors.New("csrf: window count out of range")
+	// ErrTokenLength is returned when TokenLength is too short, or by
+	// NewAuthenticator() outside MinTokenLength to MaxTokenLength
+	ErrTokenLength = errors.New("csrf: token length out of range")
+	// ErrKeyLength is returned by NewAuthenticator(), GenerateKey() and
+	// the LoadKey functions for a key shorter than MinKeyLength
+	ErrKeyLength = errors.New("csrf: key too short")
+	// ErrSaltLength is returned by CheckConfig() for a negative
+	// SaltLength or one not less than TokenLength
+	ErrSaltLength = errors.New("csrf: salt length out of range")
+	// ErrAlphabet is returned by CheckConfig() for an Alphabet that is
+	// unsorted, repeats a character, has fewer than two, or has uppercase
+	// letters in CaseInsensitive mode
+	ErrAlphabet = errors.New("csrf: invalid alphabet")
+	// ErrDateRange is returned for dates whose window cannot be computed
+	ErrDateRange = errors.New("csrf: date out of range")
+	// ErrClosed is returned by every method of a closed Authenticator
+	ErrClosed = errors.New("csrf: authenticator closed")
+	// ErrNoDenylist is returned by Revoke() without a Denylist
+	ErrNoDenylist = errors.New("csrf: no denylist configured")
+	// ErrKeyProvider is returned by SetKeys() when a KeyProvider supplies
+	// the keys
+	ErrKeyProvider = errors.New("csrf: keys supplied by a KeyProvider")
+	// ErrNoCounterStore is returned when MaxUses is above one but
+	// UsedTokens is not a CounterStore
+	ErrNoCounterStore = errors.New("csrf: store cannot count uses")
+)
+
+// MalformedTokenError reports a token that could never have been generated
+// by the Authenticator, as opposed to a well-formed token that fails the
+// MAC comparison.
+type MalformedTokenError struct {
+	// Length is the length of the rejected token
+	Length int
+	// Offset is the position of the first invalid character, or -1 if
+	// the token has the wrong length
+	Offset int
+	// Char is the invalid character at Offset
+	Char byte
+}
+
+// tokenError is an invalid token reason that matches ErrInvalidToken.
+type tokenError struct {
+	msg string
+}
+
+func (e *tokenError) Error() string {
+	return e.msg
+}
+
+func (e *tokenError) Is(target error) bool {
+	return target == ErrInvalidToken
+}
+
+// Is() makes a MalformedTokenError match ErrWrongLength or
+// ErrInvalidCharacter, and ErrInvalidToken.
+func (e *MalformedTokenError) Is(target error) bool {
+	switch target {
+	case ErrInvalidToken:
+		return true
+	case ErrWrongLength:
+		return e.Offset < 0
+	case ErrInvalidCharacter:
+		return e.Offset >= 0
+	}
+	return false
+}
+
+func (e *MalformedTokenError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("csrf: malformed token: invalid length %d", e.Length)
+	}
+	return fmt.Sprintf("csrf: malformed token: invalid character %q at offset %d", e.Char, e.Offset)
+}
@@ -0,0 +1,98 @@
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const eventPurpose = "event"
+
+// Event records the outcome of validating a token, for feeding CSRF
+// failures into a SIEM without scraping logs.
+type Event struct {
+	// Time is the date the token was validated at
+	Time time.Time
+	// Session is a keyed hash of the session binding, hex encoded. It is
+	// the same on every server with the same Key and Pepper, so events
+	// can be correlated, but cannot be reversed to the session.
+	Session string
+	// Err is why the token was rejected, or nil if it passed, and Reason
+	// is Reason(Err), or empty if it passed
+	Err    error
+	Reason string
+	// SourceIP, Method and Path describe the request, for validations
+	// made by Protect()
+	SourceIP string
+	Method   string
+	Path     string
+}
+
+// EventChannel() returns an Events function sending to ch without
+// blocking, so a slow consumer drops events rather than stall requests.
+func EventChannel(ch chan<- Event) func(Event) {
+	return func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// eventSession returns the Session of an Event for session.
+func (a *Authenticator) eventSession(session []byte) string {
+	_, key := a.primaryKey()
+	h := hmac.New(sha256.New, key)
+	h.Write(a.Pepper)
+	h.Write(bind(eventPurpose, session))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// eventKey marks the context of validations Protect() reports itself.
+const eventKey contextKey = 3
+
+// report counts the outcome of a validation in Metrics and sends it to
+// Events, unless Protect() reports it with the request.
+func (a *Authenticator) report(ctx context.Context, date time.Time, session []byte, err error) {
+	if a.Metrics != nil {
+		if err != nil {
+			a.Metrics.TokenRejected(Reason(err))
+		} else {
+			a.Metrics.TokenValidated()
+		}
+	}
+	if a.Events != nil && ctx.Value(eventKey) == nil {
+		a.Events(a.event(date, session, err))
+	}
+}
+
+func (a *Authenticator) event(date time.Time, session []byte, err error) Event {
+	e := Event{Time: a.date(date), Session: a.eventSession(session), Err: err}
+	if err != nil {
+		e.Reason = Reason(err)
+	}
+	return e
+}
+
+// reportRequest sends the outcome of checking an unsafe request to
+// Events, including rejections that happen before the token is checked.
+func (m *Middleware) reportRequest(r *http.Request, now time.Time, err error) {
+	a := m.Authenticator
+	if a.Events == nil {
+		return
+	}
+	var session []byte
+	if m.Session != nil {
+		session, _ = m.Session(r)
+	}
+	e := a.event(now, session, err)
+	b := a.RequestBinding
+	if b == nil {
+		b = &RequestBinding{}
+	}
+	e.SourceIP, e.Method, e.Path = b.clientIP(r), r.Method, r.URL.Path
+	a.Events(e)
+}
@@ -0,0 +1,53 @@
+package csrf
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SkipFunc reports whether a request is exempt from CSRF checks, for
+// example API clients authenticated by a bearer token rather than a
+// cookie.
+type SkipFunc func(r *http.Request) bool
+
+// WithExemptPaths() exempts unsafe requests whose URL path matches one of
+// patterns, such as webhook endpoints. Patterns use path.Match syntax,
+// and one ending in "/*" also matches everything below it, so
+// "/webhooks/*" covers "/webhooks/github/push".
+func WithExemptPaths(patterns ...string) Option {
+	return func(m *Middleware) {
+		m.ExemptPaths = append(m.ExemptPaths, patterns...)
+	}
+}
+
+// WithSkip() exempts unsafe requests for which skip returns true.
+func WithSkip(skip SkipFunc) Option {
+	return func(m *Middleware) {
+		m.Skip = skip
+	}
+}
+
+// checkPatterns returns an error for the first malformed ExemptPaths
+// pattern.
+func (m *Middleware) checkPatterns() error {
+	for _, pattern := range m.ExemptPaths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exempt reports whether an unsafe request skips the token check.
+func (m *Middleware) exempt(r *http.Request) bool {
+	for _, pattern := range m.ExemptPaths {
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(r.URL.Path, pattern[:len(pattern)-1]) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, r.URL.Path); ok {
+			return true
+		}
+	}
+	return m.Skip != nil && m.Skip(r)
+}
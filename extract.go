@@ -0,0 +1,336 @@
+package csrf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxMultipartMemory is how much of a request body the middleware
+// reads looking for the token field if MaxMultipartMemory is zero.
+const DefaultMaxMultipartMemory = 32 << 20
+
+// maxFieldLength caps how much of a multipart token field is read.
+const maxFieldLength = 4096
+
+// fieldKey holds the configured form field in the request context.
+const fieldKey contextKey = 2
+
+// TokenSource is a place the middleware looks for a submitted token.
+type TokenSource int
+
+const (
+	// FromHeader reads the first non-empty header of TokenHeaders.
+	FromHeader TokenSource = iota
+	// FromForm reads the FormField of an
+	// application/x-www-form-urlencoded body.
+	FromForm
+	// FromMultipart reads the FormField of a multipart/form-data body.
+	FromMultipart
+	// FromJSON reads the JSONField of an application/json body.
+	FromJSON
+	// FromQuery reads the FormField query parameter. URLs end up in logs
+	// and Referer headers, so prefer headers where the client can set
+	// them.
+	FromQuery
+)
+
+// String() returns the name of s, such as "header" or "multipart".
+func (s TokenSource) String() string {
+	switch s {
+	case FromHeader:
+		return "header"
+	case FromForm:
+		return "form"
+	case FromMultipart:
+		return "multipart"
+	case FromJSON:
+		return "json"
+	case FromQuery:
+		return "query"
+	}
+	return "TokenSource(" + strconv.Itoa(int(s)) + ")"
+}
+
+// defaultSources is the lookup order when TokenSources is empty.
+var defaultSources = []TokenSource{FromHeader, FromForm, FromMultipart}
+
+// jsonSources is the lookup order when TokenSources is empty and
+// JSONField is set.
+var jsonSources = []TokenSource{FromHeader, FromForm, FromMultipart, FromJSON}
+
+// WithTokenHeaders() sets the request headers tokens are read from, in
+// order, such as X-CSRF-Token and the X-XSRF-TOKEN that Angular sends.
+// Tokens issued on safe requests are set in the first one.
+func WithTokenHeaders(names ...string) Option {
+	return func(m *Middleware) {
+		m.TokenHeaders = names
+	}
+}
+
+// WithTokenSources() sets the order in which the header, form field,
+// multipart field and JSON field are tried. Sources left out are not consulted, so
+// WithTokenSources(FromHeader) suits JSON APIs.
+func WithTokenSources(sources ...TokenSource) Option {
+	return func(m *Middleware) {
+		m.TokenSources = sources
+	}
+}
+
+// WithFormField() sets the form field tokens are read from and
+// TemplateField() renders, in place of csrf_token.
+func WithFormField(name string) Option {
+	return func(m *Middleware) {
+		m.FormField = name
+	}
+}
+
+// WithJSONField() reads tokens from the named member of application/json
+// request bodies, for single page applications that submit JSON rather
+// than forms. A dotted name such as "meta.csrf" reaches into nested
+// objects. The body is put back for the handler to decode. The field is
+// tried after the default sources; with WithTokenSources(), list
+// FromJSON.
+func WithJSONField(name string) Option {
+	return func(m *Middleware) {
+		m.JSONField = name
+	}
+}
+
+// WithStreamingUploads() keeps large uploads out of memory: the token is
+// looked for in the headers and the FormField query parameter before the
+// body, and a multipart body is only read up to its first file, so the
+// token field must come before any files. Upload clients should send the
+// token in a header or the query, letting the request through without
+// touching the body at all.
+func WithStreamingUploads() Option {
+	return func(m *Middleware) {
+		m.StreamUploads = true
+	}
+}
+
+// WithMaxMultipartMemory() limits how many bytes of a form, multipart or
+// JSON body are read looking for the token field.
+func WithMaxMultipartMemory(n int64) Option {
+	return func(m *Middleware) {
+		m.MaxMultipartMemory = n
+	}
+}
+
+// TokenExtractor() returns a func reading the submitted token of a request
+// like Protect() with opts, such as WithTokenHeaders(), WithFormField(),
+// WithJSONField() and WithTokenSources(). Like the middleware, it leaves
+// the body intact for the handler. Use it for checks made outside the
+// middleware, such as CrossOriginGuard.Token and DoubleSubmit.Extract.
+func TokenExtractor(opts ...Option) func(r *http.Request) string {
+	m := &Middleware{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m.requestToken
+}
+
+// formField returns FormField or the default csrf_token.
+func (m *Middleware) formField() string {
+	if m.FormField == "" {
+		return TokenField
+	}
+	return m.FormField
+}
+
+// requestField returns the form field configured for the middleware that
+// issued the token for r.
+func requestField(r *http.Request) string {
+	if field, ok := r.Context().Value(fieldKey).(string); ok {
+		return field
+	}
+	return TokenField
+}
+
+// headers returns TokenHeaders or the default X-CSRF-Token.
+func (m *Middleware) headers() []string {
+	if len(m.TokenHeaders) == 0 {
+		return []string{TokenHeader}
+	}
+	return m.TokenHeaders
+}
+
+// requestToken returns the first token found in the TokenSources, or that
+// of a WebSocket handshake with WebSockets.
+func (m *Middleware) requestToken(r *http.Request) string {
+	token, _ := m.findToken(r)
+	return token
+}
+
+// findToken is requestToken(), also returning where the token was found:
+// the String() of its TokenSource, "websocket", or "" if there is none.
+func (m *Middleware) findToken(r *http.Request) (string, string) {
+	if m.WebSockets && isWebSocketUpgrade(r) {
+		if token := WebSocketToken(r, m.formField()); token != "" {
+			return token, "websocket"
+		}
+		return "", ""
+	}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	for _, source := range m.sources() {
+		switch source {
+		case FromHeader:
+			for _, name := range m.headers() {
+				if token := r.Header.Get(name); token != "" {
+					return token, source.String()
+				}
+			}
+		case FromForm:
+			if mediaType == "application/x-www-form-urlencoded" {
+				if token := m.formToken(r, ""); token != "" {
+					return token, source.String()
+				}
+			}
+		case FromMultipart:
+			if mediaType == "multipart/form-data" && params["boundary"] != "" {
+				if token := m.formToken(r, params["boundary"]); token != "" {
+					return token, source.String()
+				}
+			}
+		case FromQuery:
+			if token := r.URL.Query().Get(m.formField()); token != "" {
+				return token, source.String()
+			}
+		case FromJSON:
+			if m.JSONField != "" && isJSON(mediaType) {
+				if token := m.jsonToken(r); token != "" {
+					return token, source.String()
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// formToken reads the token field from a urlencoded body, or a multipart
+// body if boundary is set. Unlike ParseForm(), it leaves the body intact:
+// the bytes it reads are put back in front of the rest, so handlers can still
+// parse the form or stream uploads. Multipart bodies are read only up to the
+// token field, which should come before any files, and with StreamUploads
+// never past the first file.
+func (m *Middleware) formToken(r *http.Request, boundary string) string {
+	field := m.formField()
+	if r.PostForm != nil || r.MultipartForm != nil {
+		return r.PostFormValue(field)
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	body, rewind := m.peekBody(r)
+	defer rewind()
+
+	if boundary == "" {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return ""
+		}
+		values, _ := url.ParseQuery(string(data))
+		return values.Get(field)
+	}
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FileName() != "" && m.StreamUploads {
+			return ""
+		}
+		if part.FormName() == field && part.FileName() == "" {
+			value, _ := io.ReadAll(io.LimitReader(part, maxFieldLength))
+			return string(value)
+		}
+	}
+}
+
+// sources returns the TokenSources in the order they are tried.
+func (m *Middleware) sources() []TokenSource {
+	sources := m.TokenSources
+	if len(sources) == 0 {
+		sources = defaultSources
+		if m.JSONField != "" {
+			sources = jsonSources
+		}
+	}
+	if m.StreamUploads {
+		sources = bodyLast(sources)
+	}
+	return sources
+}
+
+// bodyLast returns FromHeader and FromQuery, followed by the sources that
+// read the body.
+func bodyLast(sources []TokenSource) []TokenSource {
+	ordered := []TokenSource{FromHeader, FromQuery}
+	for _, source := range sources {
+		if source != FromHeader && source != FromQuery {
+			ordered = append(ordered, source)
+		}
+	}
+	return ordered
+}
+
+// peekBody returns a reader for up to MaxMultipartMemory bytes of the body
+// of r, and a func putting the bytes read back in front of the rest.
+func (m *Middleware) peekBody(r *http.Request) (io.Reader, func()) {
+	limit := m.MaxMultipartMemory
+	if limit <= 0 {
+		limit = DefaultMaxMultipartMemory
+	}
+	var read bytes.Buffer
+	body := io.TeeReader(io.LimitReader(r.Body, limit), &read)
+	return body, func() {
+		r.Body = &rewoundBody{Reader: io.MultiReader(&read, r.Body), Closer: r.Body}
+	}
+}
+
+// isJSON reports whether mediaType is application/json or a +json type
+// such as application/merge-patch+json.
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonToken reads the JSONField string from a JSON object body, leaving
+// the body intact like formToken.
+func (m *Middleware) jsonToken(r *http.Request) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	body, rewind := m.peekBody(r)
+	defer rewind()
+
+	var object map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&object); err != nil {
+		return ""
+	}
+	path := strings.Split(m.JSONField, ".")
+	for _, name := range path[:len(path)-1] {
+		var inner map[string]json.RawMessage
+		if err := json.Unmarshal(object[name], &inner); err != nil {
+			return ""
+		}
+		object = inner
+	}
+	var token string
+	if err := json.Unmarshal(object[path[len(path)-1]], &token); err != nil {
+		return ""
+	}
+	return token
+}
+
+// rewoundBody is a request body with already-read bytes put back.
+type rewoundBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,55 @@
+package csrf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const failureKey contextKey = 1
+
+// WithErrorHandler() sets the handler for requests that fail validation,
+// instead of a plain text 403 Forbidden. It can get the reason from
+// Failure().
+func WithErrorHandler(h http.Handler) Option {
+	return func(m *Middleware) {
+		m.ErrorHandler = h
+	}
+}
+
+// WithJSONErrors() answers requests that fail validation with
+// JSONErrorHandler(), for single page applications that refresh their
+// token on a structured error.
+func WithJSONErrors() Option {
+	return WithErrorHandler(http.HandlerFunc(JSONErrorHandler))
+}
+
+// Failure() returns why Protect() rejected r, or nil outside an
+// ErrorHandler.
+func Failure(r *http.Request) error {
+	err, _ := r.Context().Value(failureKey).(error)
+	return err
+}
+
+// JSONErrorHandler() responds with 403 Forbidden and a body such as
+// {"error":"csrf_token_invalid","reason":"expired"}, where reason is the
+// Reason() for the Failure().
+func JSONErrorHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := json.Marshal(struct {
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}{"csrf_token_invalid", Reason(Failure(r))})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(append(body, '\n'))
+}
+
+// fail answers a request rejected for err.
+func (m *Middleware) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if m.ErrorHandler == nil {
+		http.Error(w, "Forbidden - CSRF token invalid", http.StatusForbidden)
+		return
+	}
+	m.ErrorHandler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), failureKey, err)))
+}
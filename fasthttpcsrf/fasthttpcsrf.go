@@ -0,0 +1,189 @@
+// Package fasthttpcsrf protects fasthttp servers the way csrf.Protect()
+// protects net/http ones: safe requests are issued a token in the
+// X-CSRF-Token response header and Token(), and unsafe requests must
+// carry a valid token in the X-CSRF-Token header or csrf_token form field
+// or they are rejected with 403 Forbidden. Requests are handled natively,
+// without conversion to net/http.
+package fasthttpcsrf
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/foobaz/csrf"
+	"github.com/valyala/fasthttp"
+)
+
+type userKey int
+
+const (
+	tokenKey userKey = iota
+	failureKey
+)
+
+// Option configures Protect().
+type Option func(*handler)
+
+// WithSession() sets the function returning the session binding for a
+// request, such as a hash of the session cookie. Without it, tokens are
+// bound to an empty session, so a token from one session is accepted in
+// any other, and Strict Authenticators reject every request.
+func WithSession(session func(ctx *fasthttp.RequestCtx) ([]byte, error)) Option {
+	return func(h *handler) {
+		h.session = session
+	}
+}
+
+// WithTokenHeader() sets the header tokens are issued in and read from,
+// in place of X-CSRF-Token.
+func WithTokenHeader(name string) Option {
+	return func(h *handler) {
+		h.header = name
+	}
+}
+
+// WithFormField() sets the form field tokens are read from, in place of
+// csrf_token.
+func WithFormField(name string) Option {
+	return func(h *handler) {
+		h.field = name
+	}
+}
+
+// WithSkip() exempts unsafe requests for which skip returns true, such as
+// webhooks authenticated by a signature.
+func WithSkip(skip func(ctx *fasthttp.RequestCtx) bool) Option {
+	return func(h *handler) {
+		h.skip = skip
+	}
+}
+
+// WithErrorHandler() replaces the plain 403 Forbidden response to rejected
+// requests. Failure() returns the reason.
+func WithErrorHandler(errorHandler fasthttp.RequestHandler) Option {
+	return func(h *handler) {
+		h.errorHandler = errorHandler
+	}
+}
+
+// handler passes context.Background() to the Authenticator rather than the
+// RequestCtx, which fasthttp recycles once the handler returns and which
+// only works as a context inside a running server.
+type handler struct {
+	authenticator *csrf.Authenticator
+	next          fasthttp.RequestHandler
+	session       func(ctx *fasthttp.RequestCtx) ([]byte, error)
+	header        string
+	field         string
+	skip          func(ctx *fasthttp.RequestCtx) bool
+	errorHandler  fasthttp.RequestHandler
+}
+
+// Protect() wraps next with CSRF protection using a. It panics if a is
+// nil, so a misconfiguration fails at startup.
+func Protect(next fasthttp.RequestHandler, a *csrf.Authenticator, opts ...Option) fasthttp.RequestHandler {
+	if a == nil {
+		panic("fasthttpcsrf: Protect() requires an Authenticator")
+	}
+	h := &handler{
+		authenticator: a,
+		next:          next,
+		header:        csrf.TokenHeader,
+		field:         csrf.TokenField,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.serve
+}
+
+// Token() returns the token Protect() issued for a safe request, or "" if
+// there is none.
+func Token(ctx *fasthttp.RequestCtx) string {
+	token, _ := ctx.UserValue(tokenKey).(string)
+	return token
+}
+
+// Failure() returns why Protect() rejected the request, for use in a
+// WithErrorHandler() handler.
+func Failure(ctx *fasthttp.RequestCtx) error {
+	err, _ := ctx.UserValue(failureKey).(error)
+	return err
+}
+
+func (h *handler) serve(ctx *fasthttp.RequestCtx) {
+	if isSafeMethod(ctx) {
+		h.issue(ctx)
+		h.next(ctx)
+		return
+	}
+	if h.skip != nil && h.skip(ctx) {
+		h.next(ctx)
+		return
+	}
+	if err := h.check(ctx); err != nil {
+		h.fail(ctx, err)
+		return
+	}
+	h.next(ctx)
+}
+
+// issue adds a fresh token to the user values and response headers.
+func (h *handler) issue(ctx *fasthttp.RequestCtx) {
+	session, err := h.requestSession(ctx)
+	if err != nil {
+		return
+	}
+	token, err := h.authenticator.GenerateTokenCtx(context.Background(), time.Time{}, session)
+	if err != nil {
+		return
+	}
+	ctx.Response.Header.Set(h.header, token)
+	ctx.SetUserValue(tokenKey, token)
+}
+
+// check validates the token carried by an unsafe request.
+func (h *handler) check(ctx *fasthttp.RequestCtx) error {
+	session, err := h.requestSession(ctx)
+	if err != nil {
+		return err
+	}
+	return h.authenticator.ValidateTokenCtx(context.Background(), time.Time{}, session, h.requestToken(ctx))
+}
+
+func (h *handler) fail(ctx *fasthttp.RequestCtx, err error) {
+	if h.errorHandler != nil {
+		ctx.SetUserValue(failureKey, err)
+		h.errorHandler(ctx)
+		return
+	}
+	ctx.Error(fasthttp.StatusMessage(fasthttp.StatusForbidden), fasthttp.StatusForbidden)
+}
+
+func (h *handler) requestSession(ctx *fasthttp.RequestCtx) ([]byte, error) {
+	if h.session == nil {
+		return nil, nil
+	}
+	return h.session(ctx)
+}
+
+// requestToken returns the token header, or else the form field of a
+// urlencoded or multipart body.
+func (h *handler) requestToken(ctx *fasthttp.RequestCtx) string {
+	if token := ctx.Request.Header.Peek(h.header); len(token) > 0 {
+		return string(token)
+	}
+	if bytes.HasPrefix(ctx.Request.Header.ContentType(), []byte("multipart/form-data")) {
+		form, err := ctx.MultipartForm()
+		if err != nil || len(form.Value[h.field]) == 0 {
+			return ""
+		}
+		return form.Value[h.field][0]
+	}
+	return string(ctx.PostArgs().Peek(h.field))
+}
+
+func isSafeMethod(ctx *fasthttp.RequestCtx) bool {
+	return ctx.IsGet() || ctx.IsHead() || ctx.IsOptions() || ctx.IsTrace()
+}
@@ -0,0 +1,16 @@
+module github.com/foobaz/csrf/fasthttpcsrf
+
+go 1.18
+
+require (
+	github.com/foobaz/csrf v0.0.0
+	github.com/valyala/fasthttp v1.51.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+)
+
+replace github.com/foobaz/csrf => ../
@@ -0,0 +1,51 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrCrossSite is returned for a state-changing request that its browser
+// labeled cross-site in Sec-Fetch-Site.
+var ErrCrossSite = errors.New("csrf: cross-site request")
+
+// FetchMetadata rejects unsafe requests whose Sec-Fetch-Site header marks
+// them as cross-site, whatever their Sec-Fetch-Mode, before the token is
+// looked at. Browsers send the header on every request and pages cannot
+// alter it. Enable it with WithFetchMetadata().
+type FetchMetadata struct {
+	// AllowSameSite also accepts same-site requests, from sibling
+	// subdomains of the same registrable domain.
+	AllowSameSite bool
+	// Fallback decides requests without Sec-Fetch-Site, from older
+	// browsers and non-browser clients, such as OriginChecker.Check. If
+	// nil, they proceed to the token check.
+	Fallback func(r *http.Request) error
+}
+
+// WithFetchMetadata() checks Sec-Fetch-Site on unsafe requests.
+func WithFetchMetadata(f *FetchMetadata) Option {
+	return func(m *Middleware) {
+		m.FetchMetadata = f
+	}
+}
+
+// Check() returns nil unless the request is cross-site or fails the
+// Fallback.
+func (f *FetchMetadata) Check(r *http.Request) error {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return nil
+	case "same-site":
+		if f.AllowSameSite {
+			return nil
+		}
+		return ErrCrossSite
+	case "":
+		if f.Fallback != nil {
+			return f.Fallback(r)
+		}
+		return nil
+	}
+	return ErrCrossSite
+}
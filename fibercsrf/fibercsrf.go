@@ -0,0 +1,41 @@
+// Package fibercsrf adapts csrf.Protect() to Fiber, which is built on
+// fasthttp, by converting each request to net/http:
+//
+//	app := fiber.New()
+//	app.Use(fibercsrf.Middleware(csrf.WithAuthenticator(a), csrf.WithSession(session)))
+//
+// The conversion copies the request, so it costs more than the other
+// adapters. Rejected requests are answered by the csrf middleware.
+package fibercsrf
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/foobaz/csrf"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// tokenKey holds the issued token in the Fiber locals.
+type tokenKey struct{}
+
+// Middleware() returns a fiber.Handler with the csrf options. It panics on
+// a misconfiguration, as csrf.Protect() does.
+func Middleware(opts ...csrf.Option) fiber.Handler {
+	wrap := csrf.Wrap(opts...)
+	return adaptor.HTTPMiddleware(func(next http.Handler) http.Handler {
+		return wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// adaptor copies context values to the Fiber locals
+			ctx := context.WithValue(r.Context(), tokenKey{}, csrf.Token(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}))
+	})
+}
+
+// Token() returns the token issued for a safe request, or "" if there is
+// none.
+func Token(c *fiber.Ctx) string {
+	token, _ := c.Locals(tokenKey{}).(string)
+	return token
+}
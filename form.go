@@ -0,0 +1,141 @@
+package csrf
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestField is the form field carrying a signed FormManifest.
+const ManifestField = "csrf_manifest"
+
+const formPurpose = "form"
+
+// FormManifest locks which fields a form may submit. Render the value of
+// SignForm() in a hidden ManifestField input and check submissions with
+// VerifyForm(), which rejects fields not listed here and locked fields
+// whose value was altered.
+type FormManifest struct {
+	// Fields lists the names the form may submit with any value
+	Fields []string `json:"f,omitempty"`
+	// Locked maps names to the only value they may be submitted with
+	Locked map[string]string `json:"l,omitempty"`
+}
+
+// FormFieldError reports a submitted field that violates the manifest.
+type FormFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FormFieldError) Error() string {
+	return fmt.Sprintf("csrf: form field %q %s", e.Field, e.Reason)
+}
+
+// SignForm() returns the ManifestField value for a form in the session.
+// It is a token bound to the manifest followed by the encoded manifest,
+// and also serves as the form's CSRF token.
+func (a *Authenticator) SignForm(date time.Time, session []byte, m FormManifest) (string, error) {
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	fields := append([]string(nil), m.Fields...)
+	sort.Strings(fields)
+	m.Fields = fields
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	token, err := a.plain().GenerateTokenErr(date, bind(formPurpose, session, manifest))
+	if err != nil {
+		return "", err
+	}
+	return token + base64.RawURLEncoding.EncodeToString(manifest), nil
+}
+
+// VerifyForm() checks submitted form values against the signed manifest
+// in their ManifestField. The ManifestField and csrf_token fields are
+// always allowed.
+func (a *Authenticator) VerifyForm(date time.Time, session []byte, form url.Values) error {
+	return a.verifyForm(date, session, session, form)
+}
+
+// verifyForm is VerifyForm() for a session bound to raw.
+func (a *Authenticator) verifyForm(date time.Time, raw, session []byte, form url.Values) error {
+	if err := a.checkSession(session); err != nil {
+		return err
+	}
+	signed := form.Get(ManifestField)
+	if len(signed) < a.tokenLength() {
+		return ErrInvalidToken
+	}
+	manifest, err := base64.RawURLEncoding.DecodeString(signed[a.tokenLength():])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	token := signed[:a.tokenLength()]
+	if _, err := a.validate(context.Background(), date, raw, bind(formPurpose, session, manifest), token); err != nil {
+		return err
+	}
+
+	var m FormManifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return ErrInvalidToken
+	}
+	allowed := map[string]bool{ManifestField: true, "csrf_token": true}
+	for _, f := range m.Fields {
+		allowed[f] = true
+	}
+	for name, values := range form {
+		if locked, ok := m.Locked[name]; ok {
+			if len(values) != 1 || values[0] != locked {
+				return &FormFieldError{Field: name, Reason: "altered"}
+			}
+			continue
+		}
+		if !allowed[name] {
+			return &FormFieldError{Field: name, Reason: "not allowed"}
+		}
+	}
+	for name := range m.Locked {
+		if _, ok := form[name]; !ok {
+			return &FormFieldError{Field: name, Reason: "missing"}
+		}
+	}
+	return nil
+}
+
+// FormClause() is a policy Clause that parses the request body and
+// requires it to satisfy its signed FormManifest. File fields of
+// multipart forms count as submitted fields.
+func FormClause(a *Authenticator, session func(*http.Request) ([]byte, error)) Clause {
+	return ClauseFunc("form", func(r *http.Request) error {
+		raw, s, err := requestSession(a, session, r)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(32 << 20); err != nil {
+				return err
+			}
+		} else if err := r.ParseForm(); err != nil {
+			return err
+		}
+		form := url.Values{}
+		for name, values := range r.PostForm {
+			form[name] = values
+		}
+		if r.MultipartForm != nil {
+			for name := range r.MultipartForm.File {
+				form[name] = append(form[name], "")
+			}
+		}
+		return a.verifyForm(a.now(), raw, s, form)
+	})
+}
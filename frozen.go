@@ -0,0 +1,143 @@
+package csrf
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// FrozenAuthenticator holds a private copy of an Authenticator
+// configuration that cannot be modified while it serves requests. The
+// exported fields of Authenticator invite racy changes to Key or Lifetime
+// at run time; a FrozenAuthenticator only changes through Reload(), which
+// replaces the whole configuration atomically.
+type FrozenAuthenticator struct {
+	config atomic.Value // *Authenticator
+}
+
+// Freeze() copies the configuration of a, including its keys and Pepper,
+// into a new FrozenAuthenticator. Later changes to a have no effect on it.
+// Stores such as Revocations and Denylist are shared, not copied, and so
+// are keys installed by SetKeys(), which rotates safely under requests.
+// With WithKeyCopy(), Close() of a zeroes those keys and so closes the
+// FrozenAuthenticator too.
+func Freeze(a *Authenticator) *FrozenAuthenticator {
+	f := &FrozenAuthenticator{}
+	f.Reload(a)
+	return f
+}
+
+// Reload() atomically replaces the configuration with a copy of a.
+// Requests in progress finish with the configuration they started with.
+func (f *FrozenAuthenticator) Reload(a *Authenticator) {
+	c := a.clone()
+	c.Key = append([]byte(nil), a.Key...)
+	c.Pepper = append([]byte(nil), a.Pepper...)
+	c.SecondaryKeys = copySecondaryKeys(a.SecondaryKeys)
+	f.config.Store(c)
+}
+
+// Config() returns a copy of the current configuration.
+func (f *FrozenAuthenticator) Config() Authenticator {
+	c := *f.current().clone()
+	c.Key = append([]byte(nil), c.Key...)
+	c.Pepper = append([]byte(nil), c.Pepper...)
+	c.SecondaryKeys = copySecondaryKeys(c.SecondaryKeys)
+	return c
+}
+
+func (f *FrozenAuthenticator) current() *Authenticator {
+	return f.config.Load().(*Authenticator)
+}
+
+// WithFrozenAuthenticator() makes Protect() generate and validate tokens
+// with the configuration f holds when each request arrives, in place of
+// WithAuthenticator(), so Reload() takes effect without rebuilding the
+// middleware. With WithDoubleSubmit(), cookies are made with it too. It
+// cannot be combined with WithTenants() or WithOverrides().
+func WithFrozenAuthenticator(f *FrozenAuthenticator) Option {
+	return func(m *Middleware) {
+		m.Frozen = f
+	}
+}
+
+// checkFrozen panics if Frozen is combined with another source of the
+// Authenticator, and otherwise starts with its current configuration, so
+// the checks of Protect() see it.
+func (m *Middleware) checkFrozen() {
+	if m.Tenants != nil {
+		panic("csrf: WithFrozenAuthenticator() cannot be combined with WithTenants()")
+	}
+	if len(m.overrides) > 0 {
+		panic("csrf: WithFrozenAuthenticator() cannot be combined with WithOverrides()")
+	}
+	if m.Authenticator != nil && (m.DoubleSubmit == nil || m.Authenticator != m.DoubleSubmit.Authenticator) {
+		panic("csrf: WithFrozenAuthenticator() cannot be combined with WithAuthenticator()")
+	}
+	m.setAuthenticator(m.Frozen.current())
+}
+
+// forFrozen returns m with the current configuration of Frozen.
+func (m *Middleware) forFrozen() *Middleware {
+	a := m.Frozen.current()
+	if a == m.Authenticator {
+		return m
+	}
+	t := *m
+	t.setAuthenticator(a)
+	return &t
+}
+
+// GenerateToken() is Authenticator.GenerateToken() with the current
+// configuration.
+func (f *FrozenAuthenticator) GenerateToken(date time.Time, session []byte) string {
+	return f.current().GenerateToken(date, session)
+}
+
+// GenerateTokenErr() is Authenticator.GenerateTokenErr() with the current
+// configuration.
+func (f *FrozenAuthenticator) GenerateTokenErr(date time.Time, session []byte) (string, error) {
+	return f.current().GenerateTokenErr(date, session)
+}
+
+// GenerateTokenCtx() is Authenticator.GenerateTokenCtx() with the current
+// configuration.
+func (f *FrozenAuthenticator) GenerateTokenCtx(ctx context.Context, date time.Time, session []byte) (string, error) {
+	return f.current().GenerateTokenCtx(ctx, date, session)
+}
+
+// ValidateToken() is Authenticator.ValidateToken() with the current
+// configuration.
+func (f *FrozenAuthenticator) ValidateToken(date time.Time, session []byte, token string) bool {
+	return f.current().ValidateToken(date, session, token)
+}
+
+// ValidateTokenCtx() is Authenticator.ValidateTokenCtx() with the current
+// configuration.
+func (f *FrozenAuthenticator) ValidateTokenCtx(ctx context.Context, date time.Time, session []byte, token string) error {
+	return f.current().ValidateTokenCtx(ctx, date, session, token)
+}
+
+// ValidateTokenErr() is Authenticator.ValidateTokenErr() with the current
+// configuration.
+func (f *FrozenAuthenticator) ValidateTokenErr(date time.Time, session []byte, token string) error {
+	return f.current().ValidateTokenErr(date, session, token)
+}
+
+// ValidateTokenTTL() is Authenticator.ValidateTokenTTL() with the current
+// configuration.
+func (f *FrozenAuthenticator) ValidateTokenTTL(date time.Time, session []byte, token string) (time.Duration, error) {
+	return f.current().ValidateTokenTTL(date, session, token)
+}
+
+// GenerateTokenFor() is Authenticator.GenerateTokenFor() with the current
+// configuration.
+func (f *FrozenAuthenticator) GenerateTokenFor(date time.Time, session []byte, method, path string) (string, error) {
+	return f.current().GenerateTokenFor(date, session, method, path)
+}
+
+// ValidateTokenFor() is Authenticator.ValidateTokenFor() with the current
+// configuration.
+func (f *FrozenAuthenticator) ValidateTokenFor(date time.Time, session []byte, method, path, token string) error {
+	return f.current().ValidateTokenFor(date, session, method, path, token)
+}
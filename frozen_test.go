@@ -0,0 +1,68 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProtectFrozenReload(t *testing.T) {
+	a := testAuthenticator(t)
+	f := Freeze(a)
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithFrozenAuthenticator(f),
+		WithSession(func(*http.Request) ([]byte, error) { return testSession, nil }))
+	send := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("X-CSRF-Token", token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	now := time.Now()
+	old, err := f.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := send(old); code != http.StatusOK {
+		t.Fatalf("before Reload(): got %d, want %d", code, http.StatusOK)
+	}
+
+	next := f.Config()
+	next.Key = make([]byte, MinKeyLength)
+	next.SecondaryKeys = nil
+	f.Reload(&next)
+	if code := send(old); code != http.StatusForbidden {
+		t.Fatalf("old key after Reload(): got %d, want %d", code, http.StatusForbidden)
+	}
+	token, err := f.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := send(token); code != http.StatusOK {
+		t.Fatalf("new key after Reload(): got %d, want %d", code, http.StatusOK)
+	}
+	if ttl, err := f.ValidateTokenTTL(now, testSession, token); err != nil || ttl <= 0 {
+		t.Fatalf("ValidateTokenTTL() = %v, %v", ttl, err)
+	}
+}
+
+func TestProtectFrozenConflicts(t *testing.T) {
+	f := Freeze(testAuthenticator(t))
+	for name, opt := range map[string]Option{
+		"WithAuthenticator()": WithAuthenticator(testAuthenticator(t)),
+		"WithTenants()":       WithTenants(&AuthenticatorSet{}, HostTenant),
+		"WithOverrides()":     WithOverrides(WithLifetime(time.Minute)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Protect() did not panic")
+				}
+			}()
+			Protect(nil, WithFrozenAuthenticator(f), opt)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package gincsrf adapts csrf.Protect() to Gin:
+//
+//	r := gin.New()
+//	r.Use(gincsrf.Middleware(csrf.WithAuthenticator(a), csrf.WithSession(session)))
+//
+// Rejected requests are answered by the csrf middleware, with its
+// ErrorHandler if one is set, and the Gin chain is aborted.
+package gincsrf
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/foobaz/csrf"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware() returns a gin.HandlerFunc with the csrf options. It panics
+// on a misconfiguration, as csrf.Protect() does.
+func Middleware(opts ...csrf.Option) gin.HandlerFunc {
+	wrap := csrf.Wrap(opts...)
+	return func(c *gin.Context) {
+		passed := false
+		original := c.Writer
+		wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Request = r
+			if w == http.ResponseWriter(original) {
+				c.Next()
+				return
+			}
+			// the rest of the chain writes through w, so whatever the
+			// csrf middleware wraps the response with, such as
+			// WithFormInjection(), sees it
+			rw := &responseWriter{ResponseWriter: original, w: w, size: noWritten, status: http.StatusOK}
+			c.Writer = rw
+			defer func() { c.Writer = original }()
+			c.Next()
+			rw.WriteHeaderNow()
+		})).ServeHTTP(original, c.Request)
+		if !passed {
+			c.Abort()
+		}
+	}
+}
+
+const noWritten = -1
+
+// responseWriter is a gin.ResponseWriter writing to the ResponseWriter
+// the csrf middleware passes on, with the status handling of Gin's own:
+// the status is only written with the body or by WriteHeaderNow().
+// Hijacking, pushing and close notification use the Gin writer.
+type responseWriter struct {
+	gin.ResponseWriter
+	w      http.ResponseWriter
+	size   int
+	status int
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if code > 0 && !rw.Written() {
+		rw.status = code
+	}
+}
+
+func (rw *responseWriter) WriteHeaderNow() {
+	if !rw.Written() {
+		rw.size = 0
+		rw.w.WriteHeader(rw.status)
+	}
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeaderNow()
+	n, err := rw.w.Write(b)
+	rw.size += n
+	return n, err
+}
+
+func (rw *responseWriter) WriteString(s string) (int, error) {
+	rw.WriteHeaderNow()
+	n, err := io.WriteString(rw.w, s)
+	rw.size += n
+	return n, err
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) Size() int {
+	return rw.size
+}
+
+func (rw *responseWriter) Written() bool {
+	return rw.size != noWritten
+}
+
+func (rw *responseWriter) Flush() {
+	rw.WriteHeaderNow()
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Token() returns the token issued for a safe request, or "" if there is
+// none.
+func Token(c *gin.Context) string {
+	return csrf.Token(c.Request)
+}
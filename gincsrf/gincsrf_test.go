@@ -0,0 +1,70 @@
+package gincsrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foobaz/csrf"
+	"github.com/gin-gonic/gin"
+)
+
+func testRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	key := make([]byte, csrf.MinKeyLength)
+	a, err := csrf.NewAuthenticator(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := func(*http.Request) ([]byte, error) {
+		return []byte("0123456789abcdef0123456789abcdef"), nil
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(csrf.WithAuthenticator(a), csrf.WithSession(session), csrf.WithFormInjection()))
+	r.GET("/form", func(c *gin.Context) {
+		c.Header("X-Handler", "form")
+		c.Data(http.StatusCreated, "text/html; charset=utf-8", []byte(`<form method="post"></form>`))
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.POST("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, "submitted")
+	})
+	return r
+}
+
+func TestMiddlewareWrapsWriter(t *testing.T) {
+	r := testRouter(t)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusCreated)
+	}
+	if got := w.Header().Get("X-Handler"); got != "form" {
+		t.Fatalf("got X-Handler %q, want %q", got, "form")
+	}
+	if body := w.Body.String(); !strings.Contains(body, `name="csrf_token"`) {
+		t.Fatalf("form injection did not run: %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/empty", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d for an empty body, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	r := testRouter(t)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/form", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if strings.Contains(w.Body.String(), "submitted") {
+		t.Fatal("handler ran for a rejected request")
+	}
+}
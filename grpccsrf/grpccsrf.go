@@ -0,0 +1,130 @@
+// Package grpccsrf validates csrf tokens on gRPC and gRPC-Web calls.
+// Browsers send gRPC-Web calls with their cookies, so a cross-site page
+// can invoke them as easily as a REST endpoint. The interceptors reject
+// calls whose metadata lacks a valid token, which a frontend gets from
+// csrf.TokenHandler() or a page served through csrf.Protect().
+package grpccsrf
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/foobaz/csrf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the metadata key tokens are read from by default. It is
+// the lowercase form of the csrf.TokenHeader HTTP header, which gRPC-Web
+// proxies forward as metadata.
+const MetadataKey = "x-csrf-token"
+
+// Option configures the interceptors.
+type Option func(*interceptor)
+
+// WithSession() sets the function returning the session binding for a
+// call, such as a hash of the session cookie in its metadata. Without it,
+// tokens are bound to an empty session, so a token from one session is
+// accepted in any other, and Strict Authenticators reject every call.
+func WithSession(session func(ctx context.Context) ([]byte, error)) Option {
+	return func(i *interceptor) {
+		i.session = session
+	}
+}
+
+// WithMetadataKeys() sets the metadata keys tokens are read from, in
+// order, in place of x-csrf-token.
+func WithMetadataKeys(keys ...string) Option {
+	return func(i *interceptor) {
+		i.keys = keys
+	}
+}
+
+// WithSkip() exempts calls for which skip returns true, given the full
+// method name such as "/pkg.Service/Method", for example health checks
+// or methods only reachable by other servers.
+func WithSkip(skip func(fullMethod string) bool) Option {
+	return func(i *interceptor) {
+		i.skip = skip
+	}
+}
+
+type interceptor struct {
+	authenticator *csrf.Authenticator
+	session       func(ctx context.Context) ([]byte, error)
+	keys          []string
+	skip          func(fullMethod string) bool
+}
+
+func newInterceptor(a *csrf.Authenticator, opts []Option) *interceptor {
+	if a == nil {
+		panic("grpccsrf: nil Authenticator")
+	}
+	i := &interceptor{authenticator: a, keys: []string{MetadataKey}}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// UnaryServerInterceptor() returns an interceptor that rejects unary
+// calls without a valid token with codes.PermissionDenied.
+func UnaryServerInterceptor(a *csrf.Authenticator, opts ...Option) grpc.UnaryServerInterceptor {
+	i := newInterceptor(a, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := i.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor() returns an interceptor that rejects streams
+// without a valid token in their initial metadata with
+// codes.PermissionDenied.
+func StreamServerInterceptor(a *csrf.Authenticator, opts ...Option) grpc.StreamServerInterceptor {
+	i := newInterceptor(a, opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// check validates the token in the incoming metadata of ctx.
+func (i *interceptor) check(ctx context.Context, fullMethod string) error {
+	if i.skip != nil && i.skip(fullMethod) {
+		return nil
+	}
+	var session []byte
+	if i.session != nil {
+		var err error
+		if session, err = i.session(ctx); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	err := i.authenticator.ValidateTokenCtx(ctx, time.Time{}, session, i.token(ctx))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+}
+
+// token returns the first non-empty token among the metadata keys.
+func (i *interceptor) token(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range i.keys {
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
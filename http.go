@@ -0,0 +1,33 @@
+package csrf
+
+import "net/http"
+
+// isSafeMethod reports whether method cannot change state and so needs no
+// CSRF check.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// requestToken returns the token from the default sources of the
+// middleware, the X-CSRF-Token header or the csrf_token form or multipart
+// field, leaving the body intact.
+var requestToken = TokenExtractor()
+
+// requestSession calls session, treating a nil func as an empty binding,
+// and returns the result and the result bound to r with a.BindRequest().
+// Tokens are checked against the bound session and Revocations against
+// the other.
+func requestSession(a *Authenticator, session func(*http.Request) ([]byte, error), r *http.Request) ([]byte, []byte, error) {
+	if session == nil {
+		return nil, a.BindRequest(r, nil), nil
+	}
+	s, err := session(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, a.BindRequest(r, s), nil
+}
@@ -0,0 +1,289 @@
+package csrf
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errPunycode is returned for a label that is not valid punycode.
+var errPunycode = errors.New("csrf: invalid punycode")
+
+// The parameters of punycode, from RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	// punyMaxInt bounds the arithmetic of decodePunycode on 32 bit
+	// platforms.
+	punyMaxInt = 1<<31 - 1
+)
+
+// asciiHost returns host, without any port, in the ASCII form browsers
+// send in Origin: lower case, with each label holding other characters
+// lower cased and punycode encoded behind "xn--". It does not apply the
+// full IDNA mapping, so internationalized TrustedOrigins should be
+// written in NFC, as typed into a browser, or already in ASCII.
+func asciiHost(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		labels[i] = "xn--" + encodePunycode([]rune(strings.ToLower(label)))
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// unicodeLabels returns the labels of an ASCII host with the punycode
+// ones decoded.
+func unicodeLabels(host string) ([][]rune, error) {
+	var labels [][]rune
+	for _, label := range strings.Split(host, ".") {
+		if !strings.HasPrefix(label, "xn--") {
+			labels = append(labels, []rune(label))
+			continue
+		}
+		decoded, err := decodePunycode(label[len("xn--"):])
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, decoded)
+	}
+	return labels, nil
+}
+
+// mixedScript reports whether a label of an ASCII host mixes scripts,
+// such as Latin and Cyrillic, the way lookalikes of well known names do,
+// or does not decode. Following the highly restrictive profile of Unicode
+// TS 39, Latin may only be mixed with Han and the Japanese kana, with Han
+// and Bopomofo, or with Han and Hangul.
+func mixedScript(host string) bool {
+	labels, err := unicodeLabels(host)
+	if err != nil {
+		return true
+	}
+	for _, label := range labels {
+		scripts := make(map[string]bool)
+		for _, r := range label {
+			if s := script(r); s != "" {
+				scripts[s] = true
+			}
+		}
+		if len(scripts) > 1 && !allowedScripts(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptSets are the combinations of scripts mixedScript accepts.
+var scriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// allowedScripts reports whether scripts are within one of scriptSets.
+func allowedScripts(scripts map[string]bool) bool {
+next:
+	for _, set := range scriptSets {
+		for s := range scripts {
+			if !containsString(set, s) {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// script returns the Unicode script of r, or "" for characters shared by
+// scripts, such as digits and the hyphen.
+func script(r rune) string {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+			return "Latin"
+		}
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			if name == "Common" || name == "Inherited" {
+				return ""
+			}
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// encodePunycode returns the punycode of label, from RFC 3492.
+func encodePunycode(label []rune) string {
+	var out []byte
+	for _, r := range label {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h := basic; h < len(label); {
+		m := rune(unicode.MaxRune + 1)
+		for _, r := range label {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+		for _, r := range label {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+// decodePunycode returns the label encoded by s, from RFC 3492.
+func decodePunycode(s string) ([]rune, error) {
+	var out []rune
+	pos := 0
+	if b := strings.LastIndexByte(s, '-'); b >= 0 {
+		for _, r := range s[:b] {
+			if r >= utf8.RuneSelf {
+				return nil, errPunycode
+			}
+			out = append(out, r)
+		}
+		pos = b + 1
+	}
+	n, i, bias := rune(punyInitialN), 0, punyInitialBias
+	for pos < len(s) {
+		old, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos == len(s) {
+				return nil, errPunycode
+			}
+			digit, ok := punyValue(s[pos])
+			pos++
+			if !ok || digit > (punyMaxInt-i)/w {
+				return nil, errPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w > punyMaxInt/(punyBase-t) {
+				return nil, errPunycode
+			}
+			w *= punyBase - t
+		}
+		length := len(out) + 1
+		bias = punyAdapt(i-old, length, old == 0)
+		if i/length > unicode.MaxRune-int(n) {
+			return nil, errPunycode
+		}
+		n += rune(i / length)
+		i %= length
+		if unicode.Is(unicode.Cs, n) {
+			return nil, errPunycode
+		}
+		out = append(out, 0)
+		copy(out[i+1:], out[i:])
+		out[i] = n
+		i++
+	}
+	return out, nil
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyValue(c byte) (int, bool) {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return int(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int(c - 'A'), true
+	case '0' <= c && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPunycode(t *testing.T) {
+	for _, tt := range []struct{ unicode, ascii string }{
+		// samples of RFC 3492, section 7.1
+		{"ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+		{"他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+		{"3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"bücher", "bcher-kva"},
+		{"münchen", "mnchen-3ya"},
+		{"pаypal", "pypal-4ve"},
+	} {
+		if got := encodePunycode([]rune(tt.unicode)); got != tt.ascii {
+			t.Errorf("encodePunycode(%q) = %q, want %q", tt.unicode, got, tt.ascii)
+		}
+		if got, err := decodePunycode(tt.ascii); err != nil || string(got) != tt.unicode {
+			t.Errorf("decodePunycode(%q) = %q, %v, want %q", tt.ascii, string(got), err, tt.unicode)
+		}
+	}
+	for _, bad := range []string{"bü-kva", "bcher-kv!", "99999999999"} {
+		if _, err := decodePunycode(bad); err == nil {
+			t.Errorf("decodePunycode(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestOriginCheckerIDN(t *testing.T) {
+	c := &OriginChecker{TrustedOrigins: []string{
+		"https://bücher.example",
+		"https://*.München.example",
+		"https://xn--wgv71a119e.example",
+		"https://*.shop.example",
+	}}
+	strict := &OriginChecker{TrustedOrigins: c.TrustedOrigins, RejectMixedScript: true}
+	tests := []struct {
+		origin        string
+		trusted       bool
+		trustedStrict bool
+	}{
+		{"https://xn--bcher-kva.example", true, true},
+		{"https://XN--BCHER-KVA.EXAMPLE", true, true},
+		{"https://bcher.example", false, false},
+		{"https://xn--bcher-kva.example:8443", false, false},
+		{"https://a.xn--mnchen-3ya.example", true, true},
+		{"https://xn--mnchen-3ya.example", false, false},
+		// 日本語, given in ASCII and sent in ASCII
+		{"https://xn--wgv71a119e.example", true, true},
+		// pаypal with a Cyrillic а under a wildcard
+		{"https://xn--pypal-4ve.shop.example", true, false},
+		{"https://paypal.shop.example", true, true},
+		// 3年B組金八先生 mixes Latin and Han, as Japanese does
+		{"https://xn--3b-ww4c5e180e575a65lsy2b.shop.example", true, true},
+		// invalid punycode
+		{"https://xn--99999999999.shop.example", true, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("Origin", tt.origin)
+		if got := c.Check(r) == nil; got != tt.trusted {
+			t.Errorf("%s: trusted = %v, want %v", tt.origin, got, tt.trusted)
+		}
+		if got := strict.Check(r) == nil; got != tt.trustedStrict {
+			t.Errorf("%s: trusted with RejectMixedScript = %v, want %v", tt.origin, got, tt.trustedStrict)
+		}
+	}
+}
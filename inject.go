@@ -0,0 +1,125 @@
+package csrf
+
+import (
+	"bytes"
+	"html"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// WithFormInjection() makes Protect() add a hidden token input to every
+// <form method="post"> in text/html responses to safe requests, so a
+// legacy template codebase is protected without editing each template.
+// Responses are buffered in full to be rewritten, and compressed ones are
+// left alone, so compress after this middleware. Forms rendered in
+// response to an unsafe request, such as a failed submission, get no
+// token. Forms submitting to another origin get no token either, so it is
+// never sent to a third party. With WithActionBinding(), each form gets a
+// token for its own action, as from TokenFor().
+func WithFormInjection() Option {
+	return func(m *Middleware) {
+		m.InjectForms = true
+	}
+}
+
+// injectForms adds a hidden input for the token of r after each opening
+// tag of a same-origin POST form in page. It finds forms with the patterns
+// of FormAudit, so the two agree on what a POST form is.
+func injectForms(page []byte, r *http.Request) []byte {
+	return formOpen.ReplaceAllFunc(page, func(tag []byte) []byte {
+		if !formMethod.Match(tag) {
+			return tag
+		}
+		var action string
+		if m := formAction.FindSubmatch(tag); m != nil {
+			action = html.UnescapeString(string(bytes.Join(m[1:], nil)))
+		}
+		if !sameOrigin(r, action) {
+			return tag
+		}
+		token, err := TokenFor(r, http.MethodPost, action)
+		if err != nil || token == "" {
+			return tag
+		}
+		return append(append([]byte(nil), tag...), hiddenField(r, token)...)
+	})
+}
+
+// sameOrigin reports whether a form action, resolved against the URL of r
+// as a browser would, submits to the origin r was sent to.
+func sameOrigin(r *http.Request, action string) bool {
+	u, err := r.URL.Parse(strings.TrimSpace(action))
+	if err != nil || u.Opaque != "" {
+		return false
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if u.Scheme != "" && !strings.EqualFold(u.Scheme, scheme) {
+		return false
+	}
+	return u.Host == "" || strings.EqualFold(u.Host, r.Host)
+}
+
+// injectWriter buffers an HTML response to add tokens to its forms, and
+// passes any other response through.
+type injectWriter struct {
+	http.ResponseWriter
+	r *http.Request
+
+	decided bool
+	inject  bool
+	status  int
+	body    bytes.Buffer
+}
+
+// decide chooses whether to rewrite the response once its headers are
+// final, sniffing the type from the first bytes of body if it is unset.
+func (w *injectWriter) decide(body []byte) {
+	w.decided = true
+	h := w.Header()
+	contentType := h.Get("Content-Type")
+	if contentType == "" && body != nil {
+		contentType = http.DetectContentType(body)
+		h.Set("Content-Type", contentType)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	w.inject = mediaType == "text/html" && h.Get("Content-Encoding") == ""
+}
+
+func (w *injectWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decide(nil)
+	}
+	if !w.inject {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *injectWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.decide(p)
+	}
+	if !w.inject {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.body.Write(p)
+}
+
+// finish writes a buffered response with its forms rewritten.
+func (w *injectWriter) finish() {
+	if !w.inject {
+		return
+	}
+	page := injectForms(w.body.Bytes(), w.r)
+	w.Header().Del("Content-Length")
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(page)
+}
@@ -0,0 +1,107 @@
+// Package core holds the token format shared by package csrf and the
+// lightweight validator in package edge. It imports only small standard
+// packages, so both build with TinyGo for WebAssembly.
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// URLSafe is the default token alphabet, sorted.
+var URLSafe = []byte{
+	'-', '.',
+	'0', '1', '2', '3', '4', '5', '6', '7', '8', '9',
+	'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M',
+	'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z',
+	'_',
+	'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm',
+	'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z',
+	'~',
+}
+
+// AlphabetIndex returns the position of c in alphabet, or -1. It compares
+// c with every character, so its time does not depend on c.
+func AlphabetIndex(alphabet []byte, c byte) int {
+	index := -1
+	for i, a := range alphabet {
+		index = subtle.ConstantTimeSelect(subtle.ConstantTimeByteEq(a, c), i, index)
+	}
+	return index
+}
+
+// WriteMAC writes the input of a token MAC to h.
+func WriteMAC(h hash.Hash, pepper []byte, counter int64, epoch, session, salt []byte) {
+	var counterBytes [8]byte
+	binary.BigEndian.PutUint64(counterBytes[:], uint64(counter))
+	h.Write(pepper)
+	h.Write(counterBytes[:])
+	h.Write(epoch)
+	h.Write(session)
+	h.Write(salt)
+}
+
+// Encode fills dst with the digits of sum, a big-endian number, in base
+// len(alphabet), least significant first. Digits past the end of sum are
+// the zero character. It divides 32 bit limbs in place, so MACs up to 128
+// bytes need no allocation.
+func Encode(dst, sum, alphabet []byte) {
+	var buf [32]uint32
+	n := (len(sum) + 3) / 4
+	limbs := buf[:]
+	if n > len(limbs) {
+		limbs = make([]uint32, n)
+	}
+	limbs = limbs[:n]
+	for i, b := range sum {
+		p := len(sum) - 1 - i
+		limbs[n-1-p/4] |= uint32(b) << (8 * (p % 4))
+	}
+
+	base := uint64(len(alphabet))
+	top := 0
+	for i := range dst {
+		for top < n && limbs[top] == 0 {
+			top++
+		}
+		var remainder uint64
+		for j := top; j < n; j++ {
+			cur := remainder<<32 | uint64(limbs[j])
+			limbs[j] = uint32(cur / base)
+			remainder = cur % base
+		}
+		dst[i] = alphabet[remainder]
+	}
+}
+
+// Window returns the counter of the lifetime nanosecond window containing
+// date and the time remaining in it, or false if the counter overflows.
+// Dates after 2262 overflow UnixNano(), so the division is done in 128
+// bits from the seconds and nanoseconds instead.
+func Window(date time.Time, lifetime int64) (int64, time.Duration, bool) {
+	sec := date.Unix()
+	if sec < 0 {
+		// UnixNano() is exact back to 1678
+		if sec < math.MinInt64/int64(time.Second)+1 {
+			return 0, 0, false
+		}
+		nanos := date.UnixNano()
+		return nanos / lifetime, time.Duration(lifetime - nanos%lifetime), true
+	}
+
+	hi, lo := bits.Mul64(uint64(sec), 1e9)
+	lo, carry := bits.Add64(lo, uint64(date.Nanosecond()), 0)
+	hi += carry
+	if hi >= uint64(lifetime) {
+		return 0, 0, false
+	}
+	counter, rem := bits.Div64(hi, lo, uint64(lifetime))
+	if counter > math.MaxInt64 {
+		return 0, 0, false
+	}
+	return int64(counter), time.Duration(uint64(lifetime) - rem), true
+}
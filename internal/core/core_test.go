@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// bigEncode is the math/big conversion Encode replaced.
+func bigEncode(dst, sum, alphabet []byte) {
+	var n, base big.Int
+	n.SetBytes(sum)
+	base.SetUint64(uint64(len(alphabet)))
+	for i := range dst {
+		var remainder big.Int
+		n.QuoRem(&n, &base, &remainder)
+		dst[i] = alphabet[remainder.Uint64()]
+	}
+}
+
+func TestEncodeMatchesBig(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		sum := make([]byte, 1+rng.Intn(160))
+		rng.Read(sum)
+		got := make([]byte, 1+rng.Intn(64))
+		want := make([]byte, len(got))
+		Encode(got, sum, URLSafe)
+		bigEncode(want, sum, URLSafe)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Encode(%x) = %q, want %q", sum, got, want)
+		}
+	}
+}
+
+func benchmarkEncode(b *testing.B, encode func(dst, sum, alphabet []byte)) {
+	sum := bytes.Repeat([]byte{0xa5}, 64)
+	dst := make([]byte, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encode(dst, sum, URLSafe)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	benchmarkEncode(b, Encode)
+}
+
+func BenchmarkEncodeBig(b *testing.B) {
+	benchmarkEncode(b, bigEncode)
+}
@@ -0,0 +1,215 @@
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+const jwtPurpose = "jwt"
+
+// JWT algorithms.
+const (
+	JWTHS256 = "HS256"
+	JWTHS512 = "HS512"
+)
+
+// ErrJWTAlgorithm is returned by a JWT with an Algorithm other than
+// JWTHS256 or JWTHS512.
+var ErrJWTAlgorithm = errors.New("csrf: unsupported JWT algorithm")
+
+// JWT issues CSRF proofs as compact JWTs, for gateways and SDKs that
+// already handle JWTs uniformly. The claims are a keyed hash of the
+// session, the window counter and a random salt, plus exp for generic
+// JWT middleware. The signing key is derived from the Authenticator key
+// and Pepper, never the key itself, and the key ID, if any, is the kid
+// header. The Authenticator's Lifetime, AcceptedWindows, Grace, epoch,
+// revocation, Denylist, Replays and UsedTokens apply as for its own
+// tokens. JWTs are case sensitive even in CaseInsensitive mode.
+type JWT struct {
+	Authenticator *Authenticator
+	// Algorithm is JWTHS256 or JWTHS512, JWTHS512 if unset
+	Algorithm string
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+type jwtClaims struct {
+	Session string `json:"sid"`
+	Counter int64  `json:"ctr"`
+	Salt    string `json:"salt"`
+	Expiry  int64  `json:"exp"`
+}
+
+func (j *JWT) algorithm() (string, func() hash.Hash, error) {
+	switch j.Algorithm {
+	case "", JWTHS512:
+		return JWTHS512, sha512.New, nil
+	case JWTHS256:
+		return JWTHS256, sha256.New, nil
+	}
+	return "", nil, ErrJWTAlgorithm
+}
+
+// signingKey returns the key JWTs made with key are signed with.
+func (j *JWT) signingKey(key []byte) []byte {
+	h := hmac.New(sha512.New, key)
+	h.Write(j.Authenticator.Pepper)
+	h.Write([]byte(jwtPurpose))
+	return h.Sum(nil)
+}
+
+// sessionHash returns the sid claim for session under signing key.
+func (j *JWT) sessionHash(key, epoch, session []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(bind(jwtPurpose, j.Authenticator.normalizeSession(session), epoch))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+func (j *JWT) sign(newHash func() hash.Hash, key []byte, signed string) string {
+	h := hmac.New(newHash, key)
+	h.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// GenerateToken() returns a JWT for the session at date.
+func (j *JWT) GenerateToken(date time.Time, session []byte) (string, error) {
+	a := j.Authenticator
+	alg, newHash, err := j.algorithm()
+	if err != nil {
+		return "", err
+	}
+	if err := a.checkLifetime(); err != nil {
+		return "", err
+	}
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	epoch, err := a.epochBytes(context.Background())
+	if err != nil {
+		return "", err
+	}
+	date = a.date(date)
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", err
+	}
+	expiry, err := a.expiry(date, counter)
+	if err != nil {
+		return "", err
+	}
+	salt := make([]byte, a.saltLength())
+	if _, err := io.ReadFull(a.random(), salt); err != nil {
+		return "", err
+	}
+
+	id, key := a.primaryKey()
+	key = j.signingKey(key)
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Typ: "JWT", Kid: id})
+	claims, _ := json.Marshal(jwtClaims{
+		Session: j.sessionHash(key, epoch, session),
+		Counter: counter,
+		Salt:    base64.RawURLEncoding.EncodeToString(salt),
+		Expiry:  expiry.Unix(),
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signed + "." + j.sign(newHash, key, signed), nil
+}
+
+// ValidateToken() returns nil if token is a JWT made by GenerateToken()
+// for the session that has not expired at date.
+func (j *JWT) ValidateToken(date time.Time, session []byte, token string) error {
+	return j.ValidateTokenCtx(context.Background(), date, session, token)
+}
+
+// ValidateTokenCtx() is like ValidateToken() but passes ctx to the
+// Authenticator's backends, as in Authenticator.ValidateTokenCtx().
+func (j *JWT) ValidateTokenCtx(ctx context.Context, date time.Time, session []byte, token string) error {
+	a := j.Authenticator
+	alg, newHash, err := j.algorithm()
+	if err != nil {
+		return err
+	}
+	if err := a.checkLifetime(); err != nil {
+		return err
+	}
+	if err := a.checkSession(session); err != nil {
+		return err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+	var header jwtHeader
+	if b, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(b, &header) != nil {
+		return ErrInvalidToken
+	}
+	// Only the configured algorithm is accepted, never one the token names.
+	if header.Alg != alg {
+		return ErrInvalidToken
+	}
+	var claims jwtClaims
+	if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(b, &claims) != nil {
+		return ErrInvalidToken
+	}
+
+	id, _ := a.primaryKey()
+	if len(header.Kid) != len(id) {
+		return ErrMismatch
+	}
+	keys, _, err := a.verificationKeys(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	signed := parts[0] + "." + parts[1]
+	var key []byte
+	for _, k := range keys {
+		k = j.signingKey(k)
+		if hmac.Equal([]byte(j.sign(newHash, k, signed)), []byte(parts[2])) {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return ErrMismatch
+	}
+
+	revoked, err := a.sessionRevoked(ctx, session)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	epoch, err := a.epochBytes(ctx)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(claims.Session), []byte(j.sessionHash(key, epoch, session))) {
+		return ErrMismatch
+	}
+	newest, oldest, err := a.acceptedRange(date)
+	if err != nil {
+		return err
+	}
+	if claims.Counter < oldest {
+		return ErrExpired
+	}
+	if claims.Counter > newest {
+		return ErrMismatch
+	}
+	return a.checkUse(ctx, date, claims.Counter, token)
+}
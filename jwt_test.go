@@ -0,0 +1,152 @@
+package csrf
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forgeJWT returns a JWT with header signed by key under newHash, with
+// valid claims for testSession.
+func forgeJWT(t *testing.T, j *JWT, header jwtHeader, key []byte, newHash func() hash.Hash) string {
+	t.Helper()
+	a := j.Authenticator
+	epoch, err := a.epochBytes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := a.now()
+	counter, err := a.counter(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = j.signingKey(key)
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(jwtClaims{
+		Session: j.sessionHash(key, epoch, testSession),
+		Counter: counter,
+		Salt:    "c2FsdA",
+		Expiry:  now.Add(a.Lifetime).Unix(),
+	})
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	if newHash == nil {
+		return signed + "."
+	}
+	return signed + "." + j.sign(newHash, key, signed)
+}
+
+func TestJWT(t *testing.T) {
+	for _, alg := range []string{"", JWTHS256, JWTHS512} {
+		j := &JWT{Authenticator: testAuthenticator(t), Algorithm: alg}
+		now := time.Now()
+		token, err := j.GenerateToken(now, testSession)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var header jwtHeader
+		b, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+		if err := json.Unmarshal(b, &header); err != nil {
+			t.Fatal(err)
+		}
+		want := alg
+		if want == "" {
+			want = JWTHS512
+		}
+		if header.Alg != want || header.Typ != "JWT" {
+			t.Fatalf("%q: got header %+v", alg, header)
+		}
+		if err := j.ValidateToken(now, testSession, token); err != nil {
+			t.Fatalf("%q: %v", alg, err)
+		}
+		if err := j.ValidateToken(now, []byte("fedcba9876543210fedcba9876543210"), token); !errors.Is(err, ErrMismatch) {
+			t.Fatalf("%q, other session: got %v, want %v", alg, err, ErrMismatch)
+		}
+		if err := j.ValidateToken(now.Add(3*j.Authenticator.Lifetime), testSession, token); !errors.Is(err, ErrExpired) {
+			t.Fatalf("%q, expired: got %v, want %v", alg, err, ErrExpired)
+		}
+	}
+	j := &JWT{Authenticator: testAuthenticator(t), Algorithm: "RS256"}
+	if _, err := j.GenerateToken(time.Now(), testSession); !errors.Is(err, ErrJWTAlgorithm) {
+		t.Fatalf("RS256: got %v, want %v", err, ErrJWTAlgorithm)
+	}
+	if err := j.ValidateToken(time.Now(), testSession, "a.b.c"); !errors.Is(err, ErrJWTAlgorithm) {
+		t.Fatalf("RS256: got %v, want %v", err, ErrJWTAlgorithm)
+	}
+}
+
+func TestJWTAlgorithmRejection(t *testing.T) {
+	j := &JWT{Authenticator: testAuthenticator(t), Algorithm: JWTHS512}
+	key := j.Authenticator.Key
+	if err := j.ValidateToken(time.Time{}, testSession, forgeJWT(t, j, jwtHeader{Alg: JWTHS512, Typ: "JWT"}, key, sha512.New)); err != nil {
+		t.Fatalf("forgeJWT() makes invalid tokens: %v", err)
+	}
+	for _, tt := range []struct {
+		name    string
+		alg     string
+		newHash func() hash.Hash
+	}{
+		{"none", "none", nil},
+		{"empty", "", nil},
+		{"other HMAC", JWTHS256, sha256.New},
+		{"lower case", "hs512", sha512.New},
+		{"asymmetric", "RS256", sha512.New},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			token := forgeJWT(t, j, jwtHeader{Alg: tt.alg, Typ: "JWT"}, key, tt.newHash)
+			if err := j.ValidateToken(time.Time{}, testSession, token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!.!.!"} {
+		if err := j.ValidateToken(time.Time{}, testSession, token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%q: got %v, want %v", token, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestJWTKeyID(t *testing.T) {
+	a := testAuthenticator(t)
+	a.KeyID = 'a'
+	secondary := make([]byte, MinKeyLength)
+	a.SecondaryKeys = []SecondaryKey{{ID: 'b', Key: secondary}}
+	j := &JWT{Authenticator: a}
+	token, err := j.GenerateToken(time.Time{}, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.ValidateToken(time.Time{}, testSession, token); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		kid  string
+		key  []byte
+		want error
+	}{
+		{"secondary key", "b", secondary, nil},
+		{"secondary key under the primary kid", "a", secondary, ErrMismatch},
+		{"primary key under the secondary kid", "b", a.Key, ErrMismatch},
+		{"unknown kid", "c", a.Key, ErrMismatch},
+		{"no kid", "", a.Key, ErrMismatch},
+		{"long kid", "aa", a.Key, ErrMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			token := forgeJWT(t, j, jwtHeader{Alg: JWTHS512, Typ: "JWT", Kid: tt.kid}, tt.key, sha512.New)
+			err := j.ValidateToken(time.Time{}, testSession, token)
+			if tt.want == nil && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package csrf
+
+import "sync/atomic"
+
+// WithKeyCopy() makes NewAuthenticator() copy Key, the SecondaryKeys and
+// Pepper into buffers the Authenticator owns, so Close() can zero them and
+// no caller slice is retained. MACs are not pooled in this mode, since the
+// pools keep their keys for the process lifetime. Keys from a KeyProvider
+// are not copied.
+func WithKeyCopy() AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.ownsKeys = true
+	}
+}
+
+// copyKeys replaces the key slices with private copies.
+func (a *Authenticator) copyKeys() {
+	a.Key = append([]byte(nil), a.Key...)
+	a.SecondaryKeys = copySecondaryKeys(a.SecondaryKeys)
+	if a.Pepper != nil {
+		a.Pepper = append([]byte(nil), a.Pepper...)
+	}
+}
+
+// Close() makes every method fail with ErrClosed and, with WithKeyCopy(),
+// zeroes the key copies. Call it at shutdown, once no requests are in
+// flight; closing an Authenticator that is still in use is a data race.
+// Close() does not zero keys it does not own, but it drops its references
+// to them.
+//
+// The copies made by Freeze(), WithOverrides(), Confirmer and the like
+// share the keys of a, including those installed by SetKeys(). Without
+// WithKeyCopy() they keep working after Close(). With it, Close() zeroes
+// the keys they use too, so it closes them as well, and they fail with
+// ErrClosed rather than serve tokens under a zeroed key.
+func (a *Authenticator) Close() error {
+	if !atomic.CompareAndSwapInt32(&a.closed, 0, 1) {
+		return nil
+	}
+	if a.ownsKeys {
+		atomic.StoreInt32(&a.keyRotation().closed, 1)
+		zero(a.Key)
+		for _, k := range a.SecondaryKeys {
+			zero(k.Key)
+		}
+		if s := a.rotated(); s != nil {
+			zero(s.key)
+			for _, k := range s.secondary {
+				zero(k.Key)
+			}
+		}
+		zero(a.Pepper)
+	}
+	a.Key, a.SecondaryKeys, a.Pepper = nil, nil, nil
+	// copies keep the rotation, but a stops referencing it
+	atomic.StorePointer(&a.rotation, nil)
+	return nil
+}
+
+// isClosed reports whether Close() has been called on a or, with
+// WithKeyCopy(), on an Authenticator a shares its keys with.
+func (a *Authenticator) isClosed() bool {
+	if atomic.LoadInt32(&a.closed) != 0 {
+		return true
+	}
+	p := atomic.LoadPointer(&a.rotation)
+	return p != nil && atomic.LoadInt32(&(*keyRotation)(p).closed) != 0
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
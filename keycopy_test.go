@@ -0,0 +1,84 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCloseCopies(t *testing.T) {
+	key := make([]byte, MinKeyLength)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	rotatedKey := make([]byte, MinKeyLength)
+	for i := range rotatedKey {
+		rotatedKey[i] = byte(i + 1)
+	}
+	tests := []struct {
+		name    string
+		copy    bool
+		setKeys bool
+	}{
+		{name: "shared keys"},
+		{name: "shared keys, rotated", setKeys: true},
+		{name: "key copy", copy: true},
+		{name: "key copy, rotated", copy: true, setKeys: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []AuthenticatorOption
+			if tt.copy {
+				opts = append(opts, WithKeyCopy())
+			}
+			a, err := NewAuthenticator(key, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setKeys {
+				if err := a.SetKeys(rotatedKey); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := time.Now()
+			token, err := a.GenerateTokenErr(now, testSession)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frozen := Freeze(a)
+			clone := a.clone()
+
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.GenerateTokenErr(now, testSession); !errors.Is(err, ErrClosed) {
+				t.Fatalf("closed Authenticator: got %v, want %v", err, ErrClosed)
+			}
+
+			// a token made under the zeroed key must never validate
+			forged := &Authenticator{Key: make([]byte, MinKeyLength), TokenLength: a.TokenLength, Lifetime: a.Lifetime}
+			forgedToken, err := forged.GenerateTokenErr(now, testSession)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, check := range map[string]func(string) error{
+				"Freeze()": func(token string) error {
+					return frozen.ValidateTokenCtx(context.Background(), now, testSession, token)
+				},
+				"clone()": func(token string) error { return clone.ValidateTokenErr(now, testSession, token) },
+			} {
+				if err := check(forgedToken); err == nil {
+					t.Errorf("%s accepted a token under the zeroed key", name)
+				}
+				err := check(token)
+				if tt.copy && !errors.Is(err, ErrClosed) {
+					t.Errorf("%s with WithKeyCopy(): got %v, want %v", name, err, ErrClosed)
+				}
+				if !tt.copy && err != nil {
+					t.Errorf("%s without WithKeyCopy(): got %v, want nil", name, err)
+				}
+			}
+		})
+	}
+}
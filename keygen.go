@@ -0,0 +1,61 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrKeyEncoding is returned by LoadKeyHex() and LoadKeyBase64() for
+// strings that do not decode.
+var ErrKeyEncoding = errors.New("csrf: malformed key encoding")
+
+// GenerateKey() returns n random bytes from crypto/rand for use as a Key,
+// or ErrKeyLength if n is less than MinKeyLength. Store the result, hex or
+// base64 encoded, in a secret store instead of typing a key by hand.
+func GenerateKey(n int) ([]byte, error) {
+	if n < MinKeyLength {
+		return nil, ErrKeyLength
+	}
+	key := make([]byte, n)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadKeyHex() decodes a hex key, such as one from an environment
+// variable, ignoring surrounding whitespace. It returns ErrKeyLength for
+// keys shorter than MinKeyLength.
+func LoadKeyHex(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, ErrKeyEncoding
+	}
+	return checkKey(key)
+}
+
+// LoadKeyBase64() decodes a standard or URL-safe base64 key, with or
+// without padding, ignoring surrounding whitespace. It returns
+// ErrKeyLength for keys shorter than MinKeyLength.
+func LoadKeyBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	encoding := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		encoding = base64.URLEncoding
+	}
+	key, err := encoding.WithPadding(base64.NoPadding).DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, ErrKeyEncoding
+	}
+	return checkKey(key)
+}
+
+func checkKey(key []byte) ([]byte, error) {
+	if len(key) < MinKeyLength {
+		return nil, ErrKeyLength
+	}
+	return key, nil
+}
@@ -0,0 +1,128 @@
+package csrf
+
+import "context"
+
+// SecondaryKey is a verification-only key kept during rotation.
+type SecondaryKey struct {
+	// ID is the KeyID the key had while it was the primary key, or 0 if
+	// key IDs were not in use
+	ID  byte
+	Key []byte
+}
+
+// KeyProvider supplies keys from an external system such as Vault, a KMS
+// or an HSM, and may change its current key at any time. Key IDs are
+// embedded in tokens, so they must consist of token alphabet characters
+// and all have the same length; providers with long native IDs should map
+// them to short ones. An empty ID embeds nothing and only the current key
+// is accepted.
+type KeyProvider interface {
+	// CurrentKey returns the key used for new tokens and its ID.
+	CurrentKey() (id string, key []byte)
+	// KeyByID returns the key with the given ID, or nil if it is unknown
+	// or retired.
+	KeyByID(id string) []byte
+}
+
+// KeyProviderContext is implemented by providers that look up keys in a
+// remote system and can honor cancellation. KeyByIDContext() is used
+// instead of KeyByID() when available, so a slow KMS respects the request
+// deadline, and an error is returned to the caller instead of being taken
+// for an unknown key.
+type KeyProviderContext interface {
+	KeyByIDContext(ctx context.Context, id string) ([]byte, error)
+}
+
+// primaryKey returns the key for new tokens and the ID to prefix them with.
+func (a *Authenticator) primaryKey() (string, []byte) {
+	id, key, _ := a.currentKeys()
+	return id, key
+}
+
+// currentKeys returns the primary key, its ID and the secondary keys, read
+// together so a concurrent SetKeys() cannot mix old and new keys.
+func (a *Authenticator) currentKeys() (string, []byte, []SecondaryKey) {
+	if a.Keys != nil {
+		id, key := a.Keys.CurrentKey()
+		return id, key, nil
+	}
+	key, secondary := a.staticKeys()
+	if a.KeyID != 0 {
+		return string(a.KeyID), key, secondary
+	}
+	return "", key, secondary
+}
+
+// tokenLength returns the length of a complete token, including the key ID
+// and window character.
+func (a *Authenticator) tokenLength() int {
+	id, _ := a.primaryKey()
+	if a.EmbedWindow {
+		return a.TokenLength + len(id) + 1
+	}
+	return a.TokenLength + len(id)
+}
+
+// saltLength returns SaltLength, or its default of half of TokenLength if
+// it is unset or out of range, which CheckConfig() reports.
+func (a *Authenticator) saltLength() int {
+	if a.SaltLength <= 0 || a.SaltLength >= a.TokenLength {
+		return a.TokenLength / 2
+	}
+	return a.SaltLength
+}
+
+// verificationKeys returns the keys that may have generated token and the
+// token without its key ID. With key IDs, at most one key is returned.
+func (a *Authenticator) verificationKeys(ctx context.Context, token string) ([][]byte, string, error) {
+	currentID, currentKey, secondary := a.currentKeys()
+	if currentID == "" {
+		keys := [][]byte{currentKey}
+		for _, k := range secondary {
+			keys = append(keys, k.Key)
+		}
+		return keys, token, nil
+	}
+
+	id, body := token[:len(currentID)], token[len(currentID):]
+	if id == currentID {
+		keys := [][]byte{currentKey}
+		for _, k := range secondary {
+			if string(k.ID) == id {
+				// keys rotated by SetKeys() share the KeyID
+				keys = append(keys, k.Key)
+			}
+		}
+		return keys, body, nil
+	}
+	if c, ok := a.Keys.(KeyProviderContext); ok {
+		key, err := c.KeyByIDContext(ctx, id)
+		if err != nil || key == nil {
+			return nil, body, err
+		}
+		return [][]byte{key}, body, nil
+	}
+	if a.Keys != nil {
+		if key := a.Keys.KeyByID(id); key != nil {
+			return [][]byte{key}, body, nil
+		}
+		return nil, body, nil
+	}
+	for _, k := range secondary {
+		if string(k.ID) == id {
+			return [][]byte{k.Key}, body, nil
+		}
+	}
+	return nil, body, nil
+}
+
+func copySecondaryKeys(keys []SecondaryKey) []SecondaryKey {
+	if keys == nil {
+		return nil
+	}
+	c := make([]SecondaryKey, len(keys))
+	for i, k := range keys {
+		c[i] = SecondaryKey{ID: k.ID, Key: append([]byte(nil), k.Key...)}
+	}
+	return c
+}
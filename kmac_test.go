@@ -0,0 +1,69 @@
+//go:build go1.24
+
+package csrf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestKMAC256 checks the KMAC256 samples of NIST SP 800-185, from
+// https://csrc.nist.gov/projects/cryptographic-standards-and-guidelines/example-values
+func TestKMAC256(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = 0x40 + byte(i)
+	}
+	short := []byte{0x00, 0x01, 0x02, 0x03}
+	long := make([]byte, 200)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	tests := []struct {
+		name          string
+		data          []byte
+		customization string
+		want          string
+	}{
+		{
+			name:          "sample 4",
+			data:          short,
+			customization: "My Tagged Application",
+			want:          "20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd",
+		},
+		{
+			name: "sample 5",
+			data: long,
+			want: "75358cf39e41494e949707927cee0af20a3ff553904c86b08f21cc414bcfd691589d27cf5e15369cbbff8b9a4c2eb17800855d0235ff635da82533ec6b759b69",
+		},
+		{
+			name:          "sample 6",
+			data:          long,
+			customization: "My Tagged Application",
+			want:          "b58618f71f92e1d56c1b8c55ddd7cd188b97b4ca4d99831eb2699a837da2e4d970fbacfde50033aea585f1a2708510c32d07880801bd182898fe476876fc8965",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := hex.DecodeString(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k := newKMAC256(key, []byte(tt.customization), len(want))
+			k.Write(tt.data)
+			if got := k.Sum(nil); !bytes.Equal(got, want) {
+				t.Fatalf("got %x, want %x", got, want)
+			}
+			// Sum() leaves the state intact and Reset() restores it
+			if got := k.Sum(nil); !bytes.Equal(got, want) {
+				t.Fatalf("second Sum(): got %x, want %x", got, want)
+			}
+			k.Reset()
+			k.Write(tt.data)
+			if got := k.Sum(nil); !bytes.Equal(got, want) {
+				t.Fatalf("after Reset(): got %x, want %x", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package csrf
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives diagnostics about rejected tokens and failed
+// generation, as a message followed by alternating keys and values.
+// *slog.Logger implements it. Loggers that also have an Info() method
+// with the same signature, as *slog.Logger does, receive informational
+// messages such as the token strength Protect() reports at startup.
+type Logger interface {
+	Warn(msg string, args ...interface{})
+}
+
+// infoLogger is implemented by Loggers with an Info level.
+type infoLogger interface {
+	Info(msg string, args ...interface{})
+}
+
+// stdLogger writes to the standard log package, for Authenticators
+// without a Logger.
+type stdLogger struct{}
+
+func (l stdLogger) Info(msg string, args ...interface{}) {
+	l.Warn(msg, args...)
+}
+
+func (stdLogger) Warn(msg string, args ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Print(b.String())
+}
+
+func (a *Authenticator) logger() Logger {
+	if a.Logger != nil {
+		return a.Logger
+	}
+	return stdLogger{}
+}
+
+// info logs msg at the Info level, if the Logger has one.
+func (a *Authenticator) info(msg string, args ...interface{}) {
+	if l, ok := a.logger().(infoLogger); ok {
+		l.Info(msg, args...)
+	}
+}
@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"crypto/subtle"
+
+	"github.com/foobaz/csrf/internal/core"
+)
+
+// firstInvalid returns the first of offset and bad that is not negative,
+// without branching, given that bad is -1 or less than offset.
+func firstInvalid(bad, offset, index int) int {
+	invalid := subtle.ConstantTimeEq(int32(index), -1) & subtle.ConstantTimeEq(int32(bad), -1)
+	return subtle.ConstantTimeSelect(invalid, offset, bad)
+}
+
+// MaskToken() returns token masked with a fresh one-time pad, so the same
+// token never appears twice in responses and cannot be recovered by a
+// compression oracle such as BREACH. The result is a random pad followed
+// by the token with each character shifted by the pad character, twice as
+// long as token. Setting Mask does this for every generated token; call
+// MaskToken() directly to re-mask a token that is embedded in more than
+// one response.
+func (a *Authenticator) MaskToken(token string) (string, error) {
+	token = a.canonicalToken(token)
+	pad, err := a.salt(len(token))
+	if err != nil {
+		return "", err
+	}
+	alphabet := a.alphabet()
+	masked := make([]byte, 2*len(token))
+	copy(masked, pad)
+	for i := 0; i < len(token); i++ {
+		c := core.AlphabetIndex(alphabet, token[i])
+		if c < 0 {
+			return "", &MalformedTokenError{Length: len(token), Offset: i, Char: token[i]}
+		}
+		p := core.AlphabetIndex(alphabet, pad[i])
+		masked[len(token)+i] = alphabet[(c+p)%len(alphabet)]
+	}
+	return string(masked), nil
+}
+
+// unmask reverses MaskToken().
+func (a *Authenticator) unmask(masked string) (string, error) {
+	alphabet := a.alphabet()
+	n, size := len(masked)/2, len(alphabet)
+	token := make([]byte, n)
+	badPad, badToken := -1, -1
+	for i := 0; i < n; i++ {
+		p := core.AlphabetIndex(alphabet, masked[i])
+		c := core.AlphabetIndex(alphabet, masked[n+i])
+		badPad = firstInvalid(badPad, i, p)
+		badToken = firstInvalid(badToken, n+i, c)
+		// invalid characters are treated as the first one, to finish
+		// the loop without branching on the token
+		p = subtle.ConstantTimeSelect(subtle.ConstantTimeEq(int32(p), -1), 0, p)
+		c = subtle.ConstantTimeSelect(subtle.ConstantTimeEq(int32(c), -1), 0, c)
+		shift := subtle.ConstantTimeSelect(subtle.ConstantTimeLessOrEq(p, c), c-p, c-p+size)
+		token[i] = alphabet[shift]
+	}
+	if badPad >= 0 {
+		return "", &MalformedTokenError{Length: len(masked), Offset: badPad, Char: masked[badPad]}
+	}
+	if badToken >= 0 {
+		return "", &MalformedTokenError{Length: len(masked), Offset: badToken, Char: masked[badToken]}
+	}
+	return string(token), nil
+}
+
+// unmaskToken unmasks token if it has the length of a masked token in
+// Mask mode, and otherwise returns it unchanged.
+func (a *Authenticator) unmaskToken(token string) (string, error) {
+	if !a.Mask || len(token) != 2*a.tokenLength() {
+		return token, nil
+	}
+	return a.unmask(token)
+}
+
+// plain returns a without Mask or Versioned, for formats that split
+// tokens by length.
+func (a *Authenticator) plain() *Authenticator {
+	if !a.Mask && !a.Versioned {
+		return a
+	}
+	b := a.clone()
+	b.Mask = false
+	b.Versioned = false
+	return b
+}
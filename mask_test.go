@@ -0,0 +1,103 @@
+package csrf
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaskRoundTrip(t *testing.T) {
+	a := testAuthenticator(t)
+	a.Mask = true
+	now := time.Now()
+	first, err := a.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("two masked tokens are the same")
+	}
+	if len(first) != 2*a.tokenLength() {
+		t.Fatalf("got masked length %d, want %d", len(first), 2*a.tokenLength())
+	}
+	for _, token := range []string{first, second} {
+		if err := a.ValidateTokenErr(now, testSession, token); err != nil {
+			t.Fatalf("%s: %v", token, err)
+		}
+	}
+	plain, err := a.unmask(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{first: true, second: true}
+	for i := 0; i < 8; i++ {
+		masked, err := a.MaskToken(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[masked] {
+			t.Fatal("MaskToken() repeated a pad")
+		}
+		seen[masked] = true
+		if unmasked, err := a.unmask(masked); err != nil || unmasked != plain {
+			t.Fatalf("unmask(MaskToken()) = %q, %v, want %q", unmasked, err, plain)
+		}
+		if err := a.ValidateTokenErr(now, testSession, masked); err != nil {
+			t.Fatalf("MaskToken(): %v", err)
+		}
+	}
+}
+
+func TestMaskTamper(t *testing.T) {
+	a := testAuthenticator(t)
+	a.Mask = true
+	now := time.Now()
+	token, err := a.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(token) / 2
+	alphabet := a.alphabet()
+	// change replaces the character at i with the next one of the
+	// alphabet
+	change := func(i int) string {
+		b := []byte(token)
+		for j, c := range alphabet {
+			if c == b[i] {
+				b[i] = alphabet[(j+1)%len(alphabet)]
+				break
+			}
+		}
+		return string(b)
+	}
+	for _, tt := range []struct {
+		name  string
+		token string
+	}{
+		{"pad", change(0)},
+		{"end of pad", change(n - 1)},
+		{"masked token", change(n)},
+		{"end of masked token", change(len(token) - 1)},
+		{"swapped halves", token[n:] + token[:n]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.ValidateTokenErr(now, testSession, tt.token); err == nil {
+				t.Fatal("tampered token validated")
+			}
+		})
+	}
+
+	bad := []byte(token)
+	bad[n+2] = '!'
+	var malformed *MalformedTokenError
+	if err := a.ValidateTokenErr(now, testSession, string(bad)); !errors.As(err, &malformed) || malformed.Offset != n+2 {
+		t.Fatalf("invalid character: got %v, want a MalformedTokenError at %d", err, n+2)
+	}
+	if err := a.ValidateTokenErr(now, testSession, token[:len(token)-1]); err == nil {
+		t.Fatal("truncated token validated")
+	}
+}
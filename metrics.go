@@ -0,0 +1,114 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics receives counts of token generation and validation outcomes,
+// for example to export CSRF rejection rates to a monitoring system. The
+// csrfprom module adapts it to Prometheus. Methods are called
+// concurrently and should not block.
+type Metrics interface {
+	// TokenGenerated is called for every token generated.
+	TokenGenerated()
+	// TokenValidated is called for every token that validates.
+	TokenValidated()
+	// TokenRejected is called for every token that does not, with the
+	// Reason() for the error.
+	TokenRejected(reason string)
+}
+
+// Reason() returns a short, fixed label for a validation error, suitable
+// as a metrics label: "missing_token", "missing_cookie", "wrong_length",
+// "invalid_character", "expired", "mismatch", "empty_session",
+// "short_session", "session_revoked", "token_revoked", "replayed",
+// "cross_site", "untrusted_origin", "missing_origin", "unknown_tenant",
+// "canceled", or "error" for anything else, such as a failing EpochSource.
+func Reason(err error) string {
+	var malformed *MalformedTokenError
+	switch {
+	case errors.As(err, &malformed) && malformed.Length == 0:
+		return "missing_token"
+	case errors.Is(err, http.ErrNoCookie):
+		return "missing_cookie"
+	case errors.Is(err, ErrWrongLength):
+		return "wrong_length"
+	case errors.Is(err, ErrInvalidCharacter):
+		return "invalid_character"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	case errors.Is(err, ErrMismatch):
+		return "mismatch"
+	case errors.Is(err, ErrEmptySession):
+		return "empty_session"
+	case errors.Is(err, ErrShortSession):
+		return "short_session"
+	case errors.Is(err, ErrSessionRevoked):
+		return "session_revoked"
+	case errors.Is(err, ErrTokenRevoked):
+		return "token_revoked"
+	case errors.Is(err, ErrTokenReplayed):
+		return "replayed"
+	case errors.Is(err, ErrCrossSite):
+		return "cross_site"
+	case errors.Is(err, ErrUntrustedOrigin):
+		return "untrusted_origin"
+	case errors.Is(err, ErrMissingOrigin):
+		return "missing_origin"
+	case errors.Is(err, ErrUnknownTenant):
+		return "unknown_tenant"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "error"
+}
+
+// CountingMetrics is a Metrics keeping running totals in memory, for
+// AdminHandler and tests. The zero value is ready to use.
+type CountingMetrics struct {
+	generated uint64
+	validated uint64
+
+	mutex    sync.Mutex
+	rejected map[string]uint64
+}
+
+// Counts are the totals of a CountingMetrics.
+type Counts struct {
+	Generated uint64 `json:"generated"`
+	Validated uint64 `json:"validated"`
+	// Rejected counts rejections by Reason().
+	Rejected map[string]uint64 `json:"rejected"`
+}
+
+func (c *CountingMetrics) TokenGenerated() { atomic.AddUint64(&c.generated, 1) }
+
+func (c *CountingMetrics) TokenValidated() { atomic.AddUint64(&c.validated, 1) }
+
+func (c *CountingMetrics) TokenRejected(reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.rejected == nil {
+		c.rejected = make(map[string]uint64)
+	}
+	c.rejected[reason]++
+}
+
+// Counts() returns the totals so far.
+func (c *CountingMetrics) Counts() Counts {
+	counts := Counts{
+		Generated: atomic.LoadUint64(&c.generated),
+		Validated: atomic.LoadUint64(&c.validated),
+		Rejected:  make(map[string]uint64),
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for reason, n := range c.rejected {
+		counts.Rejected[reason] = n
+	}
+	return counts
+}
@@ -0,0 +1,489 @@
+package csrf
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TokenHeader is the header the middleware sets on responses to safe
+// requests and reads from unsafe requests.
+const TokenHeader = "X-CSRF-Token"
+
+// TokenField is the form field the middleware reads tokens from.
+const TokenField = "csrf_token"
+
+// ExpiryHeader is the header WithExpiryHeader() sets by default.
+const ExpiryHeader = "X-CSRF-Expires"
+
+// WithExpiryHeader() makes Protect() send, with every token it issues, a
+// header holding the whole seconds the token stays valid, so front ends
+// can schedule a refresh instead of waiting for a rejection. The header
+// is name, or ExpiryHeader if name is empty.
+func WithExpiryHeader(name string) Option {
+	return func(m *Middleware) {
+		if name == "" {
+			name = ExpiryHeader
+		}
+		m.ExpiryHeader = name
+	}
+}
+
+// Middleware is the HTTP glue around an Authenticator. Safe requests (GET,
+// HEAD, OPTIONS, TRACE) get a fresh token for their session, in the
+// request context and the X-CSRF-Token response header. Other requests,
+// such as POST, PUT, PATCH and DELETE, must carry a valid token in the
+// X-CSRF-Token header or csrf_token form field, or wherever TokenHeaders
+// and TokenSources say, or they are rejected with 403 Forbidden, or only
+// logged with WithReportOnly(). CORS preflights pass untouched, without
+// a token, unless WithCORS() answers them.
+type Middleware struct {
+	Authenticator *Authenticator
+	// Session returns the session binding for a request. If nil, tokens
+	// are bound to an empty session, which is only safe for Strict
+	// Authenticators with another binding.
+	Session SessionExtractor
+	// DoubleSubmit, if set, replaces session-bound tokens with double
+	// submit cookies, and Session is ignored.
+	DoubleSubmit *DoubleSubmit
+	// BindAction requires tokens made by GenerateTokenFor() for the
+	// method and path of each unsafe request. Safe requests are issued
+	// a token for a POST to their own path. It does not apply to
+	// DoubleSubmit.
+	BindAction bool
+	// Observer, if set, is called with the outcome of every unsafe
+	// request's validation, for tracing or custom metrics.
+	Observer func(r *http.Request, v Validation)
+	// ExemptPaths and Skip exempt unsafe requests from the token check,
+	// in addition to the safe methods; see WithExemptPaths() and
+	// WithSkip(). Safe requests are still issued tokens.
+	ExemptPaths []string
+	Skip        SkipFunc
+	// ErrorHandler, if set, answers requests that fail validation
+	// instead of a plain text 403 Forbidden.
+	ErrorHandler http.Handler
+	// FetchMetadata and Origins, if set, are checked in that order
+	// before the token of unsafe requests.
+	FetchMetadata *FetchMetadata
+	Origins       *OriginChecker
+	// TokenHeaders and TokenSources say where submitted tokens are
+	// looked for; see WithTokenHeaders() and WithTokenSources(). By
+	// default the X-CSRF-Token header is tried, then the csrf_token
+	// field of a form or multipart body, then JSONField if it is set.
+	TokenHeaders []string
+	TokenSources []TokenSource
+	// FormField is the form and multipart field tokens are read from,
+	// csrf_token by default. At most MaxMultipartMemory bytes of the
+	// body, DefaultMaxMultipartMemory if zero, are read to find it.
+	FormField          string
+	MaxMultipartMemory int64
+	// JSONField is the member of a JSON body tokens are read from by
+	// FromJSON; see WithJSONField().
+	JSONField string
+	// StreamUploads looks for tokens in the header and query before the
+	// body, and never reads multipart bodies past the first file; see
+	// WithStreamingUploads().
+	StreamUploads bool
+	// Tenants, if set, supplies the Authenticator for each request by
+	// the tenant Tenant returns; see WithTenants().
+	Tenants *AuthenticatorSet
+	Tenant  TenantExtractor
+	// InjectForms adds the token to POST forms in HTML responses; see
+	// WithFormInjection().
+	InjectForms bool
+	// Pool, if set, supplies tokens minted ahead of time; see TokenPool
+	Pool *TokenPool
+	// Failures and OnFailure, if both set, count validation failures per
+	// session and report them; see WithFailureHook().
+	Failures  FailureCounter
+	OnFailure FailureHook
+	// Sliding reissues tokens from past windows; see
+	// WithSlidingExpiration().
+	Sliding bool
+	// Refresh reissues tokens with less than this fraction of their
+	// lifetime left to requests for HTML; see WithRefresh().
+	Refresh float64
+	// WebSockets checks the token of WebSocket handshakes; see
+	// WithWebSocketCheck().
+	WebSockets bool
+	// SessionCookie, if set, mints the session binding into a cookie;
+	// see WithSessionCookie().
+	SessionCookie *SessionCookie
+	// Frozen, if set, supplies the Authenticator for each request from
+	// its current configuration; see WithFrozenAuthenticator().
+	Frozen *FrozenAuthenticator
+	// CORS, if set, answers the CORS requests of trusted origins; see
+	// WithCORS().
+	CORS *CORS
+	// ExpiryHeader, if set, names the response header carrying how many
+	// seconds an issued token stays valid; see WithExpiryHeader().
+	ExpiryHeader string
+	// EarlyHints, if set, is the path of the token endpoint preloaded
+	// with 103 Early Hints; see WithEarlyHints().
+	EarlyHints string
+	// Debug describes every decision in response headers; see
+	// WithDebugHeaders(). Never set it in production.
+	Debug bool
+
+	next          http.Handler
+	mode          *enforcement
+	cookieOptions []CookieOption
+	presetCookie  []CookieOption
+	overrides     []AuthenticatorOption
+}
+
+// Option configures a Middleware.
+type Option func(*Middleware)
+
+// WithAuthenticator() sets the Authenticator that generates and validates
+// tokens. It is required.
+func WithAuthenticator(a *Authenticator) Option {
+	return func(m *Middleware) {
+		m.Authenticator = a
+	}
+}
+
+// WithSession() sets the function extracting the session binding from a
+// request, such as SessionFromCookie() or SessionFromContext() for an
+// authenticated user ID.
+func WithSession(session SessionExtractor) Option {
+	return func(m *Middleware) {
+		m.Session = session
+	}
+}
+
+// WithDoubleSubmit() protects requests with double-submit cookies, for
+// applications without server-side sessions. It also sets the
+// Authenticator, unless one was given.
+func WithDoubleSubmit(d *DoubleSubmit) Option {
+	return func(m *Middleware) {
+		m.DoubleSubmit = d
+		if m.Authenticator == nil {
+			m.Authenticator = d.Authenticator
+		}
+	}
+}
+
+// WithActionBinding() requires tokens bound to the method and path of
+// each request, for routes where a token leaked from another form must
+// not be accepted. Wrap only those routes to enable it per route.
+func WithActionBinding() Option {
+	return func(m *Middleware) {
+		m.BindAction = true
+	}
+}
+
+// WithObserver() sets a function called with the outcome of every unsafe
+// request's validation, such as one from the otelcsrf module.
+func WithObserver(observer func(r *http.Request, v Validation)) Option {
+	return func(m *Middleware) {
+		m.Observer = observer
+	}
+}
+
+// Validation describes how Protect() judged an unsafe request.
+type Validation struct {
+	// Err is why the request was rejected, or nil if it passed
+	Err error
+	// Age bounds how long ago a valid token was generated from above:
+	// the windows since its own began plus the elapsed part of the
+	// current one
+	Age time.Duration
+}
+
+// Protect() wraps next with CSRF protection. It panics if neither an
+// Authenticator nor WithTenants() is given, WithFrozenAuthenticator() is
+// combined with either, an exempt path pattern is malformed, WithCookie()
+// is used without WithDoubleSubmit(), or WithOverrides() without an
+// Authenticator or with options CheckConfig() rejects, so a
+// misconfiguration fails at startup. It logs the EffectiveBits() of the
+// Authenticator at the Info level of its Logger.
+func Protect(next http.Handler, opts ...Option) http.Handler {
+	m := &Middleware{next: next, mode: &enforcement{}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.Frozen != nil {
+		m.checkFrozen()
+	}
+	if m.CORS != nil {
+		m.checkCORS()
+	}
+	if m.Refresh < 0 || m.Refresh >= 1 {
+		panic("csrf: WithRefresh() fraction must be between 0 and 1")
+	}
+	if m.Authenticator == nil && m.Tenants == nil {
+		panic("csrf: Protect() requires WithAuthenticator()")
+	}
+	if m.Tenants != nil && m.Tenant == nil {
+		panic("csrf: Protect() requires a TenantExtractor with WithTenants()")
+	}
+	if err := m.checkPatterns(); err != nil {
+		panic("csrf: Protect() exempt path: " + err.Error())
+	}
+	m.applyOverrides()
+	m.applyCookieOptions()
+	if a := m.Authenticator; a != nil {
+		a.info("csrf: token strength", "bits", math.Round(a.EffectiveBits()*10)/10)
+	}
+	if m.Debug {
+		m.logger().Warn("csrf: debug headers enabled, do not use in production")
+	}
+	return m
+}
+
+// Wrap() returns Protect() as a func(http.Handler) http.Handler, the
+// middleware signature of routers such as chi. The options are checked
+// once, when Wrap() is called.
+func Wrap(opts ...Option) func(http.Handler) http.Handler {
+	m := Protect(nil, opts...).(*Middleware)
+	return func(next http.Handler) http.Handler {
+		wrapped := *m
+		wrapped.next = next
+		return &wrapped
+	}
+}
+
+type contextKey int
+
+const (
+	tokenKey  contextKey = 0
+	expiryKey contextKey = 6
+)
+
+// Token() returns the token Protect() issued for a safe request, or the
+// one an unsafe request passed with, so handlers re-rendering a form need
+// not make another. It returns "" if there is none, or if the token was
+// spent in UsedTokens.
+func Token(r *http.Request) string {
+	token, _ := r.Context().Value(tokenKey).(string)
+	return token
+}
+
+// Expiry() returns when the Token() of r stops validating, or the zero
+// time if there is none.
+func Expiry(r *http.Request) time.Time {
+	expiry, _ := r.Context().Value(expiryKey).(time.Time)
+	return expiry
+}
+
+func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, err := m.forRequest(r)
+	if err != nil {
+		if !m.checked(r) || m.exempt(r) {
+			m.next.ServeHTTP(w, r)
+			return
+		}
+		if m.ReportOnly() {
+			m.Tenants.logger().Warn("csrf: request would be rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+			m.next.ServeHTTP(w, r)
+			return
+		}
+		m.Tenants.logger().Warn("csrf: request rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+		m.fail(w, r, err)
+		return
+	}
+	t.serve(w, r)
+}
+
+// serve is ServeHTTP() with the Authenticator for the request.
+func (m *Middleware) serve(w http.ResponseWriter, r *http.Request) {
+	if m.CORS != nil && m.CORS.serve(w, r, m) {
+		return
+	}
+	if isPreflight(r) {
+		// preflights carry no credentials, so no session to issue a
+		// token for
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	now := m.Authenticator.now()
+	if !m.checked(r) {
+		if m.EarlyHints != "" && r.Method == http.MethodGet && acceptsHTML(r) {
+			m.hint(w)
+		}
+		if m.SessionCookie != nil {
+			r = m.SessionCookie.ensure(w, r)
+		}
+		r = m.issue(w, r, now)
+		if Token(r) != "" {
+			m.debugDecision(w, "issue")
+		}
+		if m.InjectForms && Token(r) != "" {
+			iw := &injectWriter{ResponseWriter: w, r: r}
+			m.next.ServeHTTP(iw, r)
+			iw.finish()
+			return
+		}
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if m.exempt(r) {
+		m.debugDecision(w, "exempt")
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	if m.Authenticator.Events != nil {
+		r = r.WithContext(context.WithValue(ctx, eventKey, true))
+	}
+	counter, token, err := m.check(r, now)
+	if m.Authenticator.Events != nil {
+		r = r.WithContext(ctx)
+		m.reportRequest(r, now, err)
+	}
+	if m.Observer != nil {
+		m.Observer(r, m.validation(now, counter, err))
+	}
+	m.debugCheck(w, r, now, counter, err)
+	if err != nil && m.ReportOnly() {
+		m.Authenticator.logger().Warn("csrf: request would be rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+		m.throttle(r, now, err)
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if err != nil {
+		m.Authenticator.logger().Warn("csrf: request rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+		m.throttle(r, now, err)
+		m.fail(w, r, err)
+		return
+	}
+	r = m.withToken(w, r, now, counter, token)
+	m.next.ServeHTTP(w, m.slide(w, r, now, counter))
+}
+
+// withToken adds the token an unsafe request passed with, from window
+// counter, and its expiry to the request context, and with BindAction the
+// means to make tokens for other forms.
+func (m *Middleware) withToken(w http.ResponseWriter, r *http.Request, now time.Time, counter int64, token string) *http.Request {
+	if m.Authenticator.UsedTokens != nil {
+		return r
+	}
+	ctx := context.WithValue(r.Context(), tokenKey, token)
+	if expiry, err := m.Authenticator.expiry(now, counter); err == nil {
+		ctx = context.WithValue(ctx, expiryKey, expiry)
+	}
+	ctx = m.withActionMinter(ctx, w, r, now)
+	return r.WithContext(ctx)
+}
+
+// issue adds a fresh token to the request context and response headers.
+func (m *Middleware) issue(w http.ResponseWriter, r *http.Request, now time.Time) *http.Request {
+	token, counter, err := m.token(w, r, now, http.MethodPost, r.URL.Path)
+	if err != nil {
+		m.Authenticator.logger().Warn("csrf: token generation failed", "reason", err)
+		return r
+	}
+	w.Header().Set(m.headers()[0], token)
+	ctx := context.WithValue(r.Context(), tokenKey, token)
+	if expiry, err := m.Authenticator.expiry(now, counter); err == nil {
+		ctx = context.WithValue(ctx, expiryKey, expiry)
+		if m.ExpiryHeader != "" {
+			w.Header().Set(m.ExpiryHeader, strconv.FormatInt(int64(expiry.Sub(now)/time.Second), 10))
+		}
+	}
+	if m.FormField != "" {
+		ctx = context.WithValue(ctx, fieldKey, m.FormField)
+	}
+	ctx = m.withActionMinter(ctx, w, r, now)
+	return r.WithContext(ctx)
+}
+
+// hint sends a 103 Early Hints response preloading EarlyHints.
+func (m *Middleware) hint(w http.ResponseWriter) {
+	w.Header().Add("Link", "<"+m.EarlyHints+">; rel=preload; as=fetch; crossorigin")
+	w.WriteHeader(http.StatusEarlyHints)
+}
+
+// token returns a token for r and the window it belongs to. With
+// BindAction, the token is for a request with method and path.
+func (m *Middleware) token(w http.ResponseWriter, r *http.Request, now time.Time, method, path string) (string, int64, error) {
+	if m.DoubleSubmit != nil {
+		return m.DoubleSubmit.token(w, r, now)
+	}
+	_, session, err := requestSession(m.Authenticator, m.Session, r)
+	if err != nil {
+		return "", 0, &sessionError{err}
+	}
+	counter, err := m.Authenticator.counter(now)
+	if err != nil {
+		return "", 0, err
+	}
+	var token string
+	if m.BindAction {
+		token, err = m.Authenticator.generateFor(r.Context(), now, session, method, path)
+	} else if m.Pool != nil && m.Pool.Authenticator == m.Authenticator {
+		token, err = m.Pool.Token(r.Context(), now, session)
+	} else {
+		token, err = m.Authenticator.GenerateTokenCtx(r.Context(), now, session)
+	}
+	return token, counter, err
+}
+
+// sessionError is a failure to find the session of a request, which the
+// client rather than the server is at fault for.
+type sessionError struct {
+	err error
+}
+
+func (e *sessionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+// isSessionError reports whether err comes from a missing or unusable
+// session rather than a server failure.
+func isSessionError(err error) bool {
+	var s *sessionError
+	return errors.As(err, &s) || errors.Is(err, ErrEmptySession) || errors.Is(err, ErrShortSession)
+}
+
+// check validates an unsafe request and returns its token and the window
+// the token was generated in.
+func (m *Middleware) check(r *http.Request, now time.Time) (int64, string, error) {
+	if m.FetchMetadata != nil {
+		if err := m.FetchMetadata.Check(r); err != nil {
+			return 0, "", err
+		}
+	}
+	if m.Origins != nil {
+		if err := m.Origins.Check(r); err != nil {
+			return 0, "", err
+		}
+	}
+	token := m.requestToken(r)
+	if m.DoubleSubmit != nil {
+		counter, err := m.DoubleSubmit.check(r, now, token)
+		return counter, token, err
+	}
+	raw, session, err := requestSession(m.Authenticator, m.Session, r)
+	if err != nil {
+		return 0, "", err
+	}
+	var counter int64
+	if m.BindAction {
+		counter, err = m.Authenticator.validateFor(r.Context(), now, raw, session, r.Method, r.URL.Path, token)
+	} else {
+		counter, err = m.Authenticator.validate(r.Context(), now, raw, session, token)
+	}
+	return counter, token, err
+}
+
+// validation describes the outcome of check for the Observer.
+func (m *Middleware) validation(now time.Time, counter int64, err error) Validation {
+	v := Validation{Err: err}
+	if err != nil {
+		return v
+	}
+	if current, remaining, err := m.Authenticator.window(now); err == nil && m.Authenticator.Lifetime > 0 {
+		v.Age = time.Duration(current-counter)*m.Authenticator.Lifetime + m.Authenticator.Lifetime - remaining
+	}
+	return v
+}
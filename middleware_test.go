@@ -0,0 +1,131 @@
+package csrf
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSessionOption = WithSession(func(*http.Request) ([]byte, error) { return testSession, nil })
+
+func TestExpiryHeader(t *testing.T) {
+	a := testAuthenticator(t)
+	now := time.Now()
+	a.Now = func() time.Time { return now }
+	counter, err := a.counter(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry, err := a.expiry(now, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "X-Token-TTL"} {
+		h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			WithAuthenticator(a), testSessionOption, WithExpiryHeader(name),
+			WithCORS(&CORS{Origins: &OriginChecker{TrustedOrigins: []string{"https://app.example"}}}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://app.example")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if name == "" {
+			name = ExpiryHeader
+		}
+		seconds, err := strconv.Atoi(w.Header().Get(name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, want := time.Duration(seconds)*time.Second, expiry.Sub(now).Truncate(time.Second); got != want {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+		if got, want := w.Header().Get("Access-Control-Expose-Headers"), TokenHeader+", "+name; got != want {
+			t.Fatalf("got Access-Control-Expose-Headers %q, want %q", got, want)
+		}
+	}
+}
+
+func TestProtectMatrix(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	now := time.Now()
+	a.Now = func() time.Time { return now }
+	other := []byte("fedcba9876543210fedcba9876543210")
+	revoked := []byte("0000000000000000revoked000000000")
+	revoke(t, revocations, revoked)
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		WithAuthenticator(a), WithExemptPaths("/hooks/"),
+		WithSession(func(r *http.Request) ([]byte, error) {
+			switch r.Header.Get("X-Session") {
+			case "other":
+				return other, nil
+			case "revoked":
+				return revoked, nil
+			case "none":
+				return nil, ErrNoSession
+			}
+			return testSession, nil
+		}))
+	token := a.GenerateToken(now, testSession)
+	revokedToken := a.GenerateToken(now, revoked)
+	expired := a.GenerateToken(now.Add(-3*a.Lifetime), testSession)
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		session string
+		header  string
+		form    string
+		want    int
+	}{
+		{name: "GET", method: http.MethodGet, want: http.StatusOK},
+		{name: "HEAD", method: http.MethodHead, want: http.StatusOK},
+		{name: "OPTIONS", method: http.MethodOptions, want: http.StatusOK},
+		{name: "TRACE", method: http.MethodTrace, want: http.StatusOK},
+		{name: "POST without token", method: http.MethodPost, want: http.StatusForbidden},
+		{name: "PUT without token", method: http.MethodPut, want: http.StatusForbidden},
+		{name: "PATCH without token", method: http.MethodPatch, want: http.StatusForbidden},
+		{name: "DELETE without token", method: http.MethodDelete, want: http.StatusForbidden},
+		{name: "POST with header token", method: http.MethodPost, header: token, want: http.StatusOK},
+		{name: "DELETE with header token", method: http.MethodDelete, header: token, want: http.StatusOK},
+		{name: "POST with form token", method: http.MethodPost, form: token, want: http.StatusOK},
+		{name: "POST with garbage", method: http.MethodPost, header: "not-a-token", want: http.StatusForbidden},
+		{name: "POST with expired token", method: http.MethodPost, header: expired, want: http.StatusForbidden},
+		{name: "POST with token of another session", method: http.MethodPost, session: "other", header: token, want: http.StatusForbidden},
+		{name: "POST without session", method: http.MethodPost, session: "none", header: token, want: http.StatusForbidden},
+		{name: "POST of revoked session", method: http.MethodPost, session: "revoked", header: revokedToken, want: http.StatusForbidden},
+		{name: "GET of revoked session", method: http.MethodGet, session: "revoked", want: http.StatusOK},
+		{name: "POST to exempt path", method: http.MethodPost, path: "/hooks/", want: http.StatusOK},
+		{name: "POST of revoked session to exempt path", method: http.MethodPost, path: "/hooks/", session: "revoked", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/"
+			}
+			var body io.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{TokenField: {tt.form}}.Encode())
+			}
+			r := httptest.NewRequest(tt.method, path, body)
+			if tt.form != "" {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.session != "" {
+				r.Header.Set("X-Session", tt.session)
+			}
+			if tt.header != "" {
+				r.Header.Set(TokenHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
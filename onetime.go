@@ -0,0 +1,179 @@
+package csrf
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store records used tokens for single-use validation. Unlike a Denylist,
+// checking and recording a token is one atomic step, so two concurrent
+// submissions of the same token cannot both succeed. Implementations may be
+// shared between servers.
+type Store interface {
+	// MarkUsed records token as used until expiry and returns true if it
+	// had not been used before.
+	MarkUsed(token string, expiry time.Time) (firstUse bool, err error)
+}
+
+// StoreContext is implemented by stores that can honor cancellation, such
+// as ones backed by a remote database. MarkUsedContext() is used instead of
+// MarkUsed() when available.
+type StoreContext interface {
+	MarkUsedContext(ctx context.Context, token string, expiry time.Time) (firstUse bool, err error)
+}
+
+// CounterStore is implemented by stores that count the uses of a token,
+// for an Authenticator with MaxUses above one.
+type CounterStore interface {
+	// IncrementUses atomically adds one to the uses of token, recorded
+	// until expiry, and returns the new count.
+	IncrementUses(token string, expiry time.Time) (uses int, err error)
+}
+
+// CounterStoreContext is implemented by counting stores that can honor
+// cancellation. IncrementUsesContext() is used instead of IncrementUses()
+// when available.
+type CounterStoreContext interface {
+	IncrementUsesContext(ctx context.Context, token string, expiry time.Time) (uses int, err error)
+}
+
+// expiry returns when a token generated in counter stops validating: it is
+// accepted in its own window and AcceptedWindows-1 after it, plus Grace.
+func (a *Authenticator) expiry(date time.Time, counter int64) (time.Time, error) {
+	current, remaining, err := a.window(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	windows := counter - current + int64(a.acceptedWindows()-1)
+	return date.Add(addDurations(addDurations(remaining, mulDuration(windows, a.Lifetime)), a.Grace)), nil
+}
+
+// mulDuration returns n times d, saturating instead of overflowing, so an
+// expiry is never wrapped into the past.
+func mulDuration(n int64, d time.Duration) time.Duration {
+	if n == 0 || d == 0 {
+		return 0
+	}
+	p := time.Duration(n) * d
+	if p/d != time.Duration(n) || (n == -1 && d == math.MinInt64) {
+		if (n < 0) != (d < 0) {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return p
+}
+
+// addDurations returns a plus b, saturating instead of overflowing.
+func addDurations(a, b time.Duration) time.Duration {
+	s := a + b
+	if a > 0 && b > 0 && s < 0 {
+		return math.MaxInt64
+	}
+	if a < 0 && b < 0 && s >= 0 {
+		return math.MinInt64
+	}
+	return s
+}
+
+// markUsed records a validated token in the UsedTokens store.
+func (a *Authenticator) markUsed(ctx context.Context, date time.Time, counter int64, token string) error {
+	expiry, err := a.expiry(date, counter)
+	if err != nil {
+		return err
+	}
+	if a.MaxUses > 1 {
+		return a.countUse(ctx, expiry, token)
+	}
+	var firstUse bool
+	if c, ok := a.UsedTokens.(StoreContext); ok {
+		firstUse, err = c.MarkUsedContext(ctx, token, expiry)
+	} else {
+		firstUse, err = a.UsedTokens.MarkUsed(token, expiry)
+	}
+	if err != nil {
+		return err
+	}
+	if !firstUse {
+		return ErrTokenReplayed
+	}
+	return nil
+}
+
+// countUse records a use of a validated token in the UsedTokens store and
+// rejects it once it has been used more than MaxUses times.
+func (a *Authenticator) countUse(ctx context.Context, expiry time.Time, token string) error {
+	var uses int
+	var err error
+	switch s := a.UsedTokens.(type) {
+	case CounterStoreContext:
+		uses, err = s.IncrementUsesContext(ctx, token, expiry)
+	case CounterStore:
+		uses, err = s.IncrementUses(token, expiry)
+	default:
+		return ErrNoCounterStore
+	}
+	if err != nil {
+		return err
+	}
+	if uses > a.MaxUses {
+		return ErrTokenReplayed
+	}
+	return nil
+}
+
+// sweepInterval is how often MemoryStore removes expired entries.
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-memory Store and CounterStore for a single server.
+// Expired entries are removed at most once per minute, as new tokens are
+// marked used.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	tokens map[string]memoryUse
+	sweep  time.Time
+}
+
+// memoryUse is the MemoryStore record of a token.
+type memoryUse struct {
+	expiry time.Time
+	uses   int
+}
+
+var _ CounterStore = (*MemoryStore)(nil)
+
+// MarkUsed() records token as used until expiry and returns true if it had
+// not been used before.
+func (s *MemoryStore) MarkUsed(token string, expiry time.Time) (bool, error) {
+	uses, err := s.IncrementUses(token, expiry)
+	return uses == 1, err
+}
+
+// IncrementUses() records a use of token and returns how many times it has
+// been used before expiry, including this one.
+func (s *MemoryStore) IncrementUses(token string, expiry time.Time) (int, error) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.tokens == nil {
+		s.tokens = make(map[string]memoryUse)
+	}
+	if now.After(s.sweep) {
+		for t, u := range s.tokens {
+			if now.After(u.expiry) {
+				delete(s.tokens, t)
+			}
+		}
+		s.sweep = now.Add(sweepInterval)
+	}
+	u, ok := s.tokens[token]
+	if !ok || !now.Before(u.expiry) {
+		u = memoryUse{expiry: expiry}
+	}
+	u.uses++
+	s.tokens[token] = u
+	return u.uses, nil
+}
@@ -0,0 +1,101 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// markOnlyStore is a Store without use counts.
+type markOnlyStore struct{ used map[string]bool }
+
+func (s *markOnlyStore) MarkUsed(token string, expiry time.Time) (bool, error) {
+	if s.used[token] {
+		return false, nil
+	}
+	s.used[token] = true
+	return true, nil
+}
+
+func TestSingleUse(t *testing.T) {
+	a := testAuthenticator(t)
+	a.UsedTokens = &MemoryStore{}
+	now := time.Now()
+	token := a.GenerateToken(now, testSession)
+	forged := a.GenerateToken(now, []byte("fedcba9876543210fedcba9876543210"))
+	if err := a.ValidateTokenErr(now, testSession, forged); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("token of another session: got %v, want %v", err, ErrMismatch)
+	}
+	if err := a.ValidateTokenErr(now, testSession, token); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := a.ValidateTokenErr(now, testSession, token); !errors.Is(err, ErrTokenReplayed) {
+		t.Fatalf("second use: got %v, want %v", err, ErrTokenReplayed)
+	}
+}
+
+func TestMaxUses(t *testing.T) {
+	a := testAuthenticator(t)
+	a.UsedTokens = &MemoryStore{}
+	a.MaxUses = 3
+	now := time.Now()
+	token := a.GenerateToken(now, testSession)
+	for i := 1; i <= a.MaxUses; i++ {
+		if err := a.ValidateTokenErr(now, testSession, token); err != nil {
+			t.Fatalf("use %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := a.ValidateTokenErr(now, testSession, token); !errors.Is(err, ErrTokenReplayed) {
+			t.Fatalf("use %d: got %v, want %v", a.MaxUses+1+i, err, ErrTokenReplayed)
+		}
+	}
+	if err := a.ValidateTokenErr(now, testSession, a.GenerateToken(now, testSession)); err != nil {
+		t.Fatalf("another token: %v", err)
+	}
+
+	a.UsedTokens = &markOnlyStore{used: make(map[string]bool)}
+	if err := a.ValidateTokenErr(now, testSession, token); !errors.Is(err, ErrNoCounterStore) {
+		t.Fatalf("MaxUses with a Store without counts: got %v, want %v", err, ErrNoCounterStore)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := &MemoryStore{}
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 2; i++ {
+		if uses, err := s.IncrementUses("expired", past); err != nil || uses != 1 {
+			t.Fatalf("expired token: got %d uses, %v, want 1", uses, err)
+		}
+	}
+	future := time.Now().Add(time.Hour)
+	if first, err := s.MarkUsed("live", future); err != nil || !first {
+		t.Fatalf("first MarkUsed() = %v, %v", first, err)
+	}
+	if first, err := s.MarkUsed("live", future); err != nil || first {
+		t.Fatalf("second MarkUsed() = %v, %v", first, err)
+	}
+}
+
+func TestProtectSingleUse(t *testing.T) {
+	a := testAuthenticator(t)
+	a.UsedTokens = &MemoryStore{}
+	var seen string
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = Token(r) }),
+		WithAuthenticator(a), testSessionOption)
+	token := a.GenerateToken(a.now(), testSession)
+	for i, want := range []int{http.StatusOK, http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(TokenHeader, token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Fatalf("use %d: got status %d, want %d", i+1, w.Code, want)
+		}
+	}
+	if seen != "" {
+		t.Fatalf("Token() returned the spent token %q", seen)
+	}
+}
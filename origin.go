@@ -0,0 +1,254 @@
+package csrf
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUntrustedOrigin is returned for an Origin or Referer outside
+	// the TrustedOrigins
+	ErrUntrustedOrigin = errors.New("csrf: untrusted origin")
+	// ErrMissingOrigin is returned for a request with neither an Origin
+	// nor a Referer header unless AllowMissing is set
+	ErrMissingOrigin = errors.New("csrf: missing origin")
+)
+
+// OriginChecker rejects requests whose Origin header, or Referer header if
+// there is no Origin, names a site outside TrustedOrigins. Browsers set
+// these headers themselves, so a cross-site page cannot forge them. Use it
+// alone or, through WithOriginChecker(), in front of token validation as
+// defense in depth.
+type OriginChecker struct {
+	// TrustedOrigins are origins such as "https://example.com" or
+	// "http://localhost:8080", including the site's own. A host of the
+	// form "*.example.com" matches every subdomain of example.com but
+	// not example.com itself. Internationalized domains may be written
+	// in Unicode or in their "xn--" ASCII form; they are compared in the
+	// ASCII form browsers send.
+	TrustedOrigins []string
+	// AllowMissing accepts requests without Origin or Referer, as sent
+	// by some privacy tools and non-browser clients. Browsers send Origin
+	// on every cross-site POST, so this keeps most of the protection.
+	AllowMissing bool
+	// SameOrigin also trusts the origin the request was sent to, built
+	// from its scheme and Host, so the site need not list itself.
+	SameOrigin bool
+	// TrustedProxies are the addresses or CIDR ranges, such as
+	// "10.0.0.0/8", of reverse proxies in front of the server. For
+	// requests from them, SameOrigin takes the scheme and host from the
+	// Forwarded header, or X-Forwarded-Proto and X-Forwarded-Host, using
+	// the last entry, which the proxy added. The headers of any other
+	// peer are ignored, so clients cannot spoof them. Invalid entries
+	// match nothing.
+	TrustedProxies []string
+	// RejectMixedScript rejects origins with a host label mixing scripts,
+	// such as Latin and Cyrillic, as lookalikes of well known names do,
+	// or with invalid punycode. Mixing Latin with Han and the scripts
+	// written with it is allowed, as in Unicode TS 39. It matters most for
+	// wildcards, which would otherwise trust any lookalike subdomain.
+	RejectMixedScript bool
+	// IgnorePort matches origins whatever their port, so the ports of
+	// local development servers need not be listed. Either way, a
+	// default port, such as 443 for https, may be left out.
+	IgnorePort bool
+	// IgnoreScheme matches an http origin against a trusted https one,
+	// and the other way around, for development servers and staging
+	// behind proxies that do not forward the scheme. An attacker on the
+	// network can forge plain http pages, so leave it off in production.
+	IgnoreScheme bool
+
+	parseProxies sync.Once
+	proxies      []*net.IPNet
+}
+
+// WithOriginChecker() checks the origin of unsafe requests before their
+// token, rejecting requests that fail either.
+func WithOriginChecker(c *OriginChecker) Option {
+	return func(m *Middleware) {
+		m.Origins = c
+	}
+}
+
+// Check() returns nil if the request comes from a trusted origin.
+func (c *OriginChecker) Check(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			if c.AllowMissing {
+				return nil
+			}
+			return ErrMissingOrigin
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return ErrUntrustedOrigin
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	if c.trusts(r, origin) {
+		return nil
+	}
+	return ErrUntrustedOrigin
+}
+
+// trusts reports whether origin is one of the TrustedOrigins or, with
+// SameOrigin, the origin r was sent to. Hosts are compared in the ASCII
+// form of internationalized domain names.
+func (c *OriginChecker) trusts(r *http.Request, origin string) bool {
+	scheme, host, port := originParts(origin)
+	if host == "" || c.RejectMixedScript && mixedScript(host) {
+		return false
+	}
+	if c.SameOrigin {
+		if s, h, p := originParts(c.requestOrigin(r)); c.sameSchemeAndPort(s, p, scheme, port) && h == host {
+			return true
+		}
+	}
+	for _, trusted := range c.TrustedOrigins {
+		s, h, p := originParts(trusted)
+		if c.sameSchemeAndPort(s, p, scheme, port) && matchHost(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSchemeAndPort reports whether two origins have the same scheme and
+// port, unless IgnoreScheme or IgnorePort say they need not. Ports left
+// out are the default of their scheme either way, so http://localhost and
+// https://localhost match with IgnoreScheme.
+func (c *OriginChecker) sameSchemeAndPort(scheme1, port1, scheme2, port2 string) bool {
+	return (c.IgnoreScheme || scheme1 == scheme2) && (c.IgnorePort || port1 == port2)
+}
+
+// matchHost reports whether host is trusted, with "*." wildcards for
+// subdomains.
+func matchHost(trusted, host string) bool {
+	if trusted == "" {
+		return false
+	}
+	if !strings.HasPrefix(trusted, "*.") {
+		return trusted == host
+	}
+	suffix := trusted[1:]
+	return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+}
+
+// originParts splits origin into its lower case scheme, the asciiHost()
+// of its host, and its port, "" if it has none or the default of the
+// scheme.
+func originParts(origin string) (scheme, host, port string) {
+	scheme, rest, ok := cut(origin, "://")
+	if !ok {
+		return "", "", ""
+	}
+	scheme = strings.ToLower(scheme)
+	host = rest
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 && !strings.Contains(rest[i:], "]") {
+		host, port = rest[:i], rest[i+1:]
+	}
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	return scheme, asciiHost(host), port
+}
+
+// defaultPorts are the ports origins of a scheme need not name.
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+// requestOrigin returns the origin r was sent to, as forwarded by a
+// trusted proxy.
+func (c *OriginChecker) requestOrigin(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if c.fromProxy(r) {
+		if forwarded := lastValue(r.Header, "Forwarded"); forwarded != "" {
+			for _, pair := range strings.Split(forwarded, ";") {
+				name, value, ok := cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(value, `"`)
+				switch strings.ToLower(name) {
+				case "proto":
+					scheme = value
+				case "host":
+					host = value
+				}
+			}
+		} else {
+			if proto := lastValue(r.Header, "X-Forwarded-Proto"); proto != "" {
+				scheme = proto
+			}
+			if forwardedHost := lastValue(r.Header, "X-Forwarded-Host"); forwardedHost != "" {
+				host = forwardedHost
+			}
+		}
+	}
+	if host == "" {
+		return ""
+	}
+	return strings.ToLower(scheme) + "://" + host
+}
+
+// fromProxy reports whether r comes directly from one of the
+// TrustedProxies.
+func (c *OriginChecker) fromProxy(r *http.Request) bool {
+	c.parseProxies.Do(func() {
+		for _, p := range c.TrustedProxies {
+			if !strings.Contains(p, "/") {
+				if strings.Contains(p, ":") {
+					p += "/128"
+				} else {
+					p += "/32"
+				}
+			}
+			if _, n, err := net.ParseCIDR(p); err == nil {
+				c.proxies = append(c.proxies, n)
+			}
+		}
+	})
+	if len(c.proxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastValue returns the last comma separated entry of header name.
+func lastValue(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
+// cut is strings.Cut, which needs Go 1.18.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,69 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginCheckerPortsAndSchemes(t *testing.T) {
+	trusted := []string{"https://app.example", "http://localhost:3000", "https://*.cdn.example:8443"}
+	tests := []struct {
+		origin string
+		// trusted by default, with IgnorePort, and with IgnoreScheme
+		strict, port, scheme bool
+	}{
+		{"https://app.example", true, true, true},
+		{"https://app.example:443", true, true, true},
+		{"https://app.example:8443", false, true, false},
+		{"http://app.example", false, false, true},
+		{"http://app.example:443", false, false, false},
+		{"http://localhost:3000", true, true, true},
+		{"http://localhost:5173", false, true, false},
+		{"https://localhost:3000", false, false, true},
+		{"http://localhost", false, true, false},
+		{"https://a.cdn.example:8443", true, true, true},
+		{"https://a.cdn.example", false, true, false},
+		{"wss://app.example", false, false, true},
+		{"https://evil.example", false, false, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("Origin", tt.origin)
+		for _, c := range []struct {
+			checker *OriginChecker
+			want    bool
+		}{
+			{&OriginChecker{TrustedOrigins: trusted}, tt.strict},
+			{&OriginChecker{TrustedOrigins: trusted, IgnorePort: true}, tt.port},
+			{&OriginChecker{TrustedOrigins: trusted, IgnoreScheme: true}, tt.scheme},
+		} {
+			if got := c.checker.Check(r) == nil; got != c.want {
+				t.Errorf("%s with IgnorePort %v and IgnoreScheme %v: trusted = %v, want %v",
+					tt.origin, c.checker.IgnorePort, c.checker.IgnoreScheme, got, c.want)
+			}
+		}
+	}
+}
+
+func TestOriginCheckerSameOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		origin  string
+		checker *OriginChecker
+		want    bool
+	}{
+		{"http://example.com", &OriginChecker{SameOrigin: true}, true},
+		{"http://example.com:80", &OriginChecker{SameOrigin: true}, true},
+		{"https://example.com", &OriginChecker{SameOrigin: true}, false},
+		{"https://example.com", &OriginChecker{SameOrigin: true, IgnoreScheme: true}, true},
+		{"http://example.com:8080", &OriginChecker{SameOrigin: true}, false},
+		{"http://example.com:8080", &OriginChecker{SameOrigin: true, IgnorePort: true}, true},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+		r.Header.Set("Origin", tt.origin)
+		if got := tt.checker.Check(r) == nil; got != tt.want {
+			t.Errorf("%s with IgnorePort %v and IgnoreScheme %v: trusted = %v, want %v",
+				tt.origin, tt.checker.IgnorePort, tt.checker.IgnoreScheme, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/foobaz/csrf/otelcsrf
+
+go 1.22
+
+require (
+	github.com/foobaz/csrf v0.0.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+replace github.com/foobaz/csrf => ../
@@ -0,0 +1,64 @@
+// Package otelcsrf records csrf.Protect() validation outcomes on
+// OpenTelemetry spans, so rejected requests show up in distributed traces.
+package otelcsrf
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/foobaz/csrf"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys set on the request span.
+const (
+	ResultKey   = attribute.Key("csrf.result")
+	ReasonKey   = attribute.Key("csrf.reason")
+	TokenAgeKey = attribute.Key("csrf.token_age")
+)
+
+// RejectedEvent is the name of the span event added for a rejection.
+const RejectedEvent = "csrf.rejected"
+
+// Observer() returns a function for csrf.WithObserver() that sets
+// csrf.result to "valid" or "rejected" on the span in the request context.
+// A rejection also sets csrf.reason to the csrf.Reason() and adds a
+// csrf.rejected event; a valid request sets csrf.token_age to a bucket
+// such as "<1m" or "<1h".
+func Observer() func(r *http.Request, v csrf.Validation) {
+	return func(r *http.Request, v csrf.Validation) {
+		span := trace.SpanFromContext(r.Context())
+		if !span.IsRecording() {
+			return
+		}
+		if v.Err != nil {
+			reason := ReasonKey.String(csrf.Reason(v.Err))
+			span.SetAttributes(ResultKey.String("rejected"), reason)
+			span.AddEvent(RejectedEvent, trace.WithAttributes(reason))
+			return
+		}
+		span.SetAttributes(ResultKey.String("valid"), TokenAgeKey.String(ageBucket(v.Age)))
+	}
+}
+
+// ageBuckets keep token age attributes low-cardinality.
+var ageBuckets = []struct {
+	limit time.Duration
+	name  string
+}{
+	{time.Minute, "<1m"},
+	{10 * time.Minute, "<10m"},
+	{time.Hour, "<1h"},
+	{6 * time.Hour, "<6h"},
+	{24 * time.Hour, "<1d"},
+}
+
+func ageBucket(age time.Duration) string {
+	for _, b := range ageBuckets {
+		if age < b.limit {
+			return b.name
+		}
+	}
+	return ">=1d"
+}
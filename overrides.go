@@ -0,0 +1,54 @@
+package csrf
+
+// WithOverrides() gives the middleware a copy of its Authenticator with
+// opts applied, such as WithLifetime() or WithTokenLength(), sharing the
+// key, stores and Metrics. Combined with Wrap(), it configures one
+// sub-router differently from the rest of a site without a second key:
+//
+//	base := []csrf.Option{csrf.WithAuthenticator(a), csrf.WithSession(session)}
+//	r.With(csrf.Wrap(append(base, csrf.WithOverrides(csrf.WithLifetime(5*time.Minute)))...)).Mount("/admin", admin)
+//	r.With(csrf.Wrap(base...)).Mount("/", public)
+//
+// Tokens only validate under settings they were made with, so serve each
+// form from the route it submits to. Middleware settings such as the
+// token sources and ErrorHandler are overridden with their own options.
+func WithOverrides(opts ...AuthenticatorOption) Option {
+	return func(m *Middleware) {
+		m.overrides = append(m.overrides, opts...)
+	}
+}
+
+// applyOverrides gives the middleware its own Authenticator with the
+// overrides applied.
+func (m *Middleware) applyOverrides() {
+	if len(m.overrides) == 0 {
+		return
+	}
+	if m.Authenticator == nil {
+		panic("csrf: WithOverrides() requires WithAuthenticator()")
+	}
+	a := m.Authenticator.clone()
+	for _, opt := range m.overrides {
+		opt(a)
+	}
+	err := a.CheckConfig()
+	if err == nil && a.TokenLength < a.tokenFloor {
+		// keep the floor of WithStrictConfig()
+		err = ErrTokenLength
+	}
+	if err != nil {
+		panic("csrf: WithOverrides(): " + err.Error())
+	}
+	m.setAuthenticator(a)
+}
+
+// setAuthenticator makes a the Authenticator of the middleware and of its
+// DoubleSubmit.
+func (m *Middleware) setAuthenticator(a *Authenticator) {
+	m.Authenticator = a
+	if m.DoubleSubmit != nil {
+		d := *m.DoubleSubmit
+		d.Authenticator = a
+		m.DoubleSubmit = &d
+	}
+}
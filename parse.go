@@ -0,0 +1,53 @@
+package csrf
+
+// TokenInfo describes the parts of a token, as found by ParseToken().
+type TokenInfo struct {
+	// KeyID is the key ID prefix, empty if key IDs are not in use
+	KeyID string
+	// Window identifies the issuing window with EmbedWindow, and is
+	// otherwise empty
+	Window string
+	// Hash is the HMAC part, the only part an attacker cannot choose
+	Hash string
+	// Salt is the random part
+	Salt string
+	// Masked is true if the token was masked by MaskToken(), in which
+	// case the other fields describe the unmasked token
+	Masked bool
+	// Version is the format character of a Versioned token, or 0
+	Version byte
+}
+
+// ParseToken() splits a token into its parts without the secret key, for
+// debugging reports of invalid tokens. An error means the token is
+// structurally invalid, for example truncated or altered in transit, and
+// is a *MalformedTokenError for a wrong length or invalid character. A
+// token that parses but fails validation was made with another key or
+// session, or has expired.
+func (a *Authenticator) ParseToken(token string) (TokenInfo, error) {
+	var info TokenInfo
+	token = a.canonicalToken(token)
+	if b, body, ok := a.versioned(token); ok && !a.legacyLength(len(token)) {
+		info, err := b.ParseToken(body)
+		info.Version = token[0]
+		return info, err
+	}
+	if a.Mask && len(token) == 2*a.tokenLength() {
+		unmasked, err := a.unmask(token)
+		if err != nil {
+			return info, err
+		}
+		token, info.Masked = unmasked, true
+	}
+	if err := a.CheckTokenFormat(token); err != nil {
+		return info, err
+	}
+	id, _ := a.primaryKey()
+	info.KeyID, token = token[:len(id)], token[len(id):]
+	if a.EmbedWindow {
+		info.Window, token = token[:1], token[1:]
+	}
+	hashLength := len(token) - a.saltLength()
+	info.Hash, info.Salt = token[:hashLength], token[hashLength:]
+	return info, nil
+}
@@ -0,0 +1,97 @@
+package csrf
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// DefaultPlaceholder is replaced by PlaceholderWriter unless another
+// placeholder is given.
+const DefaultPlaceholder = "<!--csrf-->"
+
+// PlaceholderWriter replaces every occurrence of Placeholder in a stream
+// with the token as it is written, so handlers that stream large or
+// chunked HTML pages can include a token without buffering the body. At
+// most len(Placeholder)-1 bytes are held back between writes, in case a
+// placeholder is split across them; Close() writes them out. An empty
+// Placeholder replaces nothing, so data passes through unchanged.
+type PlaceholderWriter struct {
+	W           io.Writer
+	Placeholder []byte
+	Token       []byte
+
+	pending []byte
+}
+
+// NewPlaceholderWriter() returns a writer substituting token for
+// DefaultPlaceholder in everything written to w.
+func NewPlaceholderWriter(w io.Writer, token string) *PlaceholderWriter {
+	return &PlaceholderWriter{
+		W:           w,
+		Placeholder: []byte(DefaultPlaceholder),
+		Token:       []byte(token),
+	}
+}
+
+func (p *PlaceholderWriter) Write(b []byte) (int, error) {
+	data := append(p.pending, b...)
+	p.pending = nil
+	if len(p.Placeholder) == 0 {
+		if _, err := p.W.Write(data); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	for {
+		i := bytes.Index(data, p.Placeholder)
+		if i < 0 {
+			break
+		}
+		if _, err := p.W.Write(data[:i]); err != nil {
+			return 0, err
+		}
+		if _, err := p.W.Write(p.Token); err != nil {
+			return 0, err
+		}
+		data = data[i+len(p.Placeholder):]
+	}
+
+	keep := partialSuffix(data, p.Placeholder)
+	if _, err := p.W.Write(data[:len(data)-keep]); err != nil {
+		return 0, err
+	}
+	p.pending = append([]byte(nil), data[len(data)-keep:]...)
+	return len(b), nil
+}
+
+// Flush() flushes the underlying writer if it is an http.Flusher. Bytes
+// that may start a placeholder are still held back.
+func (p *PlaceholderWriter) Flush() {
+	if f, ok := p.W.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close() writes any held back bytes. It does not close the underlying
+// writer.
+func (p *PlaceholderWriter) Close() error {
+	_, err := p.W.Write(p.pending)
+	p.pending = nil
+	return err
+}
+
+// partialSuffix returns the length of the longest suffix of data that is
+// a proper prefix of placeholder.
+func partialSuffix(data, placeholder []byte) int {
+	n := len(placeholder) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if bytes.HasPrefix(placeholder, data[len(data)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,216 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Clause is one check in a policy. Clauses combine with All(), Any() and
+// AtLeast() so different route groups can require different evidence that
+// a request is not forged.
+type Clause interface {
+	Name() string
+	Check(r *http.Request) error
+}
+
+// PolicyError reports which clause of a policy failed and why.
+type PolicyError struct {
+	Clause string
+	Err    error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("csrf: policy clause %s failed: %v", e.Clause, e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyHandler() enforces clause on requests with unsafe methods and
+// responds 403 Forbidden when it fails. Rejections are logged to the
+// standard log package; Authenticator.PolicyHandler() logs them to the
+// Authenticator Logger.
+func PolicyHandler(clause Clause, next http.Handler) http.Handler {
+	return policyHandler(stdLogger{}, clause, next)
+}
+
+// PolicyHandler() is like the package function PolicyHandler() but logs
+// rejections to the Logger of a.
+func (a *Authenticator) PolicyHandler(clause Clause, next http.Handler) http.Handler {
+	return policyHandler(a.logger(), clause, next)
+}
+
+func policyHandler(logger Logger, clause Clause, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isSafeMethod(r.Method) {
+			if err := checkClause(clause, r); err != nil {
+				logger.Warn("csrf: request rejected", "reason", err, "method", r.Method, "path", r.URL.Path)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkClause runs clause and wraps a failure in a *PolicyError naming it,
+// unless a nested clause already did.
+func checkClause(clause Clause, r *http.Request) error {
+	err := clause.Check(r)
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		return err
+	}
+	return &PolicyError{Clause: clause.Name(), Err: err}
+}
+
+type clauseFunc struct {
+	name  string
+	check func(r *http.Request) error
+}
+
+func (c *clauseFunc) Name() string                { return c.name }
+func (c *clauseFunc) Check(r *http.Request) error { return c.check(r) }
+
+// ClauseFunc() makes a named Clause from a function.
+func ClauseFunc(name string, check func(r *http.Request) error) Clause {
+	return &clauseFunc{name: name, check: check}
+}
+
+func clauseNames(clauses []Clause) string {
+	names := make([]string, len(clauses))
+	for i, c := range clauses {
+		names[i] = c.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// All() passes if every clause passes. The error names the first failing
+// clause.
+func All(clauses ...Clause) Clause {
+	return ClauseFunc("all("+clauseNames(clauses)+")", func(r *http.Request) error {
+		for _, c := range clauses {
+			if err := checkClause(c, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Any() passes if at least one clause passes.
+func Any(clauses ...Clause) Clause {
+	return AtLeast(1, clauses...)
+}
+
+// AtLeast() passes if at least n clauses pass. The error names the first
+// failing clause.
+func AtLeast(n int, clauses ...Clause) Clause {
+	name := fmt.Sprintf("atleast%d(%s)", n, clauseNames(clauses))
+	return ClauseFunc(name, func(r *http.Request) error {
+		passed := 0
+		var first error
+		for _, c := range clauses {
+			err := checkClause(c, r)
+			if err == nil {
+				passed++
+				if passed >= n {
+					return nil
+				}
+			} else if first == nil {
+				first = err
+			}
+		}
+		if first == nil {
+			first = errors.New("not enough clauses")
+		}
+		return &PolicyError{Clause: name, Err: first}
+	})
+}
+
+// TokenClause() requires a valid CSRF token in the X-CSRF-Token header or
+// csrf_token form field, or wherever opts such as WithTokenHeaders() and
+// WithTokenSources() say, as read by TokenExtractor().
+func TokenClause(a *Authenticator, session func(*http.Request) ([]byte, error), opts ...Option) Clause {
+	extract := TokenExtractor(opts...)
+	return ClauseFunc("token", func(r *http.Request) error {
+		raw, s, err := requestSession(a, session, r)
+		if err != nil {
+			return err
+		}
+		_, err = a.validate(r.Context(), a.now(), raw, s, extract(r))
+		return err
+	})
+}
+
+// IPBindingClause() requires a valid CSRF token bound to the client
+// address, with the IPv4Prefix, IPv6Prefix and ClientIP of b, so a token
+// only passes from the network it was issued to. Generate its tokens with
+// BindRequest() of an Authenticator whose RequestBinding has the same
+// fields. Other clauses of the policy can keep accepting unbound tokens
+// for the session. It panics if b binds neither address family.
+func IPBindingClause(a *Authenticator, session func(*http.Request) ([]byte, error), b *RequestBinding, opts ...Option) Clause {
+	if b == nil || b.IPv4Prefix <= 0 && b.IPv6Prefix <= 0 {
+		panic("csrf: IPBindingClause() requires IPv4Prefix or IPv6Prefix")
+	}
+	bound := a.clone()
+	bound.RequestBinding = &RequestBinding{IPv4Prefix: b.IPv4Prefix, IPv6Prefix: b.IPv6Prefix, ClientIP: b.ClientIP}
+	extract := TokenExtractor(opts...)
+	return ClauseFunc("ip-binding", func(r *http.Request) error {
+		raw, s, err := requestSession(bound, session, r)
+		if err != nil {
+			return err
+		}
+		_, err = bound.validate(r.Context(), bound.now(), raw, s, extract(r))
+		return err
+	})
+}
+
+// ConfirmationClause() requires a confirmation token for action in the
+// X-CSRF-Confirmation header or csrf_confirmation form field.
+func ConfirmationClause(c *Confirmer, session func(*http.Request) ([]byte, error), action string) Clause {
+	extract := TokenExtractor(WithTokenHeaders("X-CSRF-Confirmation"), WithFormField("csrf_confirmation"))
+	return ClauseFunc("confirmation", func(r *http.Request) error {
+		raw, s, err := requestSession(c.Authenticator, session, r)
+		if err != nil {
+			return err
+		}
+		token := extract(r)
+		if !c.validToken(c.Authenticator.now(), raw, s, action, token) {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+}
+
+// OriginClause() requires the Origin header to equal one of origins.
+func OriginClause(origins ...string) Clause {
+	return ClauseFunc("origin", func(r *http.Request) error {
+		origin := r.Header.Get("Origin")
+		for _, o := range origins {
+			if origin == o {
+				return nil
+			}
+		}
+		return fmt.Errorf("untrusted origin %q", origin)
+	})
+}
+
+// FetchMetadataClause() requires a Sec-Fetch-Site header of same-origin or
+// none, as sent by browsers for requests the user initiated on this site.
+func FetchMetadataClause() Clause {
+	return ClauseFunc("fetch-metadata", func(r *http.Request) error {
+		switch site := r.Header.Get("Sec-Fetch-Site"); site {
+		case "same-origin", "none":
+			return nil
+		default:
+			return fmt.Errorf("Sec-Fetch-Site %q", site)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"hash"
+	"sync"
+	"sync/atomic"
+)
+
+// maxMACPools bounds how many keys get a pool of HMAC states, so a
+// KeyProvider that rotates often cannot grow the pools without limit.
+const maxMACPools = 64
+
+var (
+	macPools     sync.Map // string(key) -> *sync.Pool
+	macPoolCount int32
+)
+
+// macState is a keyed HMAC and a buffer for its sum, reused between
+// tokens so validation does not rederive the HMAC pads or allocate.
+type macState struct {
+	h   hash.Hash
+	sum []byte
+}
+
+// pooled reports whether MACs are pooled. Only the default HMAC-SHA-512
+// is, since MAC and Hash functions cannot be compared to key a pool, and
+// not with WithKeyCopy(), since the pools keep keys for the process
+// lifetime.
+func (a *Authenticator) pooled() bool {
+	return a.MAC == nil && a.Hash == nil && !a.ownsKeys
+}
+
+func macPool(key []byte) *sync.Pool {
+	if p, ok := macPools.Load(string(key)); ok {
+		return p.(*sync.Pool)
+	}
+	p := new(sync.Pool)
+	if atomic.LoadInt32(&macPoolCount) >= maxMACPools {
+		return p
+	}
+	actual, loaded := macPools.LoadOrStore(string(key), p)
+	if !loaded {
+		atomic.AddInt32(&macPoolCount, 1)
+	}
+	return actual.(*sync.Pool)
+}
+
+// acquireMAC returns a reset MAC keyed with key.
+func (a *Authenticator) acquireMAC(key []byte) *macState {
+	if !a.pooled() {
+		return &macState{h: a.newMAC(key)}
+	}
+	if s, ok := macPool(key).Get().(*macState); ok {
+		s.h.Reset()
+		return s
+	}
+	return &macState{h: hmac.New(sha512.New, key), sum: make([]byte, 0, sha512.Size)}
+}
+
+// releaseMAC returns s for reuse by later tokens with key.
+func (a *Authenticator) releaseMAC(key []byte, s *macState) {
+	if a.pooled() {
+		macPool(key).Put(s)
+	}
+}
@@ -0,0 +1,217 @@
+package csrf
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	poolShards         = 16
+	defaultPoolSize    = 4
+	defaultPoolMax     = 4096
+	defaultPoolWorkers = 1
+)
+
+// TokenPool mints tokens ahead of time for recently seen sessions, so
+// issuance-heavy endpoints take a token off a queue instead of computing
+// an HMAC while the client waits. A session's queue is refilled in the
+// background once it runs low, and tokens left over from a past window
+// are dropped rather than handed out. The first request of a session, or
+// one that empties its queue, still generates a token itself.
+//
+// Set the fields before the first call to Token() and call Close() to
+// stop the refill workers.
+type TokenPool struct {
+	Authenticator *Authenticator
+	// Size is how many tokens are kept per session, 4 if unset
+	Size int
+	// LowWater is the queue length at which a session is refilled, Size/2
+	// if unset
+	LowWater int
+	// MaxSessions bounds how many sessions have tokens kept, 4096 if unset
+	MaxSessions int
+	// Workers is how many goroutines refill queues, 1 if unset
+	Workers int
+
+	start  sync.Once
+	stop   sync.Once
+	refill chan string
+	done   chan struct{}
+	shards [poolShards]poolShard
+}
+
+type poolShard struct {
+	mutex    sync.Mutex
+	sessions map[string]*pooledTokens
+}
+
+// pooledTokens are tokens minted for one session in one window.
+type pooledTokens struct {
+	counter   int64
+	tokens    []string
+	refilling bool
+}
+
+func (p *TokenPool) size() int {
+	if p.Size > 0 {
+		return p.Size
+	}
+	return defaultPoolSize
+}
+
+func (p *TokenPool) lowWater() int {
+	if p.LowWater > 0 && p.LowWater < p.size() {
+		return p.LowWater
+	}
+	return p.size() / 2
+}
+
+func (p *TokenPool) shardSessions() int {
+	max := p.MaxSessions
+	if max <= 0 {
+		max = defaultPoolMax
+	}
+	if max < poolShards {
+		return 1
+	}
+	return max / poolShards
+}
+
+func (p *TokenPool) shard(session string) *poolShard {
+	h := fnv.New32a()
+	h.Write([]byte(session))
+	return &p.shards[h.Sum32()%poolShards]
+}
+
+// run starts the refill workers.
+func (p *TokenPool) run() {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = defaultPoolWorkers
+	}
+	p.refill = make(chan string, p.shardSessions()*poolShards)
+	p.done = make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+}
+
+func (p *TokenPool) work() {
+	for {
+		select {
+		case session := <-p.refill:
+			p.fill(session)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// fill tops up the queue of session with tokens for the current window.
+func (p *TokenPool) fill(session string) {
+	a := p.Authenticator
+	now := a.now()
+	counter, err := a.counter(now)
+	if err != nil {
+		p.finish(session, counter, nil)
+		return
+	}
+	tokens := make([]string, 0, p.size())
+	for len(tokens) < p.size() {
+		token, err := a.GenerateTokenCtx(context.Background(), now, []byte(session))
+		if err != nil {
+			a.logger().Warn("csrf: token pre-generation failed", "reason", err)
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	p.finish(session, counter, tokens)
+}
+
+// finish adds tokens minted in counter to the queue of session.
+func (p *TokenPool) finish(session string, counter int64, tokens []string) {
+	s := p.shard(session)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	q, ok := s.sessions[session]
+	if !ok {
+		return
+	}
+	q.refilling = false
+	if q.counter != counter {
+		q.counter, q.tokens = counter, nil
+	}
+	if room := p.size() - len(q.tokens); len(tokens) > room {
+		tokens = tokens[:room]
+	}
+	q.tokens = append(q.tokens, tokens...)
+}
+
+// Token() returns a token for the session valid at date, from its queue
+// if one is ready, and schedules a refill if the queue is running low.
+// It is equivalent to GenerateTokenCtx() with the pool's Authenticator.
+func (p *TokenPool) Token(ctx context.Context, date time.Time, session []byte) (string, error) {
+	p.start.Do(p.run)
+	a := p.Authenticator
+	date = a.date(date)
+	counter, err := a.counter(date)
+	if err != nil {
+		return "", err
+	}
+
+	key := string(session)
+	s := p.shard(key)
+	s.mutex.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*pooledTokens)
+	}
+	q, ok := s.sessions[key]
+	if !ok {
+		if len(s.sessions) >= p.shardSessions() {
+			for evicted := range s.sessions {
+				delete(s.sessions, evicted)
+				break
+			}
+		}
+		q = &pooledTokens{counter: counter}
+		s.sessions[key] = q
+	}
+	if q.counter != counter {
+		q.counter, q.tokens = counter, nil
+	}
+	var token string
+	if n := len(q.tokens); n > 0 {
+		token, q.tokens = q.tokens[n-1], q.tokens[:n-1]
+	}
+	if len(q.tokens) <= p.lowWater() && !q.refilling {
+		select {
+		case p.refill <- key:
+			q.refilling = true
+		default:
+		}
+	}
+	s.mutex.Unlock()
+
+	if token != "" {
+		return token, nil
+	}
+	return a.GenerateTokenCtx(ctx, date, session)
+}
+
+// WithTokenPool() makes Protect() issue tokens from p when it belongs to
+// the Middleware's Authenticator. Tokens bound to an action are always
+// generated on demand.
+func WithTokenPool(p *TokenPool) Option {
+	return func(m *Middleware) {
+		m.Pool = p
+	}
+}
+
+// Close() stops the refill workers. Token() keeps working afterwards but
+// generates every token itself.
+func (p *TokenPool) Close() {
+	p.start.Do(p.run)
+	p.stop.Do(func() { close(p.done) })
+}
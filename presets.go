@@ -0,0 +1,106 @@
+package csrf
+
+import (
+	"net/http"
+	"time"
+)
+
+// The Authenticator presets set the token format and windows; pair each
+// with the Options preset of the same name for the middleware settings:
+//
+//	csrf.Protect(h, append(csrf.StrictOptions(csrf.Strict(key)), csrf.WithSession(session))...)
+
+// Strict() returns a preset for high-value applications: masked 132 bit
+// tokens valid for 15 to 30 minutes, no future windows and session
+// bindings of at least 16 bytes.
+func Strict(key []byte) *Authenticator {
+	return &Authenticator{
+		Key:              key,
+		TokenLength:      44,
+		Lifetime:         15 * time.Minute,
+		AcceptedWindows:  2,
+		Strict:           true,
+		MinSessionLength: 16,
+		Mask:             true,
+	}
+}
+
+// Balanced() returns a preset suitable for most sites: 96 bit tokens
+// valid for one to two hours and non-empty session bindings.
+func Balanced(key []byte) *Authenticator {
+	return &Authenticator{
+		Key:             key,
+		TokenLength:     32,
+		Lifetime:        time.Hour,
+		AcceptedWindows: 2,
+		Strict:          true,
+	}
+}
+
+// Compatible() returns a preset that favors fewer false failures: 72 bit
+// tokens valid for 12 to 24 hours plus a minute of grace, and from one
+// window ahead for servers whose clocks run early. Empty session bindings
+// are allowed, so callers must take care to always pass one.
+func Compatible(key []byte) *Authenticator {
+	return &Authenticator{
+		Key:             key,
+		TokenLength:     24,
+		Lifetime:        12 * time.Hour,
+		AcceptedWindows: 2,
+		FutureWindows:   1,
+		Grace:           time.Minute,
+	}
+}
+
+// StrictOptions() returns middleware settings for a: Fetch Metadata
+// rejects cross-site and same-site requests, those without it must come
+// from the request's own origin if they name one, and a double submit
+// cookie, if WithDoubleSubmit() is added, is a __Host- cookie that is
+// Secure, HttpOnly and SameSite=Strict.
+func StrictOptions(a *Authenticator) []Option {
+	origins := &OriginChecker{SameOrigin: true, AllowMissing: true}
+	return []Option{
+		WithAuthenticator(a),
+		WithFetchMetadata(&FetchMetadata{Fallback: origins.Check}),
+		presetCookie(
+			CookieName("__Host-"+DefaultCookieName),
+			CookiePath("/"),
+			CookieDomain(""),
+			CookieSecure(true),
+			CookieHTTPOnly(true),
+			CookieSameSite(http.SameSiteStrictMode),
+		),
+	}
+}
+
+// BalancedOptions() returns middleware settings for a: Fetch Metadata
+// rejects cross-site and same-site requests, those without it only need
+// a valid token, and a double submit cookie, if WithDoubleSubmit() is
+// added, is Secure and SameSite=Lax.
+func BalancedOptions(a *Authenticator) []Option {
+	return []Option{
+		WithAuthenticator(a),
+		WithFetchMetadata(&FetchMetadata{}),
+		presetCookie(CookieSecure(true), CookieSameSite(http.SameSiteLaxMode)),
+	}
+}
+
+// CompatibleOptions() returns middleware settings for a: Fetch Metadata
+// only rejects cross-site requests, allowing sibling subdomains, and a
+// double submit cookie, if WithDoubleSubmit() is added, is Secure and
+// SameSite=Lax.
+func CompatibleOptions(a *Authenticator) []Option {
+	return []Option{
+		WithAuthenticator(a),
+		WithFetchMetadata(&FetchMetadata{AllowSameSite: true}),
+		presetCookie(CookieSecure(true), CookieSameSite(http.SameSiteLaxMode)),
+	}
+}
+
+// presetCookie sets cookie attributes that apply only with a DoubleSubmit,
+// before those of WithCookie().
+func presetCookie(opts ...CookieOption) Option {
+	return func(m *Middleware) {
+		m.presetCookie = opts
+	}
+}
@@ -0,0 +1,26 @@
+package csrf
+
+import "encoding/binary"
+
+// bind combines a purpose label, the session and any extra values into one
+// unambiguous session binding, so a token minted for one purpose never
+// validates for another. Each part is length-prefixed.
+func bind(purpose string, session []byte, extra ...[]byte) []byte {
+	size := len(purpose) + len(session) + 2*binary.MaxVarintLen64
+	for _, e := range extra {
+		size += len(e) + binary.MaxVarintLen64
+	}
+	b := make([]byte, 0, size)
+	b = appendPart(b, []byte(purpose))
+	b = appendPart(b, session)
+	for _, e := range extra {
+		b = appendPart(b, e)
+	}
+	return b
+}
+
+func appendPart(b, part []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(part)))]...)
+	return append(b, part...)
+}
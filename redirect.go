@@ -0,0 +1,42 @@
+package csrf
+
+import (
+	"context"
+	"time"
+)
+
+const redirectPurpose = "redirect"
+
+// SignRedirect() returns target prefixed with a token binding it to the
+// session, for use as a return_to parameter. The signature expires like
+// any other token, and tokens for other purposes never validate as
+// redirect signatures.
+func (a *Authenticator) SignRedirect(date time.Time, session []byte, target string) (string, error) {
+	if err := a.checkSession(session); err != nil {
+		return "", err
+	}
+	token, err := a.plain().GenerateTokenErr(date, bind(redirectPurpose, session, []byte(target)))
+	if err != nil {
+		return "", err
+	}
+	return token + target, nil
+}
+
+// ValidateRedirect() checks a value made by SignRedirect() and returns the
+// target URL. It returns false if the value was not signed for this
+// session or the signature has expired.
+func (a *Authenticator) ValidateRedirect(date time.Time, session []byte, signed string) (string, bool) {
+	if len(signed) < a.tokenLength() {
+		a.logger().Warn("csrf: redirect rejected", "reason", ErrWrongLength, "length", len(signed))
+		return "", false
+	}
+	if err := a.checkSession(session); err != nil {
+		a.logger().Warn("csrf: redirect rejected", "reason", err)
+		return "", false
+	}
+	token, target := signed[:a.tokenLength()], signed[a.tokenLength():]
+	if !a.validToken(context.Background(), date, session, bind(redirectPurpose, session, []byte(target)), token) {
+		return "", false
+	}
+	return target, true
+}
@@ -0,0 +1,128 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReplayCells  = 1 << 20
+	defaultReplayHashes = 4
+)
+
+// ReplayFilter approximately counts how often each token is validated, in
+// a counting Bloom filter per time window, and flags tokens seen more than
+// MaxUses times. It is a middle ground between stateless tokens, which
+// can be replayed freely until they expire, and UsedTokens, which
+// remembers every token exactly: memory is fixed at Cells bytes per
+// accepted window whatever the traffic, but collisions can flag a token
+// before it reaches MaxUses, more often as a window fills up. A token is
+// never let through more than MaxUses times.
+//
+// Set it as the Authenticator's Replays. Flagged tokens are rejected with
+// ErrTokenReplayed. Filters are per process; use UsedTokens with a shared
+// Store to catch replays across servers.
+type ReplayFilter struct {
+	// MaxUses is how many times a token validates before it is flagged, 1
+	// if unset. Allow a few uses for pages that resubmit a form.
+	MaxUses int
+	// Cells is the number of counters per window, 1<<20 if unset. Keep it
+	// well above Hashes times the tokens validated per window.
+	Cells int
+	// Hashes is the number of counters per token, 4 if unset
+	Hashes int
+
+	mutex   sync.Mutex
+	seed    [16]byte
+	windows map[int64][]uint8
+}
+
+func (f *ReplayFilter) maxUses() int {
+	if f.MaxUses > 0 {
+		return f.MaxUses
+	}
+	return 1
+}
+
+func (f *ReplayFilter) cells() int {
+	if f.Cells > 0 {
+		return f.Cells
+	}
+	return defaultReplayCells
+}
+
+func (f *ReplayFilter) hashes() int {
+	if f.Hashes > 0 {
+		return f.Hashes
+	}
+	return defaultReplayHashes
+}
+
+// Seen() records a use of token, generated in window counter, and returns
+// how many times it has been seen, possibly overestimated. Windows before
+// oldest are forgotten. Counts saturate at 255.
+func (f *ReplayFilter) Seen(counter, oldest int64, token string) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.windows == nil {
+		if _, err := rand.Read(f.seed[:]); err != nil {
+			return 0, err
+		}
+		f.windows = make(map[int64][]uint8)
+	}
+	for c := range f.windows {
+		if c < oldest {
+			delete(f.windows, c)
+		}
+	}
+	cells, ok := f.windows[counter]
+	if !ok {
+		cells = make([]uint8, f.cells())
+		f.windows[counter] = cells
+	}
+
+	// The seed keeps attackers from choosing tokens that collide.
+	h := sha256.New()
+	h.Write(f.seed[:])
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum)
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+
+	// Increment only the smallest counters, which overestimates less.
+	n := uint64(len(cells))
+	count := uint8(255)
+	for i := 0; i < f.hashes(); i++ {
+		if c := cells[(h1+uint64(i)*h2)%n]; c < count {
+			count = c
+		}
+	}
+	if count < 255 {
+		count++
+	}
+	for i := 0; i < f.hashes(); i++ {
+		if cell := &cells[(h1+uint64(i)*h2)%n]; *cell < count {
+			*cell = count
+		}
+	}
+	return int(count), nil
+}
+
+// checkReplays rejects a token the Replays filter has seen too often.
+func (a *Authenticator) checkReplays(date time.Time, counter int64, token string) error {
+	_, oldest, err := a.acceptedRange(date)
+	if err != nil {
+		return err
+	}
+	count, err := a.Replays.Seen(counter, oldest, token)
+	if err != nil {
+		return err
+	}
+	if count > a.Replays.maxUses() {
+		return ErrTokenReplayed
+	}
+	return nil
+}
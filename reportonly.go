@@ -0,0 +1,39 @@
+package csrf
+
+import "sync/atomic"
+
+// enforcement is the mode of a Middleware. Copies of the Middleware, such
+// as those of Wrap() and of each tenant, share it, so SetReportOnly()
+// reaches all of them.
+type enforcement struct {
+	reportOnly int32
+}
+
+// WithReportOnly() starts the middleware in report-only mode: unsafe
+// requests failing the checks are logged, counted and observed as usual,
+// then passed on instead of rejected. Use it to roll out protection on a
+// site whose clients may not all send tokens yet, and SetReportOnly() to
+// start enforcing.
+func WithReportOnly() Option {
+	return func(m *Middleware) {
+		m.SetReportOnly(true)
+	}
+}
+
+// SetReportOnly() turns report-only mode on or off while serving; see
+// WithReportOnly().
+func (m *Middleware) SetReportOnly(on bool) {
+	if m.mode == nil {
+		m.mode = &enforcement{}
+	}
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&m.mode.reportOnly, v)
+}
+
+// ReportOnly() reports whether m passes on the requests it would reject.
+func (m *Middleware) ReportOnly() bool {
+	return m.mode != nil && atomic.LoadInt32(&m.mode.reportOnly) != 0
+}
@@ -0,0 +1,97 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"net"
+	"net/http"
+)
+
+const requestPurpose = "request"
+
+// TLSExporterLabel is the label of the keying material TLSChannel binds,
+// exported as in RFC 5705 and RFC 8446.
+const TLSExporterLabel = "EXPORTER-csrf-token-binding"
+
+// tlsChannelLength is how many bytes of keying material are bound.
+const tlsChannelLength = 32
+
+// RequestBinding mixes attributes of the request into its session
+// binding, so a token stolen by an attacker on another network or browser
+// does not validate for them. It is off by default because it rejects
+// legitimate users whose attributes change while a form is open, such as
+// mobile users moving between networks or browsers updating themselves.
+type RequestBinding struct {
+	// IPv4Prefix and IPv6Prefix are how many leading bits of the client
+	// address are bound, such as 24 and 48. Zero leaves addresses of that
+	// family unbound.
+	IPv4Prefix int
+	IPv6Prefix int
+	// UserAgent binds a hash of the User-Agent header.
+	UserAgent bool
+	// ClientIP, if set, returns the client address instead of
+	// r.RemoteAddr, for servers behind a trusted proxy.
+	ClientIP func(r *http.Request) string
+	// TLSChannel binds keying material exported from the TLS connection,
+	// so a token only validates on the connection it was issued on, even
+	// if it is stolen. Requests without it, over plain HTTP or TLS 1.2
+	// without extended master secret, are rejected. Browsers open new
+	// connections at will, so it suits API clients holding one
+	// connection, and it needs TLS to end at this server, not a proxy.
+	TLSChannel bool
+}
+
+// BindRequest() returns session bound to the attributes of r selected by
+// RequestBinding, or session itself if RequestBinding is nil. Protect()
+// and the policy clauses call it for every request; call it yourself when
+// generating tokens they will validate. A session that Strict mode
+// rejects is returned unchanged so it is still rejected.
+func (a *Authenticator) BindRequest(r *http.Request, session []byte) []byte {
+	b := a.RequestBinding
+	if b == nil || a.checkSession(session) != nil {
+		return session
+	}
+	var prefix, agent []byte
+	if ip := net.ParseIP(b.clientIP(r)); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			if b.IPv4Prefix > 0 {
+				prefix = v4.Mask(net.CIDRMask(b.IPv4Prefix, 32))
+			}
+		} else if b.IPv6Prefix > 0 {
+			prefix = ip.Mask(net.CIDRMask(b.IPv6Prefix, 128))
+		}
+	}
+	if b.UserAgent {
+		sum := sha256.Sum256([]byte(r.UserAgent()))
+		agent = sum[:]
+	}
+	if b.TLSChannel {
+		return bind(requestPurpose, session, prefix, agent, tlsChannel(r))
+	}
+	return bind(requestPurpose, session, prefix, agent)
+}
+
+// tlsChannel returns keying material exported from the TLS connection of
+// r, or random bytes no other request shares if there is none.
+func tlsChannel(r *http.Request) []byte {
+	if r.TLS != nil {
+		if ekm, err := r.TLS.ExportKeyingMaterial(TLSExporterLabel, nil, tlsChannelLength); err == nil {
+			return ekm
+		}
+	}
+	unbound := make([]byte, tlsChannelLength)
+	rand.Read(unbound)
+	return unbound
+}
+
+// clientIP returns the client address of r without port.
+func (b *RequestBinding) clientIP(r *http.Request) string {
+	if b.ClientIP != nil {
+		return b.ClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
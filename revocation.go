@@ -0,0 +1,124 @@
+package csrf
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+// SessionRevoker reports sessions that have been killed by the application,
+// for example by "log out everywhere" or an administrator. Tokens bound to a
+// revoked session fail validation even if they have not expired.
+type SessionRevoker interface {
+	SessionRevoked(session []byte) bool
+}
+
+// SessionRevokerContext is implemented by revokers that consult a remote
+// service and can honor cancellation. It is used instead of
+// SessionRevoked() when available.
+type SessionRevokerContext interface {
+	SessionRevokedContext(ctx context.Context, session []byte) (bool, error)
+}
+
+func (a *Authenticator) sessionRevoked(ctx context.Context, session []byte) (bool, error) {
+	if a.Revocations == nil {
+		return false, nil
+	}
+	if c, ok := a.Revocations.(SessionRevokerContext); ok {
+		return c.SessionRevokedContext(ctx, session)
+	}
+	return a.Revocations.SessionRevoked(session), nil
+}
+
+// ErrRevocationCacheFull is returned by RevocationCache.Revoke() when the
+// cache holds Size unexpired sessions.
+var ErrRevocationCacheFull = errors.New("csrf: revocation cache full")
+
+// RevocationCache is a small in-memory SessionRevoker. It remembers at most
+// Size sessions for TTL each. TTL should be at least twice the
+// Authenticator Lifetime so every token issued before the revocation has
+// expired by the time the entry is forgotten. Entries are never dropped
+// before TTL, since that would silently restore a killed session; Revoke()
+// fails instead once Size sessions are held, so size the cache for the
+// revocations expected within TTL.
+type RevocationCache struct {
+	Size int
+	TTL  time.Duration
+	// Now, if set, is the clock, normally the Authenticator Now
+	Now func() time.Time
+
+	mutex   sync.Mutex
+	order   list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type revocation struct {
+	key     [sha256.Size]byte
+	expires time.Time
+}
+
+// NewRevocationCache() creates a cache holding up to size sessions for ttl.
+func NewRevocationCache(size int, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{Size: size, TTL: ttl}
+}
+
+// Revoke() records that session has been killed. It returns
+// ErrRevocationCacheFull, without recording it, if the cache already holds
+// Size sessions that have not expired.
+func (c *RevocationCache) Revoke(session []byte) error {
+	key := sha256.Sum256(session)
+	now := c.now()
+	expires := now.Add(c.TTL)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]*list.Element)
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*revocation).expires = expires
+		c.order.MoveToBack(e)
+		return nil
+	}
+	// entries are ordered by expiry, as they all live for TTL
+	for e := c.order.Front(); e != nil && now.After(e.Value.(*revocation).expires); e = c.order.Front() {
+		c.remove(e)
+	}
+	if c.Size > 0 && c.order.Len() >= c.Size {
+		return ErrRevocationCacheFull
+	}
+	c.entries[key] = c.order.PushBack(&revocation{key: key, expires: expires})
+	return nil
+}
+
+// SessionRevoked() returns true if session was revoked within TTL.
+func (c *RevocationCache) SessionRevoked(session []byte) bool {
+	key := sha256.Sum256(session)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	if c.now().After(e.Value.(*revocation).expires) {
+		c.remove(e)
+		return false
+	}
+	return true
+}
+
+func (c *RevocationCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *RevocationCache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*revocation).key)
+}
@@ -0,0 +1,151 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// revokedAuthenticator returns an Authenticator whose Revocations can be
+// filled by the test.
+func revokedAuthenticator(t *testing.T) (*Authenticator, *RevocationCache) {
+	t.Helper()
+	a := testAuthenticator(t)
+	revocations := NewRevocationCache(16, time.Hour)
+	a.Revocations = revocations
+	return a, revocations
+}
+
+func revoke(t *testing.T, c *RevocationCache, session []byte) {
+	t.Helper()
+	if err := c.Revoke(session); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtectRevokedSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		binding *RequestBinding
+		action  bool
+	}{
+		{name: "plain"},
+		{name: "request binding", binding: &RequestBinding{IPv4Prefix: 24, UserAgent: true}},
+		{name: "action binding", action: true},
+		{name: "request and action binding", binding: &RequestBinding{IPv4Prefix: 24, UserAgent: true}, action: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, revocations := revokedAuthenticator(t)
+			a.RequestBinding = tt.binding
+			opts := []Option{
+				WithAuthenticator(a),
+				WithSession(func(*http.Request) ([]byte, error) { return testSession, nil }),
+			}
+			if tt.action {
+				opts = append(opts, WithActionBinding())
+			}
+			h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), opts...)
+
+			newRequest := func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/account/delete", nil)
+				r.Header.Set("User-Agent", "test")
+				return r
+			}
+			r := newRequest()
+			session := a.BindRequest(r, testSession)
+			var token string
+			var err error
+			if tt.action {
+				token, err = a.GenerateTokenFor(time.Now(), session, http.MethodPost, "/account/delete")
+			} else {
+				token, err = a.GenerateTokenErr(time.Now(), session)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			send := func() int {
+				r := newRequest()
+				r.Header.Set("X-CSRF-Token", token)
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				return w.Code
+			}
+
+			if code := send(); code != http.StatusOK {
+				t.Fatalf("before Revoke() got %d, want %d", code, http.StatusOK)
+			}
+			revoke(t, revocations, testSession)
+			if code := send(); code != http.StatusForbidden {
+				t.Fatalf("after Revoke() got %d, want %d", code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestValidateTokenForRevokedSession(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	now := time.Now()
+	token, err := a.GenerateTokenFor(now, testSession, http.MethodPost, "/transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ValidateTokenFor(now, testSession, http.MethodPost, "/transfer", token); err != nil {
+		t.Fatal(err)
+	}
+	revoke(t, revocations, testSession)
+	if err := a.ValidateTokenFor(now, testSession, http.MethodPost, "/transfer", token); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("got %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestValidateScopedTokenRevokedSession(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	now := time.Now()
+	token, err := a.GenerateScopedToken(now, testSession, "delete-account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ValidateScopedToken(now, testSession, "delete-account", token); err != nil {
+		t.Fatal(err)
+	}
+	revoke(t, revocations, testSession)
+	if err := a.ValidateScopedToken(now, testSession, "delete-account", token); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("got %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestConfirmerRevokedSession(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	c := &Confirmer{Authenticator: a, Lifetime: time.Minute}
+	now := time.Now()
+	token, err := c.GenerateToken(now, testSession, "delete-account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.ValidateToken(now, testSession, "delete-account", token) {
+		t.Fatal("confirmation rejected before Revoke()")
+	}
+	revoke(t, revocations, testSession)
+	if c.ValidateToken(now, testSession, "delete-account", token) {
+		t.Fatal("confirmation accepted after Revoke()")
+	}
+}
+
+func TestValidateRedirectRevokedSession(t *testing.T) {
+	a, revocations := revokedAuthenticator(t)
+	now := time.Now()
+	signed, err := a.SignRedirect(now, testSession, "/settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, ok := a.ValidateRedirect(now, testSession, signed); !ok || target != "/settings" {
+		t.Fatalf("before Revoke() got %q, %v", target, ok)
+	}
+	revoke(t, revocations, testSession)
+	if _, ok := a.ValidateRedirect(now, testSession, signed); ok {
+		t.Fatal("redirect accepted after Revoke()")
+	}
+}
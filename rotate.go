@@ -0,0 +1,91 @@
+package csrf
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// keySet is a primary key and its secondary keys installed by SetKeys().
+type keySet struct {
+	key       []byte
+	secondary []SecondaryKey
+}
+
+// SetKeys() replaces Key and SecondaryKeys in one atomic step, so a
+// running server can rotate keys on a configuration reload or KMS refresh
+// while requests are in flight. Each request uses either the old or the
+// new keys, never a mix, and afterwards Key and SecondaryKeys are ignored.
+// Pass the retiring key as a secondary until twice Lifetime has passed.
+// With KeyID set, all the keys share it and validation tries each; use a
+// KeyProvider to rotate with distinct IDs. SetKeys() returns ErrKeyLength
+// for a primary key shorter than MinKeyLength, and ErrKeyProvider if Keys
+// is set.
+func (a *Authenticator) SetKeys(primary []byte, secondaries ...[]byte) error {
+	if a.isClosed() {
+		return ErrClosed
+	}
+	if a.Keys != nil {
+		return ErrKeyProvider
+	}
+	if len(primary) < MinKeyLength {
+		return ErrKeyLength
+	}
+	s := &keySet{key: primary}
+	for _, k := range secondaries {
+		s.secondary = append(s.secondary, SecondaryKey{ID: a.KeyID, Key: k})
+	}
+	if a.ownsKeys {
+		// requests may still use the old copies, so they are left for
+		// the garbage collector rather than zeroed
+		s.key = append([]byte(nil), s.key...)
+		s.secondary = copySecondaryKeys(s.secondary)
+	}
+	a.keyRotation().keys.Store(s)
+	return nil
+}
+
+// keyRotation holds the keys installed by SetKeys(). Copies of an
+// Authenticator made by clone() share it, so rotations reach them too.
+// They also share the key buffers, so closed is set when Close() zeroes
+// them, and every copy then fails with ErrClosed instead of using zeroed
+// keys.
+type keyRotation struct {
+	keys   atomic.Value // *keySet
+	closed int32
+}
+
+// keyRotation returns the keyRotation of a, creating it on first use.
+func (a *Authenticator) keyRotation() *keyRotation {
+	if p := atomic.LoadPointer(&a.rotation); p != nil {
+		return (*keyRotation)(p)
+	}
+	atomic.CompareAndSwapPointer(&a.rotation, nil, unsafe.Pointer(&keyRotation{}))
+	return (*keyRotation)(atomic.LoadPointer(&a.rotation))
+}
+
+// rotated returns the keys set by SetKeys(), or nil if there are none.
+func (a *Authenticator) rotated() *keySet {
+	p := atomic.LoadPointer(&a.rotation)
+	if p == nil {
+		return nil
+	}
+	s, _ := (*keyRotation)(p).keys.Load().(*keySet)
+	return s
+}
+
+// clone returns a copy of a sharing its keyRotation, for variants of a
+// with some fields overridden. Copy an Authenticator only through clone,
+// since a plain copy races with SetKeys().
+func (a *Authenticator) clone() *Authenticator {
+	a.keyRotation()
+	b := *a
+	return &b
+}
+
+// staticKeys returns Key and SecondaryKeys, or those set by SetKeys().
+func (a *Authenticator) staticKeys() ([]byte, []SecondaryKey) {
+	if s := a.rotated(); s != nil {
+		return s.key, s.secondary
+	}
+	return a.Key, a.SecondaryKeys
+}
@@ -0,0 +1,37 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetKeysReachesCopies(t *testing.T) {
+	a := testAuthenticator(t)
+	frozen := Freeze(a)
+	clone := a.clone()
+
+	key := make([]byte, MinKeyLength)
+	for i := range key {
+		key[i] = byte(255 - i)
+	}
+	if err := a.SetKeys(key); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewAuthenticator(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for name, generate := range map[string]func() (string, error){
+		"Freeze()": func() (string, error) { return frozen.GenerateTokenErr(now, testSession) },
+		"clone()":  func() (string, error) { return clone.GenerateTokenErr(now, testSession) },
+	} {
+		token, err := generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rotated.ValidateTokenErr(now, testSession, token); err != nil {
+			t.Errorf("%s does not use the rotated key: %v", name, err)
+		}
+	}
+}
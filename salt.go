@@ -0,0 +1,42 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// salt returns n random characters from the token alphabet.
+func (a *Authenticator) salt(n int) ([]byte, error) {
+	alphabet := a.alphabet()
+	salt := make([]byte, n)
+	source := a.random()
+
+	// reject bytes past the largest multiple of the alphabet size, so
+	// every character is equally likely
+	limit := 256 - 256%len(alphabet)
+	var buf [64]byte
+	for i := 0; i < n; {
+		chunk := buf[:]
+		if want := (n-i)*3/2 + 1; want < len(chunk) {
+			chunk = chunk[:want]
+		}
+		if _, err := io.ReadFull(source, chunk); err != nil {
+			return nil, err
+		}
+		for _, b := range chunk {
+			if int(b) < limit && i < n {
+				salt[i] = alphabet[int(b)%len(alphabet)]
+				i++
+			}
+		}
+	}
+	return salt, nil
+}
+
+// random returns Rand, or crypto/rand if it is nil.
+func (a *Authenticator) random() io.Reader {
+	if a.Rand != nil {
+		return a.Rand
+	}
+	return rand.Reader
+}
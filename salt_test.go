@@ -0,0 +1,53 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+)
+
+// The parallel benchmarks show how generation scales with cores; compare
+// ns/op across go test -bench Parallel -cpu 1,2,4,8.
+
+func BenchmarkSaltParallel(b *testing.B) {
+	a := testAuthenticator(b)
+	n := a.saltLength()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := a.salt(n); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGenerateTokenParallel(b *testing.B) {
+	a := testAuthenticator(b)
+	date := time.Now()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := a.GenerateTokenErr(date, testSession); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkValidateTokenParallel(b *testing.B) {
+	a := testAuthenticator(b)
+	date := time.Now()
+	token, err := a.GenerateTokenErr(date, testSession)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := a.ValidateTokenErr(date, testSession, token); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+//go:build go1.22
+
+package csrf
+
+import "net/http"
+
+// ServeMux is an http.ServeMux whose routes are all wrapped with Protect(),
+// for applications routing with the method patterns of Go 1.22. Routes for
+// GET, and patterns without a method, issue tokens on safe requests; routes
+// for POST, PUT, PATCH and DELETE require one. The mux answers methods a
+// path has no route for with 405 before any check. Method patterns need
+// the main module to declare go 1.22 or later; older modules get the old
+// ServeMux unless GODEBUG has httpmuxgo121=0.
+//
+//	mux := csrf.NewServeMux(csrf.WithAuthenticator(a), csrf.WithSession(session))
+//	mux.Get("/items/{id}", showItem)
+//	mux.Post("/items/{id}", updateItem)
+//	http.ListenAndServe(":8080", mux)
+type ServeMux struct {
+	mux  *http.ServeMux
+	wrap func(http.Handler) http.Handler
+}
+
+// NewServeMux() returns a ServeMux protecting its routes with opts. It
+// panics like Protect() on a misconfiguration.
+func NewServeMux(opts ...Option) *ServeMux {
+	return &ServeMux{mux: http.NewServeMux(), wrap: Wrap(opts...)}
+}
+
+// Handle() registers h for pattern, such as "POST /items/{id}", as
+// http.ServeMux.Handle() does.
+func (s *ServeMux) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, s.wrap(h))
+}
+
+// HandleFunc() registers h for pattern.
+func (s *ServeMux) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	s.Handle(pattern, http.HandlerFunc(h))
+}
+
+// Get() registers h for GET and HEAD requests to path, which are issued a
+// token.
+func (s *ServeMux) Get(path string, h http.HandlerFunc) {
+	s.Handle(http.MethodGet+" "+path, h)
+}
+
+// Post() registers h for POST requests to path, which must carry a token.
+func (s *ServeMux) Post(path string, h http.HandlerFunc) {
+	s.Handle(http.MethodPost+" "+path, h)
+}
+
+// Put() registers h for PUT requests to path, which must carry a token.
+func (s *ServeMux) Put(path string, h http.HandlerFunc) {
+	s.Handle(http.MethodPut+" "+path, h)
+}
+
+// Patch() registers h for PATCH requests to path, which must carry a
+// token.
+func (s *ServeMux) Patch(path string, h http.HandlerFunc) {
+	s.Handle(http.MethodPatch+" "+path, h)
+}
+
+// Delete() registers h for DELETE requests to path, which must carry a
+// token.
+func (s *ServeMux) Delete(path string, h http.HandlerFunc) {
+	s.Handle(http.MethodDelete+" "+path, h)
+}
+
+// Unprotected() registers h for pattern without CSRF protection, for
+// routes such as webhooks that authenticate requests another way.
+func (s *ServeMux) Unprotected(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// ServeHTTP() dispatches r to the handler of the route it matches.
+func (s *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,110 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionType is returned for typed sessions that cannot be converted
+// to bytes.
+var ErrSessionType = errors.New("csrf: session must be []byte, string, encoding.BinaryMarshaler or fmt.Stringer")
+
+// SessionNormalizer maps a session binding to the bytes mixed into tokens.
+type SessionNormalizer func(session []byte) []byte
+
+// HashSession canonicalizes a session blob by trimming surrounding
+// whitespace and reduces it to its SHA-256 digest, so serialized session
+// structs of any size bind tokens as a fixed 32 byte value.
+func HashSession(session []byte) []byte {
+	sum := sha256.Sum256(bytes.TrimSpace(session))
+	return sum[:]
+}
+
+func (a *Authenticator) checkSession(session []byte) error {
+	if !a.Strict {
+		return nil
+	}
+	if len(session) == 0 {
+		return ErrEmptySession
+	}
+	if len(session) < a.MinSessionLength {
+		return ErrShortSession
+	}
+	return nil
+}
+
+func (a *Authenticator) normalizeSession(session []byte) []byte {
+	if a.Session == nil {
+		return session
+	}
+	return a.Session(session)
+}
+
+// SessionBytes() converts a typed session identifier to the bytes used to
+// bind tokens. It accepts []byte, string, encoding.BinaryMarshaler and
+// fmt.Stringer, preferring BinaryMarshaler when both are implemented.
+func SessionBytes(session interface{}) ([]byte, error) {
+	switch s := session.(type) {
+	case []byte:
+		return s, nil
+	case string:
+		return []byte(s), nil
+	case encoding.BinaryMarshaler:
+		return s.MarshalBinary()
+	case fmt.Stringer:
+		return []byte(s.String()), nil
+	}
+	return nil, ErrSessionType
+}
+
+// GenerateTokenFrom() is like GenerateTokenErr() but takes a typed
+// session, converted with SessionBytes().
+func (a *Authenticator) GenerateTokenFrom(date time.Time, session interface{}) (string, error) {
+	b, err := SessionBytes(session)
+	if err != nil {
+		return "", err
+	}
+	return a.GenerateTokenErr(date, b)
+}
+
+// ValidateTokenFrom() is like ValidateToken() but takes a typed session,
+// converted with SessionBytes().
+func (a *Authenticator) ValidateTokenFrom(date time.Time, session interface{}, token string) bool {
+	b, err := SessionBytes(session)
+	if err != nil {
+		a.logger().Warn("csrf: token rejected", "reason", err)
+		return false
+	}
+	return a.ValidateToken(date, b, token)
+}
+
+// ValidateTokenAnySession() is like ValidateTokenErr() but accepts a
+// token made for any of sessions, such as the current session and the
+// one it replaced. Pass the previous session only for a short overlap
+// after rotating the session on login or privilege change, so forms
+// already open keep working. If no session matches, the error is the one
+// for sessions[0].
+func (a *Authenticator) ValidateTokenAnySession(date time.Time, sessions [][]byte, token string) error {
+	if len(sessions) == 0 {
+		return a.ValidateTokenErr(date, nil, token)
+	}
+	ctx := context.Background()
+	var first error
+	for i, session := range sessions {
+		_, err := a.verify(ctx, date, session, session, token, true)
+		if err == nil {
+			first = nil
+			break
+		}
+		if i == 0 {
+			first = err
+		}
+	}
+	a.report(ctx, date, sessions[0], first)
+	return first
+}
@@ -0,0 +1,161 @@
+package csrf
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+)
+
+const sessionCookiePurpose = "session-cookie"
+
+// DefaultSessionCookieName is the name of the SessionCookie cookie if its
+// Cookie template has none.
+const DefaultSessionCookieName = "csrf_session"
+
+// sessionIDLength is the length of the random session identifiers a
+// SessionCookie mints.
+const sessionIDLength = 16
+
+const sessionCookieKey contextKey = 5
+
+// SessionCookie gives tokens a session binding for applications with no
+// session store at all. It mints a random session identifier, encrypted
+// and authenticated with AES-GCM under a key derived from the
+// Authenticator key, into an HttpOnly cookie, and uses it as the session.
+// Clients cannot read or choose the identifier, and any server with the
+// key can decrypt it, so stateless services need nothing else.
+type SessionCookie struct {
+	// Authenticator supplies the key
+	Authenticator *Authenticator
+	// Cookie is the template for the session cookie; its Value is set
+	// by SessionCookie. If Name is empty, a cookie named
+	// DefaultSessionCookieName with Path "/", Secure, HttpOnly and
+	// SameSite=Lax is used.
+	Cookie http.Cookie
+}
+
+// WithSessionCookie() binds tokens to the session of s, and sets its
+// cookie on safe requests that do not carry a valid one yet.
+func WithSessionCookie(s *SessionCookie) Option {
+	return func(m *Middleware) {
+		m.SessionCookie = s
+		m.Session = s.Session
+		if m.Authenticator == nil {
+			m.Authenticator = s.Authenticator
+		}
+	}
+}
+
+// NewCookie() returns the cookie carrying value, built from the Cookie
+// template.
+func (s *SessionCookie) NewCookie(value string) *http.Cookie {
+	c := s.Cookie
+	if c.Name == "" {
+		c = http.Cookie{
+			Name:     DefaultSessionCookieName,
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+	}
+	c.Value = value
+	return &c
+}
+
+// aead returns the cipher for session cookies made with key.
+func (s *SessionCookie) aead(key []byte) (cipher.AEAD, error) {
+	h := hmac.New(sha256.New, key)
+	h.Write(s.Authenticator.Pepper)
+	h.Write([]byte(sessionCookiePurpose))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// New() returns a fresh session identifier and the cookie value carrying
+// it.
+func (s *SessionCookie) New() (session []byte, value string, err error) {
+	a := s.Authenticator
+	if err := a.checkLifetime(); err != nil {
+		return nil, "", err
+	}
+	id, key := a.primaryKey()
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, "", err
+	}
+	buf := make([]byte, aead.NonceSize()+sessionIDLength)
+	if _, err := io.ReadFull(a.random(), buf); err != nil {
+		return nil, "", err
+	}
+	nonce, session := buf[:aead.NonceSize()], buf[aead.NonceSize():]
+	sealed := aead.Seal(nonce, nonce, session, []byte(sessionCookiePurpose))
+	return session, id + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open() returns the session identifier in a cookie value made by New(),
+// or ErrInvalidToken if it was not made with the key.
+func (s *SessionCookie) Open(value string) ([]byte, error) {
+	a := s.Authenticator
+	if id, _ := a.primaryKey(); len(value) < len(id) {
+		return nil, ErrInvalidToken
+	}
+	keys, body, err := a.verificationKeys(context.Background(), value)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	for _, key := range keys {
+		aead, err := s.aead(key)
+		if err != nil {
+			return nil, err
+		}
+		n := aead.NonceSize()
+		if len(sealed) < n+aead.Overhead() {
+			return nil, ErrInvalidToken
+		}
+		if session, err := aead.Open(nil, sealed[:n], sealed[n:], []byte(sessionCookiePurpose)); err == nil {
+			return session, nil
+		}
+	}
+	return nil, ErrInvalidToken
+}
+
+// Session() returns the session of r, from the cookie or one minted for
+// the request by Protect(). It is the Session function of
+// WithSessionCookie().
+func (s *SessionCookie) Session(r *http.Request) ([]byte, error) {
+	if session, ok := r.Context().Value(sessionCookieKey).([]byte); ok {
+		return session, nil
+	}
+	c, err := r.Cookie(s.NewCookie("").Name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(c.Value)
+}
+
+// ensure sets a session cookie for r if it has no valid one.
+func (s *SessionCookie) ensure(w http.ResponseWriter, r *http.Request) *http.Request {
+	if _, err := s.Session(r); err == nil {
+		return r
+	}
+	session, value, err := s.New()
+	if err != nil {
+		s.Authenticator.logger().Warn("csrf: session cookie generation failed", "reason", err)
+		return r
+	}
+	http.SetCookie(w, s.NewCookie(value))
+	return r.WithContext(context.WithValue(r.Context(), sessionCookieKey, session))
+}
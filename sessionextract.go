@@ -0,0 +1,81 @@
+package csrf
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrNoSession is returned by a SessionExtractor for a request without the
+// identity it looks for.
+var ErrNoSession = errors.New("csrf: no session in request")
+
+// SessionExtractor returns the session binding of a request. It is the
+// type of Middleware.Session, and WithSession() takes any of the ones
+// below, alone or combined with FirstSession().
+type SessionExtractor func(r *http.Request) ([]byte, error)
+
+// SessionFromCookie() binds tokens to the value of the named cookie, such
+// as the session cookie of a web framework. A missing cookie returns
+// http.ErrNoCookie and an empty one ErrNoSession.
+func SessionFromCookie(name string) SessionExtractor {
+	return func(r *http.Request) ([]byte, error) {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return nil, err
+		}
+		if c.Value == "" {
+			return nil, ErrNoSession
+		}
+		return []byte(c.Value), nil
+	}
+}
+
+// SessionFromContext() binds tokens to the request context value for key,
+// such as the authenticated user ID set by authentication middleware,
+// converted with SessionBytes(). A missing or nil value returns
+// ErrNoSession.
+func SessionFromContext(key interface{}) SessionExtractor {
+	return func(r *http.Request) ([]byte, error) {
+		v := r.Context().Value(key)
+		if v == nil {
+			return nil, ErrNoSession
+		}
+		return SessionBytes(v)
+	}
+}
+
+// SessionFromRemoteAddr() binds tokens to the client address in
+// r.RemoteAddr, without port. It is a fallback for anonymous visitors: every
+// client behind the same NAT or proxy shares a binding, and behind a
+// reverse proxy RemoteAddr must be rewritten to the real client first.
+func SessionFromRemoteAddr() SessionExtractor {
+	return func(r *http.Request) ([]byte, error) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if host == "" {
+			return nil, ErrNoSession
+		}
+		return []byte(host), nil
+	}
+}
+
+// FirstSession() returns the binding of the first extractor that finds
+// one, such as a user ID, then a session cookie, then the client address.
+// If none does, the error of the last is returned. Bindings from
+// different extractors never collide, so a user ID cannot pass for an
+// equal cookie value.
+func FirstSession(extractors ...SessionExtractor) SessionExtractor {
+	return func(r *http.Request) ([]byte, error) {
+		err := ErrNoSession
+		for i, extract := range extractors {
+			var session []byte
+			if session, err = extract(r); err == nil {
+				return append([]byte{byte(i)}, session...), nil
+			}
+		}
+		return nil, err
+	}
+}
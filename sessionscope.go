@@ -0,0 +1,25 @@
+package csrf
+
+import "time"
+
+// SessionScoped() is a WindowFunc with one window that never ends, so
+// tokens stay valid for as long as their session binding does; see
+// WithSessionScope().
+func SessionScoped(date time.Time) (int64, time.Duration) {
+	return 0, MaxLifetime
+}
+
+// WithSessionScope() makes tokens valid until their session ends instead
+// of expiring, by setting Window to SessionScoped() and Lifetime to zero.
+// It suits internal tools where a form failing after a long lunch costs
+// more than the added risk. That risk is real: a leaked token works for
+// the rest of the session, so bind tokens to a session identifier that
+// changes at every login, not a user ID, and end sessions with
+// Revocations or Epochs when needed. Expiry-based limits, such as the TTL
+// of UsedTokens entries, become MaxLifetime.
+func WithSessionScope() AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.Window = SessionScoped
+		a.Lifetime = 0
+	}
+}
@@ -0,0 +1,187 @@
+package csrf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries request signatures made by RequestSigner.
+const SignatureHeader = "X-CSRF-Signature"
+
+const signingPurpose = "request-signing"
+
+// DefaultMaxBodyBytes is how much of a request body
+// ValidateRequestSignature() reads if MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20
+
+// ErrBadSignature is returned for a missing, malformed, expired or
+// incorrect request signature.
+var ErrBadSignature = errors.New("csrf: bad request signature")
+
+// ErrBodyTooLarge is returned by ValidateRequestSignature() for a body
+// longer than MaxBodyBytes, which handlers should answer with 413
+// Request Entity Too Large. It matches ErrBadSignature.
+var ErrBodyTooLarge = fmt.Errorf("%w: body too large", ErrBadSignature)
+
+// SigningKey() derives the key a machine client uses to sign requests for
+// session. Hand it to the client once, for example at login; the
+// Authenticator Key itself never leaves the server. It returns nil after
+// Close().
+func (a *Authenticator) SigningKey(session []byte) []byte {
+	if a.isClosed() {
+		return nil
+	}
+	_, key := a.primaryKey()
+	return a.signingKey(key, session)
+}
+
+func (a *Authenticator) signingKey(key, session []byte) []byte {
+	h := hmac.New(sha512.New, key)
+	h.Write(a.Pepper)
+	h.Write(bind(signingPurpose, a.normalizeSession(session)))
+	return h.Sum(nil)
+}
+
+// signingKeys returns the signing keys of session under the primary key
+// and every secondary key, so signatures survive a rotation. With a
+// KeyProvider, only its current key is used.
+func (a *Authenticator) signingKeys(session []byte) [][]byte {
+	_, key, secondary := a.currentKeys()
+	keys := [][]byte{a.signingKey(key, session)}
+	for _, k := range secondary {
+		keys = append(keys, a.signingKey(k.Key, session))
+	}
+	return keys
+}
+
+// RequestSigner signs outgoing requests on behalf of a machine client such
+// as an Electron app or kiosk, as a stronger alternative to sending a
+// bearer-style CSRF token. Each signature covers the method, the request
+// URI, a hash of the body and the current time window.
+type RequestSigner struct {
+	// Key is the result of Authenticator.SigningKey() for the session
+	Key []byte
+	// Lifetime, TOTP, T0 and Window must match those of the
+	// Authenticator, so both sides derive the same time windows.
+	Lifetime time.Duration
+	TOTP     bool
+	T0       time.Time
+	Window   WindowFunc
+}
+
+// counter returns the time window containing date, derived like that of
+// the Authenticator.
+func (s *RequestSigner) counter(date time.Time) (int64, error) {
+	a := &Authenticator{Lifetime: s.Lifetime, TOTP: s.TOTP, T0: s.T0, Window: s.Window}
+	if err := a.checkLifetime(); err != nil {
+		return 0, err
+	}
+	return a.counter(date)
+}
+
+// Sign() sets the signature header on r. The body is read in full and
+// replaced, so r can still be sent. It returns ErrLifetime for a Lifetime
+// the Authenticator would reject.
+func (s *RequestSigner) Sign(r *http.Request, date time.Time) error {
+	counter, err := s.counter(date)
+	if err != nil {
+		return err
+	}
+	body, err := readBody(r, 0)
+	if err != nil {
+		return err
+	}
+	mac := requestMAC(s.Key, r, body, counter)
+	r.Header.Set(SignatureHeader, strconv.FormatInt(counter, 10)+":"+base64.RawURLEncoding.EncodeToString(mac))
+	return nil
+}
+
+// ValidateRequestSignature() checks the signature header of a request made
+// by a RequestSigner holding SigningKey(session), made with the current
+// key or a secondary one. Signatures are accepted in the same windows as
+// tokens, as set by AcceptedWindows, FutureWindows and Grace. The body is
+// read in full, up to MaxBodyBytes, and replaced, so handlers can still
+// read it.
+func (a *Authenticator) ValidateRequestSignature(date time.Time, session []byte, r *http.Request) error {
+	header := r.Header.Get(SignatureHeader)
+	i := strings.IndexByte(header, ':')
+	if i < 0 {
+		return ErrBadSignature
+	}
+	counter, err := strconv.ParseInt(header[:i], 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(header[i+1:])
+	if err != nil {
+		return ErrBadSignature
+	}
+	newest, oldest, err := a.acceptedRange(date)
+	if err != nil {
+		return err
+	}
+	if counter > newest || counter < oldest {
+		return ErrBadSignature
+	}
+
+	limit := a.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body, err := readBody(r, limit)
+	if err != nil {
+		return err
+	}
+	for _, key := range a.signingKeys(session) {
+		if hmac.Equal(mac, requestMAC(key, r, body, counter)) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+func requestMAC(key []byte, r *http.Request, body []byte, counter int64) []byte {
+	bodyHash := sha256.Sum256(body)
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, r.Method)
+	io.WriteString(h, "\n")
+	io.WriteString(h, r.URL.RequestURI())
+	io.WriteString(h, "\n")
+	io.WriteString(h, hex.EncodeToString(bodyHash[:]))
+	io.WriteString(h, "\n")
+	io.WriteString(h, strconv.FormatInt(counter, 10))
+	return h.Sum(nil)
+}
+
+// readBody reads the body of r and replaces it, so it can be read again.
+// With a positive limit, it returns ErrBodyTooLarge for a longer body.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	reader := r.Body
+	if limit > 0 {
+		reader = http.MaxBytesReader(nil, r.Body, limit)
+	}
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil && limit > 0 && int64(len(body)) >= limit {
+		return nil, ErrBodyTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,78 @@
+package csrf
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(t *testing.T, a *Authenticator, date time.Time, body string) *signedBody {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/transfer", strings.NewReader(body))
+	s := &RequestSigner{Key: a.SigningKey(testSession), Lifetime: a.Lifetime}
+	if err := s.Sign(r, date); err != nil {
+		t.Fatal(err)
+	}
+	return &signedBody{r.Header.Get(SignatureHeader), body}
+}
+
+// signedBody is a signature and the body it was made for, sent again
+// as a fresh request for each check.
+type signedBody struct {
+	signature, body string
+}
+
+func (s *signedBody) validate(a *Authenticator, date time.Time) error {
+	r := httptest.NewRequest("POST", "/api/transfer", strings.NewReader(s.body))
+	r.Header.Set(SignatureHeader, s.signature)
+	return a.ValidateRequestSignature(date, testSession, r)
+}
+
+func TestRequestSignatureWindows(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		accepted int
+		future   int
+		grace    time.Duration
+		signed   time.Time
+		valid    bool
+	}{
+		{name: "same window", signed: now, valid: true},
+		{name: "previous window", signed: now.Add(-time.Hour), valid: true},
+		{name: "expired", signed: now.Add(-2 * time.Hour), valid: false},
+		{name: "three windows", accepted: 3, signed: now.Add(-2 * time.Hour), valid: true},
+		{name: "client ahead", signed: now.Add(time.Hour), valid: false},
+		{name: "client ahead, future window", future: 1, signed: now.Add(time.Hour), valid: true},
+		{name: "grace", grace: time.Hour, signed: now.Add(-2 * time.Hour), valid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthenticator(t)
+			a.AcceptedWindows, a.FutureWindows, a.Grace = tt.accepted, tt.future, tt.grace
+			s := signedRequest(t, a, tt.signed, "amount=1")
+			err := s.validate(a, now)
+			if tt.valid && err != nil {
+				t.Fatalf("got %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("got %v, want %v", err, ErrBadSignature)
+			}
+		})
+	}
+}
+
+func TestRequestSignatureBodyLimit(t *testing.T) {
+	a := testAuthenticator(t)
+	a.MaxBodyBytes = 16
+	now := time.Now()
+	if err := signedRequest(t, a, now, strings.Repeat("a", 16)).validate(a, now); err != nil {
+		t.Fatalf("body at limit: got %v, want nil", err)
+	}
+	err := signedRequest(t, a, now, strings.Repeat("a", 17)).validate(a, now)
+	if !errors.Is(err, ErrBodyTooLarge) || !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("body over limit: got %v, want %v", err, ErrBodyTooLarge)
+	}
+}
@@ -0,0 +1,70 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithSlidingExpiration() makes Protect() issue a fresh token, in the
+// response header, the request context and with DoubleSubmit the cookie,
+// whenever an unsafe request passes with a token from a window before the
+// current one. A user who keeps submitting within AcceptedWindows windows
+// then never sees a token expire, provided the client picks up the new
+// token.
+func WithSlidingExpiration() Option {
+	return func(m *Middleware) {
+		m.Sliding = true
+	}
+}
+
+// WithRefresh() makes Protect() issue a fresh token, like
+// WithSlidingExpiration(), when an unsafe request that accepts text/html
+// passes with a token that has less than fraction of its lifetime left,
+// so a page re-rendered in answer, say a form with errors, does not carry
+// a token about to expire. A fraction of 0 turns it off; Protect() panics
+// if it is negative or not below 1.
+func WithRefresh(fraction float64) Option {
+	return func(m *Middleware) {
+		m.Refresh = fraction
+	}
+}
+
+// slide reissues a token for a request that passed with a token from
+// window counter, if that window is over or, with Refresh, if the token
+// is about to expire.
+func (m *Middleware) slide(w http.ResponseWriter, r *http.Request, now time.Time, counter int64) *http.Request {
+	if !m.Sliding && m.Refresh == 0 {
+		return r
+	}
+	a := m.Authenticator
+	current, err := a.counter(now)
+	if err != nil {
+		return r
+	}
+	if m.Sliding && counter < current {
+		return m.issue(w, r, now)
+	}
+	if m.Refresh > 0 && acceptsHTML(r) {
+		expiry, err := a.expiry(now, counter)
+		if err != nil {
+			return r
+		}
+		lifetime := addDurations(mulDuration(int64(a.acceptedWindows()), a.Lifetime), a.Grace)
+		if float64(expiry.Sub(now)) < m.Refresh*float64(lifetime) {
+			return m.issue(w, r, now)
+		}
+	}
+	return r
+}
+
+// acceptsHTML reports whether the Accept header of r lists text/html, as
+// browsers submitting forms send.
+func acceptsHTML(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType := strings.TrimSpace(strings.SplitN(v, ";", 2)[0]); mediaType == "text/html" {
+			return true
+		}
+	}
+	return false
+}
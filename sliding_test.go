@@ -0,0 +1,76 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefresh(t *testing.T) {
+	a := testAuthenticator(t)
+	a.Lifetime = time.Hour
+	// half way through a window, so tokens of the current window have
+	// three quarters of their two windows left and those of the previous
+	// one a quarter
+	now := time.Unix(1000*3600+1800, 0)
+	a.Now = func() time.Time { return now }
+	current, err := a.GenerateTokenErr(now, testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := a.GenerateTokenErr(now.Add(-time.Hour), testSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen string
+	h := Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = Token(r) }),
+		WithAuthenticator(a), testSessionOption, WithRefresh(0.5))
+	tests := []struct {
+		name    string
+		token   string
+		accept  string
+		refresh bool
+	}{
+		{"current window", current, "text/html,application/xhtml+xml;q=0.9", false},
+		{"previous window", previous, "text/html,application/xhtml+xml;q=0.9", true},
+		{"previous window without HTML", previous, "application/json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set(TokenHeader, tt.token)
+			r.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+			}
+			fresh := w.Header().Get(TokenHeader)
+			if refreshed := fresh != ""; refreshed != tt.refresh {
+				t.Fatalf("refreshed = %v, want %v", refreshed, tt.refresh)
+			}
+			if tt.refresh && !a.ValidateToken(now, testSession, fresh) {
+				t.Fatal("the fresh token does not validate")
+			}
+			want := tt.token
+			if tt.refresh {
+				want = fresh
+			}
+			if seen != want {
+				t.Fatalf("handler saw token %q, want %q", seen, want)
+			}
+		})
+	}
+
+	for _, fraction := range []float64{-0.5, 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("Protect() did not panic for WithRefresh(%v)", fraction)
+				}
+			}()
+			Protect(nil, WithAuthenticator(a), WithRefresh(fraction))
+		}()
+	}
+}
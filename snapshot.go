@@ -0,0 +1,139 @@
+package csrf
+
+import "sort"
+
+// ConfigSnapshot is a read-only copy of the policy a Middleware enforces,
+// as Snapshot() returns it, for admin tooling and support engineers. It
+// marshals to JSON and holds no keys or other secrets.
+type ConfigSnapshot struct {
+	// Mode is "enforce" if unsafe requests failing the checks are
+	// rejected, "report-only" if they are logged and passed on.
+	Mode string `json:"mode"`
+	// TokenSources are where submitted tokens are looked for, in order,
+	// and TokenHeaders the headers FromHeader reads.
+	TokenSources []string `json:"tokenSources"`
+	TokenHeaders []string `json:"tokenHeaders"`
+	FormField    string   `json:"formField"`
+	JSONField    string   `json:"jsonField,omitempty"`
+	WebSockets   bool     `json:"webSockets"`
+	// ExemptPaths are exempt from the token check, and Skip says whether
+	// a SkipFunc may exempt more.
+	ExemptPaths []string `json:"exemptPaths,omitempty"`
+	Skip        bool     `json:"skip"`
+	// FetchMetadata says whether Sec-Fetch-Site is checked, and Origins,
+	// if set, which origins are trusted.
+	FetchMetadata bool             `json:"fetchMetadata"`
+	Origins       *OriginsSnapshot `json:"origins,omitempty"`
+	CORS          bool             `json:"cors"`
+	DoubleSubmit  bool             `json:"doubleSubmit"`
+	BindAction    bool             `json:"bindAction"`
+	Sliding       bool             `json:"sliding"`
+	Refresh       float64          `json:"refresh,omitempty"`
+	// Tokens describes the tokens of the Authenticator, of the Default
+	// with Tenants, or nil if there is neither.
+	Tokens *TokenSnapshot `json:"tokens,omitempty"`
+	// Tenants are the tenants with their own Authenticator.
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// OriginsSnapshot is the OriginChecker of a ConfigSnapshot.
+type OriginsSnapshot struct {
+	TrustedOrigins    []string `json:"trustedOrigins"`
+	AllowMissing      bool     `json:"allowMissing"`
+	SameOrigin        bool     `json:"sameOrigin"`
+	TrustedProxies    []string `json:"trustedProxies,omitempty"`
+	RejectMixedScript bool     `json:"rejectMixedScript"`
+	IgnorePort        bool     `json:"ignorePort"`
+	IgnoreScheme      bool     `json:"ignoreScheme"`
+}
+
+// TokenSnapshot describes the tokens of an Authenticator. Durations are
+// in the form of time.Duration.String(), such as "1h0m0s".
+type TokenSnapshot struct {
+	Lifetime        string `json:"lifetime"`
+	AcceptedWindows int    `json:"acceptedWindows"`
+	FutureWindows   int    `json:"futureWindows"`
+	Grace           string `json:"grace"`
+	TOTP            bool   `json:"totp"`
+	Strict          bool   `json:"strict"`
+	SingleUse       bool   `json:"singleUse"`
+	MaxUses         int    `json:"maxUses,omitempty"`
+	Mask            bool   `json:"mask"`
+}
+
+// Snapshot() returns the configuration m enforces, with the defaults
+// filled in. With WithFrozenAuthenticator() it has the current
+// configuration. Changing the snapshot does not change m.
+func (m *Middleware) Snapshot() ConfigSnapshot {
+	if m.Frozen != nil {
+		m = m.forFrozen()
+	}
+	s := ConfigSnapshot{
+		Mode:          m.modeName(),
+		TokenHeaders:  append([]string(nil), m.headers()...),
+		FormField:     m.formField(),
+		JSONField:     m.JSONField,
+		WebSockets:    m.WebSockets,
+		ExemptPaths:   append([]string(nil), m.ExemptPaths...),
+		Skip:          m.Skip != nil,
+		FetchMetadata: m.FetchMetadata != nil,
+		CORS:          m.CORS != nil,
+		DoubleSubmit:  m.DoubleSubmit != nil,
+		BindAction:    m.BindAction,
+		Sliding:       m.Sliding,
+		Refresh:       m.Refresh,
+	}
+	for _, source := range m.sources() {
+		s.TokenSources = append(s.TokenSources, source.String())
+	}
+	if c := m.Origins; c != nil {
+		s.Origins = &OriginsSnapshot{
+			TrustedOrigins:    append([]string(nil), c.TrustedOrigins...),
+			AllowMissing:      c.AllowMissing,
+			SameOrigin:        c.SameOrigin,
+			TrustedProxies:    append([]string(nil), c.TrustedProxies...),
+			RejectMixedScript: c.RejectMixedScript,
+			IgnorePort:        c.IgnorePort,
+			IgnoreScheme:      c.IgnoreScheme,
+		}
+	}
+	a := m.Authenticator
+	if m.Tenants != nil {
+		a = m.Tenants.Default
+		s.Tenants = m.Tenants.names()
+	}
+	if a != nil {
+		s.Tokens = &TokenSnapshot{
+			Lifetime:        a.Lifetime.String(),
+			AcceptedWindows: a.acceptedWindows(),
+			FutureWindows:   a.futureWindows(),
+			Grace:           a.Grace.String(),
+			TOTP:            a.TOTP,
+			Strict:          a.Strict,
+			SingleUse:       a.UsedTokens != nil,
+			MaxUses:         a.MaxUses,
+			Mask:            a.Mask,
+		}
+	}
+	return s
+}
+
+// modeName returns the Mode of a ConfigSnapshot of m.
+func (m *Middleware) modeName() string {
+	if m.ReportOnly() {
+		return "report-only"
+	}
+	return "enforce"
+}
+
+// names returns the tenants of s in order.
+func (s *AuthenticatorSet) names() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	names := make([]string, 0, len(s.tenants))
+	for tenant := range s.tenants {
+		names = append(names, tenant)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,67 @@
+package csrf
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	a := testAuthenticator(t)
+	a.Lifetime = time.Hour
+	origins := &OriginChecker{TrustedOrigins: []string{"https://app.example"}, SameOrigin: true}
+	m := Protect(nil, WithAuthenticator(a), testSessionOption,
+		WithOriginChecker(origins), WithExemptPaths("/hooks/"), WithStreamingUploads(),
+		WithJSONField("csrf")).(*Middleware)
+	s := m.Snapshot()
+	want := ConfigSnapshot{
+		Mode:         "enforce",
+		TokenSources: []string{"header", "query", "form", "multipart", "json"},
+		TokenHeaders: []string{TokenHeader},
+		FormField:    TokenField,
+		JSONField:    "csrf",
+		ExemptPaths:  []string{"/hooks/"},
+		Origins: &OriginsSnapshot{
+			TrustedOrigins: []string{"https://app.example"},
+			SameOrigin:     true,
+		},
+		Tokens: &TokenSnapshot{
+			Lifetime:        "1h0m0s",
+			AcceptedWindows: 2,
+			Grace:           a.Grace.String(),
+			Strict:          true,
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("got %+v, want %+v", s, want)
+	}
+
+	s.Origins.TrustedOrigins[0] = "https://evil.example"
+	if origins.TrustedOrigins[0] != "https://app.example" {
+		t.Fatal("changing the snapshot changed the OriginChecker")
+	}
+	body, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"mode":"enforce"`, `"lifetime":"1h0m0s"`, `"exemptPaths":["/hooks/"]`} {
+		if !strings.Contains(string(body), field) {
+			t.Fatalf("%s lacks %s", body, field)
+		}
+	}
+}
+
+func TestSnapshotTenants(t *testing.T) {
+	set := &AuthenticatorSet{Default: testAuthenticator(t)}
+	set.Set("b.example", testAuthenticator(t))
+	set.Set("a.example", testAuthenticator(t))
+	s := Protect(nil, WithTenants(set, HostTenant)).(*Middleware).Snapshot()
+	if want := []string{"a.example", "b.example"}; !reflect.DeepEqual(s.Tenants, want) {
+		t.Fatalf("got tenants %q, want %q", s.Tenants, want)
+	}
+	if s.Tokens == nil {
+		t.Fatal("no tokens of the Default")
+	}
+}
@@ -0,0 +1,65 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// ErrShares is returned when key shares cannot be combined.
+var ErrShares = errors.New("csrf: invalid key shares")
+
+// KeyCombiner assembles a key from shares supplied at startup by
+// independent systems, so no single operator or store holds the full key.
+type KeyCombiner interface {
+	Combine(shares [][]byte) ([]byte, error)
+}
+
+// KeyCombinerFunc adapts a function, such as a Shamir secret sharing
+// implementation's Combine, to the KeyCombiner interface.
+type KeyCombinerFunc func(shares [][]byte) ([]byte, error)
+
+// Combine() calls f(shares).
+func (f KeyCombinerFunc) Combine(shares [][]byte) ([]byte, error) {
+	return f(shares)
+}
+
+// XORShares combines equal-length shares by XOR. Every share is required
+// to recover the key, and any subset reveals nothing about it.
+var XORShares KeyCombiner = KeyCombinerFunc(combineXOR)
+
+func combineXOR(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrShares
+	}
+	key := make([]byte, len(shares[0]))
+	for _, share := range shares {
+		if len(share) != len(key) {
+			return nil, ErrShares
+		}
+		for i, b := range share {
+			key[i] ^= b
+		}
+	}
+	return key, nil
+}
+
+// SplitXOR() splits key into n random shares that XORShares combines back
+// into key, for provisioning the independent systems.
+func SplitXOR(key []byte, n int) ([][]byte, error) {
+	if n < 2 {
+		return nil, ErrShares
+	}
+	shares := make([][]byte, n)
+	last := append([]byte(nil), key...)
+	for i := 0; i < n-1; i++ {
+		shares[i] = make([]byte, len(key))
+		if _, err := rand.Read(shares[i]); err != nil {
+			return nil, err
+		}
+		for j, b := range shares[i] {
+			last[j] ^= b
+		}
+	}
+	shares[n-1] = last
+	return shares, nil
+}
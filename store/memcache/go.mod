@@ -0,0 +1,10 @@
+module github.com/foobaz/csrf/store/memcache
+
+go 1.18
+
+require (
+	github.com/bradfitz/gomemcache v0.0.0-20230905024940-24af94b03874
+	github.com/foobaz/csrf v0.0.0
+)
+
+replace github.com/foobaz/csrf => ../../
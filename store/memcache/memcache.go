@@ -0,0 +1,120 @@
+// Package memcache is a csrf.Store backed by Memcached, so every server of
+// a deployment sees the same used tokens.
+package memcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+	"github.com/foobaz/csrf"
+)
+
+// DefaultPrefix starts the key of every token recorded by a Store.
+const DefaultPrefix = "csrf:used:"
+
+// maxRelativeExpiry is the longest expiration Memcached takes as seconds
+// from now; longer ones must be Unix times.
+const maxRelativeExpiry = 30 * 24 * time.Hour
+
+// Client is the part of a gomemcache client a Store needs, implemented by
+// *memcache.Client.
+type Client interface {
+	Add(item *gomemcache.Item) error
+	Increment(key string, delta uint64) (newValue uint64, err error)
+}
+
+// Store records used tokens in Memcached with add, which only stores a
+// key that is not there yet, so checking and recording a token is one
+// atomic step across servers. Entries expire with the token, rounded up
+// to whole seconds. Uses are counted, for MaxUses above one, with add and
+// incr. Keys are a hash of the token, never the token.
+//
+// Memcached may evict entries early under memory pressure, which lets a
+// token be used again, so size it for the tokens in flight.
+type Store struct {
+	Client Client
+	// Prefix starts every key, DefaultPrefix if empty
+	Prefix string
+}
+
+var (
+	_ csrf.Store        = (*Store)(nil)
+	_ csrf.CounterStore = (*Store)(nil)
+)
+
+// New() returns a Store using client. Assign the result to
+// Authenticator.UsedTokens.
+func New(client Client) *Store {
+	return &Store{Client: client}
+}
+
+func (s *Store) key(token string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	sum := sha256.Sum256([]byte(token))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+// expiration returns the Memcached expiration for expiry.
+func expiration(expiry time.Time) int32 {
+	ttl := time.Until(expiry)
+	if ttl > maxRelativeExpiry {
+		return int32(expiry.Unix() + 1)
+	}
+	seconds := int32((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		// zero would never expire
+		seconds = 1
+	}
+	return seconds
+}
+
+// MarkUsed() records token as used until expiry and returns true if it
+// had not been used before.
+func (s *Store) MarkUsed(token string, expiry time.Time) (bool, error) {
+	err := s.Client.Add(&gomemcache.Item{
+		Key:        s.key(token),
+		Value:      []byte("1"),
+		Expiration: expiration(expiry),
+	})
+	if errors.Is(err, gomemcache.ErrNotStored) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IncrementUses() records a use of token and returns how many times it has
+// been used before expiry, including this one.
+func (s *Store) IncrementUses(token string, expiry time.Time) (int, error) {
+	key := s.key(token)
+	for {
+		err := s.Client.Add(&gomemcache.Item{
+			Key:        key,
+			Value:      []byte("1"),
+			Expiration: expiration(expiry),
+		})
+		if err == nil {
+			return 1, nil
+		}
+		if !errors.Is(err, gomemcache.ErrNotStored) {
+			return 0, err
+		}
+		uses, err := s.Client.Increment(key, 1)
+		if errors.Is(err, gomemcache.ErrCacheMiss) {
+			// expired between add and incr
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return int(uses), nil
+	}
+}
@@ -0,0 +1,96 @@
+// Package redis is a csrf.Store backed by Redis, so every server of a
+// deployment sees the same used tokens.
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/foobaz/csrf"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// DefaultPrefix starts the key of every token recorded by a Store.
+const DefaultPrefix = "csrf:used:"
+
+// incrementScript counts a use of KEYS[1] and sets its TTL, ARGV[1] in
+// milliseconds, with the first use.
+const incrementScript = `local uses = redis.call("INCR", KEYS[1])
+if uses == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return uses`
+
+// Client is the part of a go-redis client a Store needs, implemented by
+// *redis.Client, *redis.ClusterClient and *redis.Ring.
+type Client interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.BoolCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *goredis.Cmd
+}
+
+// Store records used tokens in Redis with SET NX and a TTL, so checking
+// and recording a token is one atomic step across servers and entries
+// expire with the token. Uses are counted, for MaxUses above one, with
+// INCR in a script. Keys are a hash of the token, never the token.
+type Store struct {
+	Client Client
+	// Prefix starts every key, DefaultPrefix if empty
+	Prefix string
+}
+
+var (
+	_ csrf.Store        = (*Store)(nil)
+	_ csrf.StoreContext = (*Store)(nil)
+
+	_ csrf.CounterStore        = (*Store)(nil)
+	_ csrf.CounterStoreContext = (*Store)(nil)
+)
+
+// New() returns a Store using client. Assign the result to
+// Authenticator.UsedTokens.
+func New(client Client) *Store {
+	return &Store{Client: client}
+}
+
+func (s *Store) key(token string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	sum := sha256.Sum256([]byte(token))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+// MarkUsed() records token as used until expiry and returns true if it
+// had not been used before.
+func (s *Store) MarkUsed(token string, expiry time.Time) (bool, error) {
+	return s.MarkUsedContext(context.Background(), token, expiry)
+}
+
+// MarkUsedContext() is like MarkUsed() but honors ctx.
+func (s *Store) MarkUsedContext(ctx context.Context, token string, expiry time.Time) (bool, error) {
+	return s.Client.SetNX(ctx, s.key(token), 1, ttl(expiry)).Result()
+}
+
+// IncrementUses() records a use of token and returns how many times it has
+// been used before expiry, including this one.
+func (s *Store) IncrementUses(token string, expiry time.Time) (int, error) {
+	return s.IncrementUsesContext(context.Background(), token, expiry)
+}
+
+// IncrementUsesContext() is like IncrementUses() but honors ctx.
+func (s *Store) IncrementUsesContext(ctx context.Context, token string, expiry time.Time) (int, error) {
+	return s.Client.Eval(ctx, incrementScript, []string{s.key(token)}, ttl(expiry).Milliseconds()).Int()
+}
+
+// ttl returns the Redis TTL for expiry.
+func ttl(expiry time.Time) time.Duration {
+	ttl := time.Until(expiry)
+	if ttl < time.Millisecond {
+		// the token is about to expire, but Redis needs a positive TTL
+		ttl = time.Millisecond
+	}
+	return ttl
+}
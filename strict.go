@@ -0,0 +1,41 @@
+package csrf
+
+// DefaultTokenFloor is the shortest TokenLength WithStrictConfig(0)
+// accepts. With the default alphabet and salt, 16 characters give about
+// 47 EffectiveBits().
+const DefaultTokenFloor = 16
+
+// WithStrictConfig() makes NewAuthenticator() enforce a security floor
+// for production: it rejects a TokenLength below minTokenLength, or
+// DefaultTokenFloor if minTokenLength is zero, with ErrTokenLength, and
+// warns through the Logger about keys shorter than the MAC output or
+// secondary keys whose length differs from the primary key, which
+// usually means one of them was loaded or encoded wrong.
+func WithStrictConfig(minTokenLength int) AuthenticatorOption {
+	return func(a *Authenticator) {
+		if minTokenLength <= 0 {
+			minTokenLength = DefaultTokenFloor
+		}
+		a.tokenFloor = minTokenLength
+	}
+}
+
+// checkStrict applies the WithStrictConfig() policy.
+func (a *Authenticator) checkStrict() error {
+	if a.TokenLength < a.tokenFloor {
+		return ErrTokenLength
+	}
+	if a.Keys != nil {
+		return nil
+	}
+	size := a.newMAC(a.Key).Size()
+	if len(a.Key) < size {
+		a.logger().Warn("csrf: key shorter than MAC output", "length", len(a.Key), "recommended", size)
+	}
+	for _, k := range a.SecondaryKeys {
+		if len(k.Key) != len(a.Key) {
+			a.logger().Warn("csrf: secondary key length differs from primary key", "id", k.ID, "length", len(k.Key), "primary", len(a.Key))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package csrf
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// TemplateField() renders a hidden csrf_token input, or the middleware's
+// FormField, holding Token(r), for forms in html/template pages served
+// through Protect(). It renders nothing if the request has no token.
+func TemplateField(r *http.Request) template.HTML {
+	token := Token(r)
+	if token == "" {
+		return ""
+	}
+	return hiddenField(r, token)
+}
+
+// TemplateFieldFor() is like TemplateField() but holds TokenFor(r, method,
+// action), for a form submitting to action. It renders nothing if no
+// token can be made.
+func TemplateFieldFor(r *http.Request, method, action string) template.HTML {
+	token, err := TokenFor(r, method, action)
+	if err != nil || token == "" {
+		return ""
+	}
+	return hiddenField(r, token)
+}
+
+func hiddenField(r *http.Request, token string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(requestField(r)) + `" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// FuncMap() returns template functions csrfField, which is TemplateField(),
+// csrfFieldFor, which is TemplateFieldFor(), and csrfToken, which is
+// Token(). Pass the request to them:
+//
+//	tmpl := template.New("page").Funcs(csrf.FuncMap())
+//	// in the template: <form method="post">{{csrfField .Request}}...
+//	// or: <form method="post" action="/delete">{{csrfFieldFor .Request "post" "/delete"}}...
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"csrfField":    TemplateField,
+		"csrfFieldFor": TemplateFieldFor,
+		"csrfToken":    Token,
+	}
+}
@@ -0,0 +1,110 @@
+package csrf
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ErrUnknownTenant is returned for a request whose tenant has no
+// Authenticator in the AuthenticatorSet.
+var ErrUnknownTenant = errors.New("csrf: unknown tenant")
+
+// TenantExtractor returns the tenant or site ID of a request.
+type TenantExtractor func(r *http.Request) (string, error)
+
+// HostTenant() is a TenantExtractor returning the request host without
+// port, for sites told apart by domain.
+func HostTenant(r *http.Request) (string, error) {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host, nil
+	}
+	return r.Host, nil
+}
+
+// AuthenticatorSet holds an Authenticator per tenant, each with its own
+// key, lifetime and token length, for serving many sites from one binary.
+// Tenants can be added and removed while serving.
+type AuthenticatorSet struct {
+	// Default, if set, serves tenants without their own Authenticator
+	Default *Authenticator
+
+	mutex   sync.RWMutex
+	tenants map[string]*Authenticator
+}
+
+// Set() makes a the Authenticator for tenant.
+func (s *AuthenticatorSet) Set(tenant string, a *Authenticator) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.tenants == nil {
+		s.tenants = make(map[string]*Authenticator)
+	}
+	s.tenants[tenant] = a
+}
+
+// Delete() removes the Authenticator for tenant.
+func (s *AuthenticatorSet) Delete(tenant string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tenants, tenant)
+}
+
+// Get() returns the Authenticator for tenant, the Default if it has
+// none, or ErrUnknownTenant.
+func (s *AuthenticatorSet) Get(tenant string) (*Authenticator, error) {
+	s.mutex.RLock()
+	a, ok := s.tenants[tenant]
+	s.mutex.RUnlock()
+	if ok {
+		return a, nil
+	}
+	if s.Default != nil {
+		return s.Default, nil
+	}
+	return nil, ErrUnknownTenant
+}
+
+// logger returns the Logger of the Default, for requests without a
+// tenant.
+func (s *AuthenticatorSet) logger() Logger {
+	if s.Default != nil {
+		return s.Default.logger()
+	}
+	return stdLogger{}
+}
+
+// WithTenants() selects the Authenticator for each request from set by the
+// tenant extract returns, in place of WithAuthenticator(). With
+// WithDoubleSubmit(), cookies are made with the tenant's Authenticator.
+// Unsafe requests of unknown tenants are rejected with ErrUnknownTenant;
+// safe ones are passed on without a token.
+func WithTenants(set *AuthenticatorSet, extract TenantExtractor) Option {
+	return func(m *Middleware) {
+		m.Tenants = set
+		m.Tenant = extract
+	}
+}
+
+// forRequest returns m configured for r, with the Authenticator of its
+// tenant or the current configuration of Frozen.
+func (m *Middleware) forRequest(r *http.Request) (*Middleware, error) {
+	if m.Frozen != nil {
+		return m.forFrozen(), nil
+	}
+	if m.Tenants == nil {
+		return m, nil
+	}
+	tenant, err := m.Tenant(r)
+	if err != nil {
+		return nil, err
+	}
+	a, err := m.Tenants.Get(tenant)
+	if err != nil {
+		return nil, err
+	}
+	t := *m
+	t.setAuthenticator(a)
+	return &t, nil
+}
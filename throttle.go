@@ -0,0 +1,112 @@
+package csrf
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailureHook is called by Protect() for each request of a session that
+// fails validation, with the Reason() and how many failures the session
+// has had in the FailureCounter's window, this one included. It can lock
+// the session out or require a CAPTCHA once count gets high. Requests
+// without a session are not counted.
+type FailureHook func(r *http.Request, session []byte, reason string, count int)
+
+// FailureCounter counts validation failures per session. Implementations
+// may be shared between servers.
+type FailureCounter interface {
+	// Fail records a failure of session at now and returns the failures
+	// in the window ending at now.
+	Fail(session []byte, now time.Time) (int, error)
+}
+
+// WithFailureHook() makes Protect() count validation failures per session
+// with counter and report them to hook.
+func WithFailureHook(counter FailureCounter, hook FailureHook) Option {
+	return func(m *Middleware) {
+		m.Failures = counter
+		m.OnFailure = hook
+	}
+}
+
+// throttle counts a failure of r for err and reports it to OnFailure.
+func (m *Middleware) throttle(r *http.Request, now time.Time, err error) {
+	if m.Failures == nil || m.OnFailure == nil || m.Session == nil {
+		return
+	}
+	session, serr := m.Session(r)
+	if serr != nil || len(session) == 0 {
+		return
+	}
+	count, cerr := m.Failures.Fail(session, now)
+	if cerr != nil {
+		m.Authenticator.logger().Warn("csrf: failure counting failed", "reason", cerr)
+		return
+	}
+	m.OnFailure(r, session, Reason(err), count)
+}
+
+// defaultFailureWindow is the SlidingWindowCounter Window if unset.
+const defaultFailureWindow = 10 * time.Minute
+
+// SlidingWindowCounter is an in-memory FailureCounter for a single server.
+// It approximates a sliding window from the counts of the current and
+// previous fixed windows, weighting the previous one by how much of it
+// the sliding window still covers, so each session costs a few words.
+// Sessions idle for two windows are removed at most once per Window.
+type SlidingWindowCounter struct {
+	// Window is how far back failures are counted, 10 minutes if unset
+	Window time.Duration
+
+	mutex    sync.Mutex
+	sessions map[string]*failureCount
+	sweep    time.Time
+}
+
+type failureCount struct {
+	start          time.Time
+	previous, last int
+}
+
+func (c *SlidingWindowCounter) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultFailureWindow
+}
+
+// Fail() records a failure of session at now and returns the approximate
+// failures in the Window ending at now.
+func (c *SlidingWindowCounter) Fail(session []byte, now time.Time) (int, error) {
+	window := c.window()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sessions == nil {
+		c.sessions = make(map[string]*failureCount)
+	}
+	if now.After(c.sweep) {
+		for s, f := range c.sessions {
+			if now.Sub(f.start) >= 2*window {
+				delete(c.sessions, s)
+			}
+		}
+		c.sweep = now.Add(window)
+	}
+
+	f, ok := c.sessions[string(session)]
+	if !ok {
+		f = &failureCount{start: now}
+		c.sessions[string(session)] = f
+	}
+	switch elapsed := now.Sub(f.start); {
+	case elapsed >= 2*window:
+		f.start, f.previous, f.last = now, 0, 0
+	case elapsed >= window:
+		f.start, f.previous, f.last = f.start.Add(window), f.last, 0
+	}
+	f.last++
+	covered := window - now.Sub(f.start)
+	return f.last + int(int64(f.previous)*int64(covered)/int64(window)), nil
+}
@@ -0,0 +1,64 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TokenHandler() returns a handler that vends tokens to single page
+// applications. A GET responds with {"token":"...","expiresIn":1234}, the
+// token for the caller's session and the seconds it stays valid, so the
+// application can fetch a fresh one over XHR before submitting. It takes
+// the same options as Protect(). With WithActionBinding(), the token is
+// for the method and path in the query parameters of the same name,
+// defaulting to POST. Requests without a usable session get 403 Forbidden.
+// It panics like Protect() on a misconfiguration.
+func TokenHandler(opts ...Option) http.Handler {
+	m := Protect(nil, opts...).(*Middleware)
+	return http.HandlerFunc(m.vend)
+}
+
+func (m *Middleware) vend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	m, err := m.forRequest(r)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if m.SessionCookie != nil {
+		r = m.SessionCookie.ensure(w, r)
+	}
+	a := m.Authenticator
+	now := a.now()
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = http.MethodPost
+	}
+	token, counter, err := m.token(w, r, now, method, r.URL.Query().Get("path"))
+	if isSessionError(err) {
+		a.logger().Warn("csrf: token request rejected", "reason", err, "status", http.StatusForbidden)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		a.logger().Warn("csrf: token generation failed", "reason", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	expiry, err := a.expiry(now, counter)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	body, _ := json.Marshal(struct {
+		Token     string `json:"token"`
+		ExpiresIn int64  `json:"expiresIn"`
+	}{token, int64(expiry.Sub(now).Seconds())})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,192 @@
+package csrf
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry Transport replaces a token.
+const tokenRefreshMargin = 10 * time.Second
+
+// maxTokenResponse caps how much of a TokenHandler() response is read.
+const maxTokenResponse = 4096
+
+// Transport is an http.RoundTripper adding a token to every unsafe request,
+// for integration tests and internal services calling endpoints behind
+// Protect(). It fetches tokens from a TokenHandler() endpoint, keeps them
+// until shortly before they expire, and retries a request rejected with
+// 403 Forbidden once with a fresh token, if its body can be replayed.
+//
+// Tokens are bound to the session the endpoint sees, so share a cookie jar
+// between the client and the Transport:
+//
+//	jar, _ := cookiejar.New(nil)
+//	client := &http.Client{Jar: jar, Transport: &csrf.Transport{TokenURL: "/csrf-token", Jar: jar}}
+type Transport struct {
+	// Base sends the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// TokenURL is the TokenHandler() endpoint. A relative URL is resolved
+	// against each request.
+	TokenURL string
+	// Jar, if set, supplies cookies to and stores cookies from the token
+	// endpoint, such as a session or double submit cookie.
+	Jar http.CookieJar
+	// Header carries the token, TokenHeader if empty.
+	Header string
+	// BindAction fetches a token for the method and path of each request,
+	// for servers using WithActionBinding().
+	BindAction bool
+
+	mutex  sync.Mutex
+	tokens map[string]vendedToken
+}
+
+// vendedToken is a token fetched by Transport.
+type vendedToken struct {
+	token  string
+	expiry time.Time
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) header() string {
+	if t.Header != "" {
+		return t.Header
+	}
+	return TokenHeader
+}
+
+// RoundTrip() sends req, with a token if its method is unsafe and it does
+// not carry one already.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isSafeMethod(req.Method) || req.Header.Get(t.header()) != "" {
+		return t.base().RoundTrip(req)
+	}
+	resp, err := t.send(req, req.Body, false)
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+	body := req.Body
+	if body != nil && body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		if body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	// the token may have expired or been used up
+	resp.Body.Close()
+	return t.send(req, body, true)
+}
+
+// send sends a copy of req with body and a token, a fresh one if refresh
+// is set.
+func (t *Transport) send(req *http.Request, body io.ReadCloser, refresh bool) (*http.Response, error) {
+	token, err := t.token(req, refresh)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	r.Header.Set(t.header(), token)
+	if t.Jar != nil {
+		// the token endpoint may have just set cookies the client
+		// added before they existed
+		for _, c := range t.Jar.Cookies(r.URL) {
+			if _, err := r.Cookie(c.Name); err != nil {
+				r.AddCookie(c)
+			}
+		}
+	}
+	return t.base().RoundTrip(r)
+}
+
+// token returns a held token for req, fetching one if there is none, it
+// is about to expire, or refresh is set.
+func (t *Transport) token(req *http.Request, refresh bool) (string, error) {
+	u, err := req.URL.Parse(t.TokenURL)
+	if err != nil {
+		return "", err
+	}
+	if t.BindAction {
+		q := u.Query()
+		q.Set("method", req.Method)
+		q.Set("path", req.URL.Path)
+		u.RawQuery = q.Encode()
+	}
+	key := u.String()
+
+	t.mutex.Lock()
+	held, ok := t.tokens[key]
+	t.mutex.Unlock()
+	if ok && !refresh && time.Now().Add(tokenRefreshMargin).Before(held.expiry) {
+		return held.token, nil
+	}
+
+	held, err = t.fetch(req, u)
+	if err != nil {
+		return "", err
+	}
+	t.mutex.Lock()
+	if t.tokens == nil {
+		t.tokens = make(map[string]vendedToken)
+	}
+	t.tokens[key] = held
+	t.mutex.Unlock()
+	return held.token, nil
+}
+
+// fetch gets a token from the endpoint at u for req.
+func (t *Transport) fetch(req *http.Request, u *url.URL) (vendedToken, error) {
+	r, err := http.NewRequestWithContext(req.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return vendedToken{}, err
+	}
+	if t.Jar != nil {
+		for _, c := range t.Jar.Cookies(u) {
+			r.AddCookie(c)
+		}
+	}
+	start := time.Now()
+	resp, err := t.base().RoundTrip(r)
+	if err != nil {
+		return vendedToken{}, err
+	}
+	defer resp.Body.Close()
+	if t.Jar != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			t.Jar.SetCookies(u, cookies)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return vendedToken{}, errors.New("csrf: token endpoint returned " + resp.Status)
+	}
+	var vended struct {
+		Token     string `json:"token"`
+		ExpiresIn int64  `json:"expiresIn"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponse)).Decode(&vended); err != nil {
+		return vendedToken{}, err
+	}
+	if vended.Token == "" {
+		return vendedToken{}, errors.New("csrf: token endpoint returned no token")
+	}
+	return vendedToken{
+		token:  vended.Token,
+		expiry: start.Add(time.Duration(vended.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,76 @@
+package csrf
+
+import (
+	"context"
+	"time"
+)
+
+// Format bits of a Versioned token's first character, which is
+// versionBase plus the bits.
+const (
+	formatWindow = 1 << iota
+	formatMasked
+
+	versionBase = '1'
+	maxVersion  = versionBase + (formatWindow | formatMasked)
+)
+
+// versionPrefix returns the format character for new tokens, or "" if
+// Versioned is not set.
+func (a *Authenticator) versionPrefix() string {
+	if !a.Versioned {
+		return ""
+	}
+	v := byte(versionBase)
+	if a.EmbedWindow {
+		v += formatWindow
+	}
+	if a.Mask {
+		v += formatMasked
+	}
+	return string(v)
+}
+
+// versioned returns a copy of a with the format named by the first
+// character of token, and token without it, if token has a format
+// character and the length of that format.
+func (a *Authenticator) versioned(token string) (*Authenticator, string, bool) {
+	if len(token) == 0 || token[0] < versionBase || token[0] > maxVersion {
+		return nil, "", false
+	}
+	format := token[0] - versionBase
+	b := a.clone()
+	b.EmbedWindow = format&formatWindow != 0
+	b.Mask = format&formatMasked != 0
+	body := token[1:]
+	n := b.tokenLength()
+	if b.Mask {
+		n *= 2
+	}
+	if len(body) != n {
+		return nil, "", false
+	}
+	return b, body, true
+}
+
+// legacyLength reports whether a token of length n could be an
+// unversioned token, whose first character can look like a version.
+func (a *Authenticator) legacyLength(n int) bool {
+	return n == a.tokenLength() || a.Mask && n == 2*a.tokenLength()
+}
+
+// recorded returns the form of a valid token kept in the Denylist and
+// UsedTokens: canonical, unmasked and without version, so every encoding
+// of a token is revoked together.
+func (a *Authenticator) recorded(ctx context.Context, date time.Time, session []byte, token string) (string, error) {
+	token = a.canonicalToken(token)
+	if b, body, ok := a.versioned(token); ok {
+		if !a.legacyLength(len(token)) {
+			return b.unmaskToken(body)
+		}
+		if _, err := b.verifyToken(ctx, date, session, session, body, false); err == nil {
+			return b.unmaskToken(body)
+		}
+	}
+	return a.unmaskToken(token)
+}
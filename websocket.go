@@ -0,0 +1,93 @@
+package csrf
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebSocketProtocolPrefix marks the Sec-WebSocket-Protocol entry carrying a
+// token, since browsers cannot set other headers on a WebSocket handshake.
+// A client offers it next to its real subprotocol, as in
+//
+//	new WebSocket(url, ["chat", "csrf." + token])
+const WebSocketProtocolPrefix = "csrf."
+
+// isWebSocketUpgrade reports whether r is a WebSocket handshake.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+// headerHasToken reports whether a comma separated header lists token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketToken() returns the token of a WebSocket handshake, from a
+// Sec-WebSocket-Protocol entry starting with WebSocketProtocolPrefix or
+// else the field query parameter. The protocol entry is removed from r,
+// so an upgrader negotiating from the header never selects it; the
+// server must still select one of the client's real subprotocols, or
+// browsers fail the connection.
+func WebSocketToken(r *http.Request, field string) string {
+	var token string
+	values := r.Header.Values("Sec-WebSocket-Protocol")
+	var kept []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if strings.HasPrefix(v, WebSocketProtocolPrefix) {
+				if token == "" {
+					token = v[len(WebSocketProtocolPrefix):]
+				}
+				continue
+			}
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+	}
+	if token != "" {
+		if len(kept) == 0 {
+			r.Header.Del("Sec-WebSocket-Protocol")
+		} else {
+			r.Header.Set("Sec-WebSocket-Protocol", strings.Join(kept, ", "))
+		}
+		return token
+	}
+	return r.URL.Query().Get(field)
+}
+
+// ValidateWebSocket() returns nil if the WebSocket handshake r carries a
+// valid token for the session, as found by WebSocketToken() with the
+// TokenField query parameter. Handshakes are GET requests, which
+// middleware lets through unchecked, and the browser sends cookies with
+// them from any origin, so a socket acting for the user needs a token
+// like any unsafe request.
+func (a *Authenticator) ValidateWebSocket(r *http.Request, session []byte) error {
+	token := WebSocketToken(r, TokenField)
+	_, err := a.validate(r.Context(), time.Time{}, session, a.BindRequest(r, session), token)
+	return err
+}
+
+// WithWebSocketCheck() makes Protect() check the token of WebSocket
+// handshakes, from WebSocketToken() with the FormField query parameter,
+// like that of an unsafe request. With BindAction, the token must be
+// bound to GET and the socket's path.
+func WithWebSocketCheck() Option {
+	return func(m *Middleware) {
+		m.WebSockets = true
+	}
+}
+
+// checked reports whether r must carry a token.
+func (m *Middleware) checked(r *http.Request) bool {
+	return !isSafeMethod(r.Method) || m.WebSockets && isWebSocketUpgrade(r)
+}
@@ -0,0 +1,163 @@
+package csrf
+
+import (
+	"time"
+
+	"github.com/foobaz/csrf/internal/core"
+)
+
+// MaxLifetime is the longest accepted Lifetime. Tokens stay valid for up
+// to AcceptedWindows times Lifetime, and longer windows leave time arithmetic such as
+// revocation expiry without a safe margin.
+const MaxLifetime = 365 * 24 * time.Hour
+
+// MaxWindows is the largest accepted AcceptedWindows or FutureWindows.
+// Validation computes a MAC for every accepted window, so the counts bound
+// its cost, and they keep token expiry arithmetic in range.
+const MaxWindows = 64
+
+// WindowFunc maps a time to the counter of the window containing it and
+// the time remaining until that window ends. Counters of consecutive
+// windows must differ by one, because previous windows are also accepted.
+type WindowFunc func(date time.Time) (counter int64, remaining time.Duration)
+
+// CheckConfig() returns an error if the Authenticator cannot generate
+// usable tokens: a Lifetime that is not positive or exceeds MaxLifetime,
+// AcceptedWindows or FutureWindows above MaxWindows, a TokenLength too
+// short to hold a hash and a salt, a SaltLength that leaves no room for
+// the hash, or an Alphabet rejected with ErrAlphabet.
+// Call it once at startup to fail fast instead of rejecting every
+// request.
+func (a *Authenticator) CheckConfig() error {
+	if err := a.checkLifetime(); err != nil {
+		return err
+	}
+	if a.AcceptedWindows > MaxWindows || a.FutureWindows > MaxWindows {
+		return ErrWindows
+	}
+	if a.TokenLength < 2 {
+		return ErrTokenLength
+	}
+	if a.SaltLength < 0 || a.SaltLength >= a.TokenLength {
+		return ErrSaltLength
+	}
+	if a.MaxUses > 1 && a.UsedTokens != nil {
+		if _, ok := a.UsedTokens.(CounterStore); !ok {
+			return ErrNoCounterStore
+		}
+	}
+	if a.Alphabet != nil {
+		return a.checkAlphabet()
+	}
+	return nil
+}
+
+func (a *Authenticator) checkLifetime() error {
+	if a.isClosed() {
+		return ErrClosed
+	}
+	if a.Window != nil {
+		return nil
+	}
+	if a.Lifetime <= 0 || a.Lifetime > MaxLifetime {
+		return ErrLifetime
+	}
+	return nil
+}
+
+// acceptedWindows returns AcceptedWindows or its default of two, at most
+// MaxWindows.
+func (a *Authenticator) acceptedWindows() int {
+	if a.AcceptedWindows <= 0 {
+		return 2
+	}
+	if a.AcceptedWindows > MaxWindows {
+		return MaxWindows
+	}
+	return a.AcceptedWindows
+}
+
+// futureWindows returns FutureWindows, treating negative values as zero,
+// at most MaxWindows.
+func (a *Authenticator) futureWindows() int {
+	if a.FutureWindows < 0 {
+		return 0
+	}
+	if a.FutureWindows > MaxWindows {
+		return MaxWindows
+	}
+	return a.FutureWindows
+}
+
+// now returns the time from the Now clock, or time.Now if it is nil.
+func (a *Authenticator) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+// date returns date, or the current time if date is zero.
+func (a *Authenticator) date(date time.Time) time.Time {
+	if date.IsZero() {
+		return a.now()
+	}
+	return date
+}
+
+// windowChar returns the character EmbedWindow tokens carry for counter.
+func (a *Authenticator) windowChar(counter int64) byte {
+	alphabet := a.alphabet()
+	n := int64(len(alphabet))
+	return alphabet[(counter%n+n)%n]
+}
+
+// counter returns the time window containing date.
+func (a *Authenticator) counter(date time.Time) (int64, error) {
+	counter, _, err := a.window(date)
+	return counter, err
+}
+
+// window returns the time window containing date and how long remains
+// until it ends.
+func (a *Authenticator) window(date time.Time) (int64, time.Duration, error) {
+	if a.isClosed() {
+		return 0, 0, ErrClosed
+	}
+	date = a.date(date)
+	if a.Window != nil {
+		counter, remaining := a.Window(date)
+		return counter, remaining, nil
+	}
+	if err := a.checkLifetime(); err != nil {
+		return 0, 0, err
+	}
+
+	if !a.TOTP {
+		return nanoWindow(date, int64(a.Lifetime))
+	}
+
+	var t0 int64
+	if !a.T0.IsZero() {
+		t0 = a.T0.Unix()
+	}
+	step := int64(a.Lifetime / time.Second)
+	if step < 1 {
+		step = 1
+	}
+	elapsed := date.Unix() - t0
+	if elapsed < 0 {
+		return 0, 0, ErrDateRange
+	}
+	remaining := time.Duration(step-elapsed%step)*time.Second - time.Duration(date.Nanosecond())
+	return elapsed / step, remaining, nil
+}
+
+// nanoWindow divides the nanoseconds since the Unix epoch by lifetime.
+func nanoWindow(date time.Time, lifetime int64) (int64, time.Duration, error) {
+	counter, remaining, ok := core.Window(date, lifetime)
+	if !ok {
+		return 0, 0, ErrDateRange
+	}
+	return counter, remaining, nil
+}
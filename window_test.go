@@ -0,0 +1,78 @@
+package csrf
+
+import (
+	"testing"
+	"time"
+)
+
+// testSession is a session binding long enough for every preset.
+var testSession = []byte("0123456789abcdef0123456789abcdef")
+
+func testAuthenticator(t testing.TB) *Authenticator {
+	t.Helper()
+	key := make([]byte, MinKeyLength)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	a, err := NewAuthenticator(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestWindowBoundaries(t *testing.T) {
+	const lifetime = 250 * time.Millisecond
+	// start is the first nanosecond of a window
+	start := time.Unix(1700000000, 0).UnixNano() / int64(lifetime) * int64(lifetime)
+	at := func(windows int64, offset time.Duration) time.Time {
+		return time.Unix(0, start+windows*int64(lifetime)+int64(offset))
+	}
+	last := lifetime - 1
+
+	tests := []struct {
+		name     string
+		accepted int
+		future   int
+		grace    time.Duration
+		issued   time.Time
+		checked  time.Time
+		valid    bool
+	}{
+		{name: "first nanosecond, same instant", issued: at(0, 0), checked: at(0, 0), valid: true},
+		{name: "first nanosecond, end of window", issued: at(0, 0), checked: at(0, last), valid: true},
+		{name: "last nanosecond, same instant", issued: at(0, last), checked: at(0, last), valid: true},
+		{name: "last nanosecond, next window", issued: at(0, last), checked: at(1, 0), valid: true},
+		{name: "last accepted nanosecond", issued: at(0, 0), checked: at(1, last), valid: true},
+		{name: "first expired nanosecond", issued: at(0, last), checked: at(2, 0), valid: false},
+		{name: "one window, next window", accepted: 1, issued: at(0, last), checked: at(1, 0), valid: false},
+		{name: "one window, end of window", accepted: 1, issued: at(0, 0), checked: at(0, last), valid: true},
+		{name: "three windows, last nanosecond", accepted: 3, issued: at(0, 0), checked: at(2, last), valid: true},
+		{name: "three windows, expired", accepted: 3, issued: at(0, last), checked: at(3, 0), valid: false},
+		{name: "grace, last nanosecond", grace: 50 * time.Millisecond, issued: at(0, 0), checked: at(2, 50*time.Millisecond-1), valid: true},
+		{name: "grace, expired", grace: 50 * time.Millisecond, issued: at(0, 0), checked: at(2, 50*time.Millisecond), valid: false},
+		{name: "future window rejected", issued: at(1, 0), checked: at(0, last), valid: false},
+		{name: "future window accepted", future: 1, issued: at(1, 0), checked: at(0, 0), valid: true},
+		{name: "future window too far", future: 1, issued: at(2, 0), checked: at(0, last), valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthenticator(t)
+			a.Lifetime = lifetime
+			a.AcceptedWindows = tt.accepted
+			a.FutureWindows = tt.future
+			a.Grace = tt.grace
+			token, err := a.GenerateTokenErr(tt.issued, testSession)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = a.ValidateTokenErr(tt.checked, testSession, token)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateTokenErr() = %v, want nil", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("ValidateTokenErr() = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package csrf
+
+import "net/http"
+
+// The cookie and header names Angular and axios use for CSRF tokens by
+// default.
+const (
+	XSRFCookieName = "XSRF-TOKEN"
+	XSRFHeader     = "X-XSRF-TOKEN"
+)
+
+// NewXSRF() returns a DoubleSubmit following the cookie-to-header
+// convention of Angular and axios: the signed token is set in a
+// JavaScript-readable XSRF-TOKEN cookie, which the client echoes in the
+// X-XSRF-TOKEN header. The value is signed with the key of a, so a
+// subdomain that can set cookies cannot forge one. It could still plant
+// a value obtained from this server; rename the cookie to
+// "__Host-XSRF-TOKEN", and tell the client, to stop subdomains setting it
+// at all.
+func NewXSRF(a *Authenticator) *DoubleSubmit {
+	return &DoubleSubmit{
+		Authenticator: a,
+		Cookie: http.Cookie{
+			Name:     XSRFCookieName,
+			Path:     "/",
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		},
+	}
+}
+
+// WithXSRF() protects requests with NewXSRF(a) cookies and only accepts
+// the token from the X-XSRF-TOKEN header, for API backends whose clients
+// follow the Angular and axios convention.
+func WithXSRF(a *Authenticator) Option {
+	return func(m *Middleware) {
+		WithDoubleSubmit(NewXSRF(a))(m)
+		m.TokenHeaders = []string{XSRFHeader}
+		m.TokenSources = []TokenSource{FromHeader}
+	}
+}